@@ -0,0 +1,517 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/database"
+	"github.com/FirstTirr/G7KAIH-GO/internal/handlers"
+	"github.com/FirstTirr/G7KAIH-GO/internal/legacy"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/printables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// findUser looks a user up by ID, username or NIS
+func (a *app) findUser(ref string) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		err = a.db.Where("id = ?", id).First(&profile).Error
+	} else {
+		// A username wins over another user's NIS that happens to match it
+		ref = strings.TrimSpace(ref)
+		err = a.db.Where("username = ?", strings.ToLower(ref)).First(&profile).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = a.db.Where("nis = ?", ref).First(&profile).Error
+		}
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user %q not found", ref)
+		}
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// roleExists reports whether the role is defined in the roles table; the
+// CLI operator may create users of any role
+func (a *app) roleExists(role string) (bool, error) {
+	var count int64
+	err := a.db.Model(&models.Role{}).Where("name = ?", role).Count(&count).Error
+	return count > 0, err
+}
+
+func runCreateUser(a *app, args []string) error {
+	fs := a.newFlagSet("create-user", "-name NAME -class CLASS -role ROLE [-nis NIS]")
+	name := fs.String("name", "", "full name (required)")
+	class := fs.String("class", "", "class, e.g. 7A (required)")
+	role := fs.String("role", "", "role, e.g. siswa or admin (required)")
+	nis := fs.String("nis", "", "student NIS, used as username")
+	fs.Parse(args)
+
+	req := handlers.CreateUserRequest{
+		Name:  strings.TrimSpace(*name),
+		Class: strings.TrimSpace(*class),
+		Role:  strings.TrimSpace(*role),
+	}
+	if req.Name == "" || req.Class == "" || req.Role == "" {
+		fs.Usage()
+		return errors.New("-name, -class and -role are required")
+	}
+	if n := strings.TrimSpace(*nis); n != "" {
+		req.NIS = &n
+	}
+	if ok, err := a.roleExists(req.Role); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("unknown role %q", req.Role)
+	}
+
+	profile, pin, err := a.admin.CreateUserAccount(req)
+	if err != nil {
+		return err
+	}
+	a.record(audit.Entry{Action: "user.create", TargetType: "user", TargetID: profile.ID.String(), After: profile})
+
+	return a.out.print(
+		handlers.CreateUserResponse{UserID: profile.ID.String(), Username: *profile.Username, PIN: pin},
+		[]string{"USER ID", "NAME", "CLASS", "ROLE", "USERNAME", "PIN"},
+		[][]string{{profile.ID.String(), profile.Name, profile.Class, profile.Role, *profile.Username, pin}},
+	)
+}
+
+func runImportUsers(a *app, args []string) error {
+	fs := a.newFlagSet("import-users", "-file users.csv")
+	path := fs.String("file", "", "CSV file with a header row and the columns name,class,role[,nis] (required)")
+	fs.Parse(args)
+	if *path == "" {
+		fs.Usage()
+		return errors.New("-file is required")
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var roles []string
+	if err := a.db.Model(&models.Role{}).Pluck("name", &roles).Error; err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		known[r] = true
+	}
+
+	result, err := a.admin.ImportUsers(f, func(role string) bool { return known[role] })
+	if err != nil {
+		return err
+	}
+
+	created := make([]string, 0, len(result.Tokens))
+	rows := make([][]string, 0, len(result.Tokens))
+	for _, t := range result.Tokens {
+		created = append(created, t.ID)
+		rows = append(rows, []string{t.ID, t.Name, t.Class, t.Username, t.PIN})
+	}
+	// PINs stay out of the log
+	a.record(audit.Entry{
+		Action:     "user.bulk_import",
+		TargetType: "user",
+		Details: map[string]interface{}{
+			"file":          *path,
+			"success_count": result.SuccessCount,
+			"error_count":   result.ErrorCount,
+			"created":       created,
+		},
+	})
+
+	for _, rowErr := range result.Errors {
+		log.Println(rowErr)
+	}
+	if err := a.out.print(result, []string{"USER ID", "NAME", "CLASS", "USERNAME", "PIN"}, rows); err != nil {
+		return err
+	}
+	a.out.message("\n%d created, %d failed", result.SuccessCount, result.ErrorCount)
+	return nil
+}
+
+func runResetToken(a *app, args []string) error {
+	fs := a.newFlagSet("reset-token", "(-user ID|USERNAME|NIS | -class CLASS [-role ROLE] | -role ROLE) [-pdf slips.pdf]")
+	user := fs.String("user", "", "reset a single user")
+	class := fs.String("class", "", "reset everyone in the class")
+	role := fs.String("role", "", "reset everyone with the role (within -class if given)")
+	pdfPath := fs.String("pdf", "", "also write printable login slips to this file")
+	fs.Parse(args)
+
+	var profiles []models.UserProfile
+	skipped := 0
+	switch {
+	case *user != "":
+		profile, err := a.findUser(*user)
+		if err != nil {
+			return err
+		}
+		if profile.PasswordHash != nil {
+			return fmt.Errorf("%s signs in with a password; a new PIN would not let them in", profile.Name)
+		}
+		profiles = []models.UserProfile{*profile}
+	case *class != "" || *role != "":
+		query := a.db.Model(&models.UserProfile{})
+		if *class != "" {
+			query = query.Where("class = ?", strings.TrimSpace(*class))
+		}
+		if *role != "" {
+			query = query.Where("role = ?", *role)
+		}
+		var matched []models.UserProfile
+		if err := query.Order("class ASC, name ASC").Find(&matched).Error; err != nil {
+			return err
+		}
+		if len(matched) == 0 {
+			return errors.New("no users match the filter")
+		}
+		for _, profile := range matched {
+			if profile.PasswordHash != nil {
+				skipped++
+				continue
+			}
+			profiles = append(profiles, profile)
+		}
+		if len(profiles) == 0 {
+			return errors.New("every matching user signs in with a password")
+		}
+	default:
+		fs.Usage()
+		return errors.New("-user, -class or -role is required")
+	}
+
+	results, err := a.admin.ResetCredentials(profiles)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(results))
+	rows := make([][]string, 0, len(results))
+	slips := make([]printables.TokenSlip, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.UserID)
+		rows = append(rows, []string{r.UserID, r.Name, r.Class, r.Username, r.PIN})
+		slips = append(slips, printables.TokenSlip{Name: r.Name, Class: r.Class, Username: r.Username, PIN: r.PIN})
+	}
+	a.record(audit.Entry{
+		Action:     "user.reset_token",
+		TargetType: "user",
+		Details:    map[string]interface{}{"class": *class, "role": *role, "user_ids": ids, "skipped": skipped},
+	})
+
+	if *pdfPath != "" {
+		title := "Kode Login G7KAIH"
+		if *class != "" {
+			title += " - Kelas " + *class
+		}
+		f, err := os.Create(*pdfPath)
+		if err != nil {
+			return err
+		}
+		if err := printables.WriteTokenSlips(f, title, slips); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := a.out.print(results, []string{"USER ID", "NAME", "CLASS", "USERNAME", "PIN"}, rows); err != nil {
+		return err
+	}
+	if skipped > 0 {
+		a.out.message("\n%d skipped: they sign in with a password", skipped)
+	}
+	return nil
+}
+
+func runLinkParent(a *app, args []string) error {
+	fs := a.newFlagSet("link-parent", "-parent ID|USERNAME -student ID|USERNAME|NIS")
+	parentRef := fs.String("parent", "", "parent account (required)")
+	studentRef := fs.String("student", "", "student account (required)")
+	fs.Parse(args)
+	if *parentRef == "" || *studentRef == "" {
+		fs.Usage()
+		return errors.New("-parent and -student are required")
+	}
+
+	parent, err := a.findUser(*parentRef)
+	if err != nil {
+		return err
+	}
+	student, err := a.findUser(*studentRef)
+	if err != nil {
+		return err
+	}
+
+	link, err := a.admin.CreateParentLink(parent.ID, student.ID)
+	if err != nil {
+		return err
+	}
+	a.record(audit.Entry{Action: "parent_student.link", TargetType: "parent_student", TargetID: link.ID.String(), After: link})
+
+	return a.out.print(link,
+		[]string{"LINK ID", "PARENT", "STUDENT"},
+		[][]string{{link.ID.String(), parent.Name, student.Name + " (" + student.Class + ")"}},
+	)
+}
+
+func runAssignGuruWali(a *app, args []string) error {
+	fs := a.newFlagSet("assign-guruwali", "-teacher ID|USERNAME -student ID|USERNAME|NIS")
+	teacherRef := fs.String("teacher", "", "guru wali account (required)")
+	studentRef := fs.String("student", "", "student account (required)")
+	fs.Parse(args)
+	if *teacherRef == "" || *studentRef == "" {
+		fs.Usage()
+		return errors.New("-teacher and -student are required")
+	}
+
+	teacher, err := a.findUser(*teacherRef)
+	if err != nil {
+		return err
+	}
+	student, err := a.findUser(*studentRef)
+	if err != nil {
+		return err
+	}
+
+	assignment, err := a.admin.CreateGuruWaliAssignment(teacher.ID, student.ID)
+	if err != nil {
+		return err
+	}
+	a.record(audit.Entry{Action: "guruwali.assign", TargetType: "guruwali_assignment", TargetID: assignment.ID.String(), After: assignment})
+
+	return a.out.print(assignment,
+		[]string{"ASSIGNMENT ID", "GURU WALI", "STUDENT"},
+		[][]string{{assignment.ID.String(), teacher.Name, student.Name + " (" + student.Class + ")"}},
+	)
+}
+
+func runImportSupabase(a *app, args []string) error {
+	fs := a.newFlagSet("import-supabase", "(-source postgres://... | -dump data.sql) [-dry-run]")
+	source := fs.String("source", "", "connection string of the Supabase/Postgres database")
+	dump := fs.String("dump", "", "plain-text data dump with COPY blocks (pg_dump --data-only)")
+	dryRun := fs.Bool("dry-run", false, "import and reconcile, then roll everything back")
+	fs.Parse(args)
+
+	var src legacy.Source
+	switch {
+	case *source != "" && *dump != "":
+		fs.Usage()
+		return errors.New("use either -source or -dump")
+	case *source != "":
+		s, err := legacy.OpenDB(*source)
+		if err != nil {
+			return err
+		}
+		src = s
+	case *dump != "":
+		f, err := os.Open(*dump)
+		if err != nil {
+			return err
+		}
+		s, err := legacy.ReadDump(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		src = s
+	default:
+		fs.Usage()
+		return errors.New("-source or -dump is required")
+	}
+
+	report, err := legacy.Import(a.db, src, legacy.Options{DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	summary := make(map[string]interface{}, len(report.Entities))
+	rows := make([][]string, 0, len(report.Entities))
+	for _, e := range report.Entities {
+		summary[e.Entity] = e.Imported
+		status := "ok"
+		if e.Skipped > 0 || e.Target != int64(e.Imported) {
+			status = "check"
+		}
+		rows = append(rows, []string{
+			e.Entity, strconv.Itoa(e.Source), strconv.Itoa(e.Imported), strconv.Itoa(e.Skipped),
+			strconv.FormatInt(e.Target, 10), status,
+		})
+	}
+	if !*dryRun {
+		a.record(audit.Entry{
+			Action:     "legacy.import",
+			TargetType: "import",
+			Details:    map[string]interface{}{"imported": summary, "issues": len(report.Issues)},
+		})
+	}
+
+	for _, issue := range report.Issues {
+		log.Println(issue)
+	}
+	if err := a.out.print(report, []string{"ENTITY", "SOURCE", "IMPORTED", "SKIPPED", "TARGET", "STATUS"}, rows); err != nil {
+		return err
+	}
+	if *dryRun {
+		a.out.message("\nDry run: nothing was saved")
+	}
+	return nil
+}
+
+func runMigrate(a *app, args []string) error {
+	fs := a.newFlagSet("migrate", "up | down [steps] | status")
+	fs.Parse(args)
+
+	sub := "up"
+	if fs.NArg() > 0 {
+		sub = fs.Arg(0)
+	}
+
+	migrationRows := func(migrations []database.Migration) [][]string {
+		rows := make([][]string, 0, len(migrations))
+		for _, m := range migrations {
+			rows = append(rows, []string{fmt.Sprintf("%03d", m.Version), m.Name})
+		}
+		return rows
+	}
+
+	switch sub {
+	case "up":
+		applied, err := database.MigrateUp(a.db)
+		if err != nil {
+			return err
+		}
+		return a.out.print(migrationNames(applied), []string{"APPLIED", "NAME"}, migrationRows(applied))
+
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			n, err := strconv.Atoi(fs.Arg(1))
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", fs.Arg(1))
+			}
+			steps = n
+		}
+		reverted, err := database.MigrateDown(a.db, steps)
+		if err != nil {
+			return err
+		}
+		return a.out.print(migrationNames(reverted), []string{"REVERTED", "NAME"}, migrationRows(reverted))
+
+	case "status":
+		statuses, err := database.GetMigrationStatus(a.db)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(statuses))
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			if !s.Known {
+				applied += " (unknown to this binary)"
+			}
+			rows = append(rows, []string{fmt.Sprintf("%03d", s.Version), s.Name, applied})
+		}
+		return a.out.print(statuses, []string{"VERSION", "NAME", "APPLIED AT"}, rows)
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command %q", sub)
+	}
+}
+
+// migrationNames lists migrations as NNN_name for JSON output
+func migrationNames(migrations []database.Migration) []string {
+	names := make([]string, 0, len(migrations))
+	for _, m := range migrations {
+		names = append(names, fmt.Sprintf("%03d_%s", m.Version, m.Name))
+	}
+	return names
+}
+
+type countRow struct {
+	Role   string `json:"role,omitempty"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+type stats struct {
+	Users             []countRow `json:"users"`
+	Activities        []countRow `json:"activities"`
+	ActivitiesToday   int64      `json:"activities_today"`
+	ActiveSessions    int64      `json:"active_sessions"`
+	PendingMigrations int        `json:"pending_migrations"`
+}
+
+func runStats(a *app, args []string) error {
+	fs := a.newFlagSet("stats", "")
+	fs.Parse(args)
+
+	var s stats
+	if err := a.db.Model(&models.UserProfile{}).
+		Select("role, status, COUNT(*) AS count").
+		Group("role, status").Order("role, status").
+		Scan(&s.Users).Error; err != nil {
+		return err
+	}
+	if err := a.db.Model(&models.Activity{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").Order("status").
+		Scan(&s.Activities).Error; err != nil {
+		return err
+	}
+	if err := a.db.Model(&models.Activity{}).
+		Where("date = ?", time.Now().Format("2006-01-02")).
+		Count(&s.ActivitiesToday).Error; err != nil {
+		return err
+	}
+	if err := a.db.Model(&models.UserSession{}).
+		Where("revoked_at IS NULL").
+		Count(&s.ActiveSessions).Error; err != nil {
+		return err
+	}
+
+	statuses, err := database.GetMigrationStatus(a.db)
+	if err != nil {
+		return err
+	}
+	for _, m := range statuses {
+		if m.AppliedAt == nil {
+			s.PendingMigrations++
+		}
+	}
+
+	rows := [][]string{}
+	for _, u := range s.Users {
+		rows = append(rows, []string{"users", u.Role + " / " + u.Status, strconv.FormatInt(u.Count, 10)})
+	}
+	for _, act := range s.Activities {
+		rows = append(rows, []string{"activities", act.Status, strconv.FormatInt(act.Count, 10)})
+	}
+	rows = append(rows,
+		[]string{"activities", "today", strconv.FormatInt(s.ActivitiesToday, 10)},
+		[]string{"sessions", "active", strconv.FormatInt(s.ActiveSessions, 10)},
+		[]string{"migrations", "pending", strconv.Itoa(s.PendingMigrations)},
+	)
+	return a.out.print(s, []string{"GROUP", "KEY", "COUNT"}, rows)
+}
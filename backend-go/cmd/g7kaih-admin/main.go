@@ -0,0 +1,130 @@
+// Command g7kaih-admin runs operational tasks against the G7KAIH database
+// with the same configuration and code as the API server: creating and
+// importing users, resetting login codes, linking parents and guru wali,
+// importing the legacy Supabase data, migrations and stats.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/config"
+	"github.com/FirstTirr/G7KAIH-GO/internal/database"
+	"github.com/FirstTirr/G7KAIH-GO/internal/handlers"
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// app is what every command runs with
+type app struct {
+	db    *gorm.DB
+	admin *handlers.AdminHandler
+	audit *audit.Logger
+	out   *output
+	// command is the command line, stored as the path of audit entries
+	command string
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+var commands = []command{
+	{"create-user", "Create a user and print the generated username and PIN", runCreateUser},
+	{"import-users", "Create users from a CSV file (name,class,role[,nis])", runImportUsers},
+	{"reset-token", "Give users a new PIN and sign them out everywhere", runResetToken},
+	{"link-parent", "Link a parent to a student", runLinkParent},
+	{"assign-guruwali", "Make a teacher guru wali of a student", runAssignGuruWali},
+	{"import-supabase", "Import users, kegiatan and activities from the legacy Supabase schema", runImportSupabase},
+	{"migrate", "Apply, revert or list schema migrations (up | down [steps] | status)", runMigrate},
+	{"stats", "Print user, activity and session counts", runStats},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: g7kaih-admin [-o table|json] <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run g7kaih-admin <command> -h for the flags of a command.")
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	format := flag.String("o", "table", "output format: table or json")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flag.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	out, err := newOutput(*format, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load environment variables
+	_ = godotenv.Load()
+
+	cfg := config.Load()
+	db, err := database.New(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	// SQL logging is for the server; here it would bury the output
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	a := &app{
+		db:      db,
+		admin:   handlers.NewAdminHandler(db, auth.NewSessionStore(db), auth.NewPermissionStore(db)),
+		audit:   audit.New(db),
+		out:     out,
+		command: "g7kaih-admin " + strings.Join(flag.Args(), " "),
+	}
+	if err := cmd.run(a, flag.Args()[1:]); err != nil {
+		log.Fatalf("%s: %v", cmd.name, err)
+	}
+}
+
+// newFlagSet returns the flag set of a command. Commands also accept -o so
+// it can follow the command name.
+func (a *app) newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: g7kaih-admin %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	fs.Func("o", "output format: table or json", a.out.setFormat)
+	return fs
+}
+
+// record writes audit entries for a change; failing to audit is reported
+// but does not undo the change
+func (a *app) record(entries ...audit.Entry) {
+	if err := a.audit.Write(a.command, entries...); err != nil {
+		log.Printf("Warning: failed to write audit log: %v", err)
+	}
+}
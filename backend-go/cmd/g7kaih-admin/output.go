@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// output prints command results either as an aligned table or as JSON
+type output struct {
+	format string
+	w      io.Writer
+}
+
+func newOutput(format string, w io.Writer) (*output, error) {
+	o := &output{w: w}
+	if err := o.setFormat(format); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *output) setFormat(format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (table or json)", format)
+	}
+	o.format = format
+	return nil
+}
+
+// print writes value as JSON, or the header and rows as a table
+func (o *output) print(value interface{}, header []string, rows [][]string) error {
+	if o.format == "json" {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// message prints a line in table mode only; in JSON mode stdout carries
+// nothing but the result
+func (o *output) message(format string, args ...interface{}) {
+	if o.format == "table" {
+		fmt.Fprintf(o.w, format+"\n", args...)
+	}
+}
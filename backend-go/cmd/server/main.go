@@ -3,7 +3,9 @@ package main
 import (
 	"log"
 	"os"
+	_ "time/tzdata" // SCHOOL_TIMEZONE must resolve in images without zoneinfo
 
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
 	"github.com/FirstTirr/G7KAIH-GO/internal/config"
 	"github.com/FirstTirr/G7KAIH-GO/internal/database"
 	"github.com/FirstTirr/G7KAIH-GO/internal/router"
@@ -45,11 +47,22 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// `server migrate ...` only manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Run migrations
 	if err := database.Migrate(db); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Purge old audit log entries in the background
+	audit.New(db).StartRetention(cfg.Audit.Retention)
+
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -0,0 +1,170 @@
+// Package audit writes the append-only audit log of administrative and
+// review actions. Handlers describe what they changed with Record; the
+// middleware stores those entries once the request succeeded, together with
+// the actor, IP address and request ID.
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const entriesKey = "audit_entries"
+
+// Entry describes one change made by a request. Before and After are
+// snapshots of the target (nil for creations and deletions) that are stored
+// as a diff; Details is stored as is.
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+	Details    interface{}
+}
+
+// Record adds an entry for the current request. Nothing is written if the
+// request fails.
+func Record(c *gin.Context, entry Entry) {
+	entries, _ := c.Get(entriesKey)
+	list, _ := entries.([]Entry)
+	c.Set(entriesKey, append(list, entry))
+}
+
+type Logger struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Logger {
+	return &Logger{db: db}
+}
+
+// Middleware logs every successful write request. Requests whose handler
+// recorded nothing get an entry named after the route, with the :id
+// parameter as target.
+func (l *Logger) Middleware() gin.HandlerFunc {
+	return l.handler(true)
+}
+
+// Collect only logs the entries handlers recorded, for routes where most
+// writes are routine (students posting activities) and only some are
+// reviews worth keeping
+func (l *Logger) Collect() gin.HandlerFunc {
+	return l.handler(false)
+}
+
+func (l *Logger) handler(everyWrite bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if isReadOnlyMethod(c.Request.Method) || c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		entries, _ := c.Get(entriesKey)
+		list, _ := entries.([]Entry)
+		if len(list) == 0 {
+			if !everyWrite {
+				return
+			}
+			list = []Entry{{Action: c.Request.Method + " " + c.FullPath(), TargetID: c.Param("id")}}
+		}
+
+		rows := make([]models.AuditLog, 0, len(list))
+		for _, entry := range list {
+			row, err := l.row(c, entry)
+			if err != nil {
+				log.Printf("Warning: failed to build audit entry %s: %v", entry.Action, err)
+				continue
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) == 0 {
+			return
+		}
+		if err := l.db.Create(&rows).Error; err != nil {
+			log.Printf("Warning: failed to write audit log: %v", err)
+		}
+	}
+}
+
+// Write stores entries for changes made outside an HTTP request, such as by
+// the admin CLI. They have no actor; the method is CLI and the path is the
+// command that ran.
+func (l *Logger) Write(command string, entries ...Entry) error {
+	rows := make([]models.AuditLog, 0, len(entries))
+	for _, entry := range entries {
+		row := models.AuditLog{Method: "CLI", Path: command}
+		if err := fillRow(&row, entry); err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return l.db.Create(&rows).Error
+}
+
+func (l *Logger) row(c *gin.Context, entry Entry) (models.AuditLog, error) {
+	row := models.AuditLog{
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		StatusCode: c.Writer.Status(),
+		IPAddress:  optional(c.ClientIP()),
+		UserAgent:  optional(c.Request.UserAgent()),
+		RequestID:  optional(c.GetString("request_id")),
+	}
+	if actorID, err := middleware.GetUserID(c); err == nil {
+		row.ActorID = &actorID
+	}
+	return row, fillRow(&row, entry)
+}
+
+// fillRow copies the entry into the row, storing Before/After as a diff
+func fillRow(row *models.AuditLog, entry Entry) error {
+	row.Action = entry.Action
+	row.TargetType = optional(entry.TargetType)
+	row.TargetID = optional(entry.TargetID)
+
+	if entry.Before != nil || entry.After != nil {
+		changes, err := Diff(entry.Before, entry.After)
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			encoded, err := json.Marshal(changes)
+			if err != nil {
+				return err
+			}
+			row.Changes = optional(string(encoded))
+		}
+	}
+
+	if entry.Details != nil {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		row.Details = optional(string(encoded))
+	}
+
+	return nil
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Change is the value of one field before and after a request
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// ignoredFields change on every write and say nothing about it
+var ignoredFields = map[string]bool{"updated_at": true}
+
+// Diff compares two snapshots field by field through their JSON form, so
+// fields hidden from JSON (hashes, secrets) never reach the log. Either side
+// may be nil: a creation lists every field with a nil before, a deletion
+// every field with a nil after.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for name, value := range b {
+		if ignoredFields[name] {
+			continue
+		}
+		if other, ok := a[name]; !ok || !reflect.DeepEqual(value, other) {
+			changes[name] = Change{Before: value, After: a[name]}
+		}
+	}
+	for name, value := range a {
+		if ignoredFields[name] {
+			continue
+		}
+		if _, ok := b[name]; !ok {
+			changes[name] = Change{After: value}
+		}
+	}
+	return changes, nil
+}
+
+// fields turns a snapshot into its top-level JSON fields
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return map[string]interface{}{}, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return map[string]interface{}{}, nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &out); err != nil {
+		// not an object: compare the value as a whole
+		var value interface{}
+		if err := json.Unmarshal(encoded, &value); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"value": value}, nil
+	}
+	return out, nil
+}
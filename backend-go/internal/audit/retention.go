@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"log"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"gorm.io/gorm"
+)
+
+const retentionInterval = 24 * time.Hour
+
+// Purge deletes entries created before the cutoff. The append-only trigger
+// on audit_logs only lets deletes through in a transaction that sets
+// audit.purge.
+func (l *Logger) Purge(before time.Time) (int64, error) {
+	var deleted int64
+	err := l.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config('audit.purge', 'on', true)").Error; err != nil {
+			return err
+		}
+		result := tx.Where("created_at < ?", before).Delete(&models.AuditLog{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// StartRetention purges entries older than retention now and then once a
+// day in the background. A retention of zero keeps entries forever.
+func (l *Logger) StartRetention(retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			deleted, err := l.Purge(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Warning: failed to purge audit log: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Purged %d audit log entries older than %s", deleted, retention)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImpersonationStore keeps the audit trail of admins viewing the app as
+// another user and decides whether an impersonation token is still valid
+type ImpersonationStore struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func NewImpersonationStore(db *gorm.DB, ttl time.Duration) *ImpersonationStore {
+	return &ImpersonationStore{db: db, ttl: ttl}
+}
+
+// TTL is how long an impersonation lasts
+func (s *ImpersonationStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Start records that the actor, logged in with actorSessionID, is about to
+// view the app as the target
+func (s *ImpersonationStore) Start(actorID, actorSessionID, targetID uuid.UUID, reason, userAgent, ip string) (*models.ImpersonationSession, error) {
+	now := time.Now()
+	session := &models.ImpersonationSession{
+		ID:             uuid.New(),
+		ActorID:        actorID,
+		ActorSessionID: actorSessionID,
+		TargetID:       targetID,
+		Reason:         reason,
+		ExpiresAt:      now.Add(s.ttl),
+		CreatedAt:      now,
+	}
+	if userAgent != "" {
+		session.UserAgent = &userAgent
+	}
+	if ip != "" {
+		session.IPAddress = &ip
+	}
+
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Validate returns the target's profile for an impersonation token. It fails
+// with ErrSessionRevoked once the impersonation ended or expired, the admin's
+// own session was revoked, or the target has been deleted.
+func (s *ImpersonationStore) Validate(impersonationID, targetID, actorID uuid.UUID) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	err := s.db.Joins("JOIN impersonation_sessions ON impersonation_sessions.target_id = user_profiles.id").
+		Joins("JOIN user_sessions ON user_sessions.id = impersonation_sessions.actor_session_id").
+		Where("impersonation_sessions.id = ? AND user_profiles.id = ? AND impersonation_sessions.actor_id = ?", impersonationID, targetID, actorID).
+		Where("impersonation_sessions.ended_at IS NULL AND impersonation_sessions.expires_at > ?", time.Now()).
+		Where("user_sessions.revoked_at IS NULL").
+		First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// RecordBlockedWrite counts a write request refused during the impersonation
+func (s *ImpersonationStore) RecordBlockedWrite(impersonationID uuid.UUID) error {
+	return s.db.Model(&models.ImpersonationSession{}).
+		Where("id = ?", impersonationID).
+		Update("blocked_writes", gorm.Expr("blocked_writes + 1")).Error
+}
+
+// End stops an impersonation before it expires. It returns
+// gorm.ErrRecordNotFound when there is no such impersonation still running.
+func (s *ImpersonationStore) End(impersonationID uuid.UUID) error {
+	result := s.db.Model(&models.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL AND expires_at > ?", impersonationID, time.Now()).
+		Update("ended_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -8,21 +8,38 @@ import (
 	"github.com/google/uuid"
 )
 
+const purposeMFAChallenge = "mfa_challenge"
+
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("expired token")
 )
 
+// Claims of an access token. Role is the active role context and Roles every
+// role the user holds. MFA marks a session that passed the second login step.
+// Purpose is only set on single-purpose tokens (the MFA challenge), which are
+// never accepted as access tokens. Act names the admin behind an
+// impersonation token; SessionID is then the impersonation session.
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
-	Role   string    `json:"role"`
+	UserID    uuid.UUID `json:"user_id"`
+	SessionID uuid.UUID `json:"sid"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Roles     []string  `json:"roles,omitempty"`
+	MFA       bool      `json:"mfa,omitempty"`
+	Purpose   string    `json:"purpose,omitempty"`
+	Act       *Actor    `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Actor is the user acting on behalf of the token's subject
+type Actor struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
 type JWTService struct {
-	secretKey       []byte
-	expirationHours int
+	keys           *KeySet
+	accessTokenTTL time.Duration
 }
 
 type TokenPair struct {
@@ -31,33 +48,70 @@ type TokenPair struct {
 	ExpiresAt    int64  `json:"expires_at"`
 }
 
-func NewJWTService(secret string, expirationHours int) *JWTService {
+func NewJWTService(keys *KeySet, accessTokenTTL time.Duration) *JWTService {
 	return &JWTService{
-		secretKey:       []byte(secret),
-		expirationHours: expirationHours,
+		keys:           keys,
+		accessTokenTTL: accessTokenTTL,
 	}
 }
 
-func (s *JWTService) GenerateToken(userID uuid.UUID, role string) (string, int64, error) {
-	return s.generateToken(userID, role)
+// JWKS returns the public verification keys
+func (s *JWTService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+func (s *JWTService) GenerateToken(userID uuid.UUID, activeRole string, roles []string, sessionID uuid.UUID, mfa bool) (string, int64, error) {
+	return s.generateToken(&Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      activeRole,
+		Roles:     roles,
+		MFA:       mfa,
+	}, s.accessTokenTTL)
 }
 
-func (s *JWTService) generateToken(userID uuid.UUID, role string) (string, int64, error) {
-	expiresAt := time.Now().Add(time.Hour * time.Duration(s.expirationHours))
-
-	claims := &Claims{
-		UserID: userID,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// GenerateImpersonationToken issues a token for the target user that carries
+// the admin as actor. It has no refresh token and lives for ttl.
+func (s *JWTService) GenerateImpersonationToken(targetID uuid.UUID, activeRole string, roles []string, impersonationID, actorID uuid.UUID, ttl time.Duration) (string, int64, error) {
+	return s.generateToken(&Claims{
+		UserID:    targetID,
+		SessionID: impersonationID,
+		Role:      activeRole,
+		Roles:     roles,
+		Act:       &Actor{UserID: actorID},
+	}, ttl)
+}
+
+// GenerateMFAChallenge issues the short-lived token a client trades, together
+// with a TOTP or recovery code, for real tokens at /auth/login/2fa
+func (s *JWTService) GenerateMFAChallenge(userID uuid.UUID, ttl time.Duration) (string, int64, error) {
+	return s.generateToken(&Claims{
+		UserID:  userID,
+		Purpose: purposeMFAChallenge,
+	}, ttl)
+}
+
+// ValidateMFAChallenge parses a token from GenerateMFAChallenge
+func (s *JWTService) ValidateMFAChallenge(tokenString string) (uuid.UUID, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.Purpose != purposeMFAChallenge {
+		return uuid.Nil, ErrInvalidToken
 	}
+	return claims.UserID, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+func (s *JWTService) generateToken(claims *Claims, ttl time.Duration) (string, int64, error) {
+	expiresAt := time.Now().Add(ttl)
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+	}
 
-	tokenString, err := token.SignedString(s.secretKey)
+	tokenString, err := s.keys.sign(claims)
 	if err != nil {
 		return "", 0, err
 	}
@@ -65,13 +119,20 @@ func (s *JWTService) generateToken(userID uuid.UUID, role string) (string, int64
 	return tokenString, expiresAt.Unix(), nil
 }
 
+// ValidateToken parses an access token. Single-purpose tokens are rejected.
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return s.secretKey, nil
-	})
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *JWTService) parseToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keys.keyFunc)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -87,12 +148,3 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
-
-func (s *JWTService) RefreshAccessToken(refreshToken string) (string, int64, error) {
-	claims, err := s.ValidateToken(refreshToken)
-	if err != nil {
-		return "", 0, err
-	}
-
-	return s.generateToken(claims.UserID, claims.Role)
-}
\ No newline at end of file
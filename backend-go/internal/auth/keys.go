@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	privateKeySuffix = ".pem"
+	publicKeySuffix  = ".pub.pem"
+	hmacKeyID        = "hs256"
+)
+
+// signingKey is one key of a KeySet. Public keys are published in the JWKS;
+// the HMAC key never is.
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// KeySet holds the key that signs new tokens and every key whose tokens are
+// still accepted. To rotate, add a new key file, make it active and keep the
+// old one (or only its public half) until the last tokens it signed expire.
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// JWK is a public key in RFC 7517 form
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet loads the keys from cfg.KeysDir, or falls back to the HS256 secret
+func NewKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	if cfg.KeysDir == "" {
+		return NewHMACKeySet(cfg.Secret), nil
+	}
+	return LoadKeySet(cfg.KeysDir, cfg.ActiveKeyID)
+}
+
+// NewHMACKeySet signs with HS256 and a shared secret. Other services can only
+// verify these tokens if they know the secret, so it is meant for single
+// instance setups and development.
+func NewHMACKeySet(secret string) *KeySet {
+	key := &signingKey{
+		id:     hmacKeyID,
+		method: jwt.SigningMethodHS256,
+		sign:   []byte(secret),
+		verify: []byte(secret),
+	}
+	return &KeySet{active: key, keys: map[string]*signingKey{key.id: key}}
+}
+
+// LoadKeySet reads the PEM keys in dir. "<kid>.pem" holds a private key
+// (RSA for RS256, Ed25519 for EdDSA) and "<kid>.pub.pem" a public key that
+// only verifies. Tokens are signed with activeKeyID, or with the private key
+// whose kid sorts last when it is empty.
+func LoadKeySet(dir, activeKeyID string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT keys: %w", err)
+	}
+
+	set := &KeySet{keys: make(map[string]*signingKey)}
+	var privateIDs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, privateKeySuffix) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read JWT key %s: %w", name, err)
+		}
+
+		var key *signingKey
+		if strings.HasSuffix(name, publicKeySuffix) {
+			key, err = parsePublicKey(strings.TrimSuffix(name, publicKeySuffix), data)
+		} else {
+			key, err = parsePrivateKey(strings.TrimSuffix(name, privateKeySuffix), data)
+			if err == nil {
+				privateIDs = append(privateIDs, key.id)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse JWT key %s: %w", name, err)
+		}
+		if existing, ok := set.keys[key.id]; ok && existing.sign != nil {
+			continue // the private key already covers this kid
+		}
+		set.keys[key.id] = key
+	}
+
+	if activeKeyID == "" {
+		if len(privateIDs) == 0 {
+			return nil, fmt.Errorf("no private JWT key in %s", dir)
+		}
+		sort.Strings(privateIDs)
+		activeKeyID = privateIDs[len(privateIDs)-1]
+	}
+
+	active, ok := set.keys[activeKeyID]
+	if !ok || active.sign == nil {
+		return nil, fmt.Errorf("no private JWT key with kid %q in %s", activeKeyID, dir)
+	}
+	set.active = active
+
+	return set, nil
+}
+
+// JWKS returns the public keys other services use to verify tokens
+func (ks *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := ks.keys[id]
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.id,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return jwks
+}
+
+// sign signs the claims with the active key and sets the kid header
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.id
+	return token.SignedString(ks.active.sign)
+}
+
+// keyFunc picks the verification key by kid and refuses tokens whose
+// algorithm does not match that key. Tokens without a kid were issued before
+// key rotation existed and are only accepted by an HMAC key set.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = hmacKeyID
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok || token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.verify, nil
+}
+
+func parsePrivateKey(id string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	key, err := newVerifyKey(id, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.sign = parsed
+	return key, nil
+}
+
+func parsePublicKey(id string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newVerifyKey(id, parsed)
+}
+
+func newVerifyKey(id string, pub interface{}) (*signingKey, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &signingKey{id: id, method: jwt.SigningMethodRS256, verify: pub}, nil
+	case ed25519.PublicKey:
+		return &signingKey{id: id, method: jwt.SigningMethodEdDSA, verify: pub}, nil
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/config"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LockoutScopeIP          = "ip"
+	LockoutScopeFingerprint = "fingerprint"
+	LockoutScopeAccount     = "account"
+)
+
+// LoginSubject identifies where a login attempt comes from and, when known,
+// the account it is for. The IP and fingerprint come from the client; the
+// account counter is the one a client cannot reset by changing them. Logins
+// that name no account, like a bare legacy token, leave Account empty and
+// are only counted per IP and device.
+type LoginSubject struct {
+	IP          string
+	Fingerprint string
+	Account     string
+	UserAgent   string
+}
+
+// LoginGuard throttles login attempts per IP, per device fingerprint and per
+// account, and keeps a log of every attempt.
+type LoginGuard struct {
+	db  *gorm.DB
+	cfg config.LoginGuardConfig
+}
+
+func NewLoginGuard(db *gorm.DB, cfg config.LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{db: db, cfg: cfg}
+}
+
+// Check returns how long the subject has to wait before it may try again.
+// A zero duration means the attempt may proceed.
+func (g *LoginGuard) Check(s LoginSubject) (time.Duration, error) {
+	if !g.cfg.Enabled {
+		return 0, nil
+	}
+
+	var lockouts []models.LoginLockout
+	if err := g.db.Where("(scope = ? AND identifier = ?) OR (scope = ? AND identifier = ?) OR (scope = ? AND identifier = ?)",
+		LockoutScopeIP, s.IP, LockoutScopeFingerprint, s.Fingerprint, LockoutScopeAccount, s.Account).
+		Find(&lockouts).Error; err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, l := range lockouts {
+		if l.LockedUntil != nil && l.LockedUntil.After(now) {
+			if d := l.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordFailure logs a failed attempt and advances the failure counters
+func (g *LoginGuard) RecordFailure(s LoginSubject, reason string) error {
+	if err := g.logAttempt(s, nil, false, reason); err != nil {
+		return err
+	}
+	if !g.cfg.Enabled {
+		return nil
+	}
+
+	now := time.Now()
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		if err := g.bump(tx, LockoutScopeIP, s.IP, g.cfg.IPFreeAttempts, now); err != nil {
+			return err
+		}
+		if err := g.bump(tx, LockoutScopeFingerprint, s.Fingerprint, g.cfg.FingerprintFreeAttempts, now); err != nil {
+			return err
+		}
+		return g.bump(tx, LockoutScopeAccount, s.Account, g.cfg.AccountFreeAttempts, now)
+	})
+}
+
+// RecordBlocked logs an attempt that was rejected because of an active lockout.
+// Blocked attempts do not extend the lockout.
+func (g *LoginGuard) RecordBlocked(s LoginSubject) error {
+	return g.logAttempt(s, nil, false, "locked")
+}
+
+// RecordInactive logs a login with valid credentials to an account that is
+// not active. It does not count as a failure.
+func (g *LoginGuard) RecordInactive(s LoginSubject, userID uuid.UUID, status string) error {
+	return g.logAttempt(s, &userID, false, "account_"+status)
+}
+
+// RecordSuccess logs a successful login and clears the device and account
+// counters. The IP counter is left alone so a valid code cannot be used to
+// reset throttling for a whole network.
+func (g *LoginGuard) RecordSuccess(s LoginSubject, userID uuid.UUID) error {
+	if err := g.logAttempt(s, &userID, true, ""); err != nil {
+		return err
+	}
+	if !g.cfg.Enabled {
+		return nil
+	}
+
+	query := g.db.Where("scope = ? AND identifier = ?", LockoutScopeFingerprint, s.Fingerprint)
+	if s.Account != "" {
+		query = query.Or("scope = ? AND identifier = ?", LockoutScopeAccount, s.Account)
+	}
+	return query.Delete(&models.LoginLockout{}).Error
+}
+
+// ActiveLockouts lists every IP, device or account that is currently blocked
+func (g *LoginGuard) ActiveLockouts() ([]models.LoginLockout, error) {
+	var lockouts []models.LoginLockout
+	err := g.db.Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&lockouts).Error
+	return lockouts, err
+}
+
+// ClearLockout removes a lockout and resets its failure counter
+func (g *LoginGuard) ClearLockout(id uuid.UUID) error {
+	result := g.db.Where("id = ?", id).Delete(&models.LoginLockout{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (g *LoginGuard) bump(tx *gorm.DB, scope, identifier string, freeAttempts int, now time.Time) error {
+	if identifier == "" {
+		return nil
+	}
+
+	// Make sure the row exists so concurrent failures serialize on the row lock
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginLockout{
+		Scope:      scope,
+		Identifier: identifier,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}).Error; err != nil {
+		return err
+	}
+
+	var lockout models.LoginLockout
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND identifier = ?", scope, identifier).
+		First(&lockout).Error; err != nil {
+		return err
+	}
+
+	stillLocked := lockout.LockedUntil != nil && lockout.LockedUntil.After(now)
+	if lockout.LastFailureAt != nil && now.Sub(*lockout.LastFailureAt) > g.cfg.FailureWindow && !stillLocked {
+		lockout.FailureCount = 0
+	}
+
+	lockout.FailureCount++
+	lockout.LastFailureAt = &now
+	if delay := g.delayFor(lockout.FailureCount, freeAttempts); delay > 0 {
+		until := now.Add(delay)
+		lockout.LockedUntil = &until
+	}
+	lockout.UpdatedAt = now
+
+	return tx.Save(&lockout).Error
+}
+
+// delayFor doubles the wait for every failure past the free attempts and caps
+// it at the configured lockout duration.
+func (g *LoginGuard) delayFor(failures, freeAttempts int) time.Duration {
+	excess := failures - freeAttempts
+	if excess <= 0 {
+		return 0
+	}
+	if excess > 30 {
+		return g.cfg.LockoutDuration
+	}
+
+	delay := g.cfg.BackoffBase << (excess - 1)
+	if delay <= 0 || delay > g.cfg.LockoutDuration {
+		return g.cfg.LockoutDuration
+	}
+	return delay
+}
+
+func (g *LoginGuard) logAttempt(s LoginSubject, userID *uuid.UUID, success bool, reason string) error {
+	attempt := models.LoginAttempt{
+		IPAddress:     s.IP,
+		Fingerprint:   s.Fingerprint,
+		UserProfileID: userID,
+		Success:       success,
+		CreatedAt:     time.Now(),
+	}
+	if s.UserAgent != "" {
+		attempt.UserAgent = &s.UserAgent
+	}
+	if reason != "" {
+		attempt.Reason = &reason
+	}
+
+	if err := g.db.Create(&attempt).Error; err != nil {
+		return fmt.Errorf("failed to log login attempt: %w", err)
+	}
+	return nil
+}
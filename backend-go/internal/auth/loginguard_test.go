@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/config"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDB connects to TEST_DATABASE_URL, a database migrated with
+// `server migrate up`
+func testDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testGuard(db *gorm.DB) *LoginGuard {
+	return NewLoginGuard(db, config.LoginGuardConfig{
+		Enabled:                 true,
+		FailureWindow:           15 * time.Minute,
+		IPFreeAttempts:          20,
+		FingerprintFreeAttempts: 5,
+		AccountFreeAttempts:     3,
+		BackoffBase:             5 * time.Second,
+		LockoutDuration:         30 * time.Minute,
+	})
+}
+
+// A client that sends a new IP and device ID with every guess is still
+// locked out of the account it is guessing
+func TestLoginGuardAccountSurvivesRotation(t *testing.T) {
+	tx := testDB(t).Begin()
+	defer tx.Rollback()
+	guard := testGuard(tx)
+
+	account := "siswa-" + uuid.NewString()[:8]
+	rotated := func(i int) LoginSubject {
+		return LoginSubject{
+			IP:          fmt.Sprintf("203.0.113.%d", i),
+			Fingerprint: uuid.NewString(),
+			Account:     account,
+		}
+	}
+
+	for i := 1; i <= 3; i++ {
+		if wait, err := guard.Check(rotated(i)); err != nil || wait > 0 {
+			t.Fatalf("attempt %d: wait = %v, err = %v", i, wait, err)
+		}
+		if err := guard.RecordFailure(rotated(i), "invalid_credentials"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := guard.RecordFailure(rotated(4), "invalid_credentials"); err != nil {
+		t.Fatal(err)
+	}
+
+	wait, err := guard.Check(rotated(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 {
+		t.Fatal("a fresh IP and device ID reset the account counter")
+	}
+
+	other := rotated(6)
+	other.Account = "siswa-" + uuid.NewString()[:8]
+	if wait, err := guard.Check(other); err != nil || wait > 0 {
+		t.Errorf("another account is locked too: wait = %v, err = %v", wait, err)
+	}
+}
+
+func TestLoginGuardSuccessClearsAccount(t *testing.T) {
+	tx := testDB(t).Begin()
+	defer tx.Rollback()
+	guard := testGuard(tx)
+
+	profile := models.UserProfile{ID: uuid.New(), Name: "Guru", Role: "guru"}
+	if err := tx.Create(&profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	subject := LoginSubject{IP: "203.0.113.1", Fingerprint: uuid.NewString(), Account: "guru-" + uuid.NewString()[:8]}
+	for i := 0; i < 2; i++ {
+		if err := guard.RecordFailure(subject, "invalid_credentials"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := guard.RecordSuccess(subject, profile.ID); err != nil {
+		t.Fatal(err)
+	}
+	var remaining int64
+	if err := tx.Model(&models.LoginLockout{}).Where("scope = ? AND identifier = ?", LockoutScopeAccount, subject.Account).Count(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Error("a successful login kept the account counter")
+	}
+}
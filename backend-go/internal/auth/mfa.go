@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/config"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrMFANotEnrolled     = errors.New("two-factor authentication is not set up")
+	ErrMFAAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrInvalidMFACode     = errors.New("invalid two-factor code")
+	ErrMFAUnsupportedRole = errors.New("two-factor authentication is only available for staff accounts")
+)
+
+// MFAService handles TOTP enrollment, verification and recovery codes
+type MFAService struct {
+	db   *gorm.DB
+	cfg  config.MFAConfig
+	aead cipher.AEAD
+}
+
+// NewMFAService derives the secret encryption key from cfg.EncryptionKey, or
+// from fallbackKey (the JWT secret) when none is configured
+func NewMFAService(db *gorm.DB, cfg config.MFAConfig, fallbackKey string) (*MFAService, error) {
+	keyMaterial := cfg.EncryptionKey
+	if keyMaterial == "" {
+		keyMaterial = fallbackKey
+	}
+	key := sha256.Sum256([]byte(keyMaterial))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAService{db: db, cfg: cfg, aead: aead}, nil
+}
+
+// ChallengeTTL is how long a client has to enter the second factor
+func (s *MFAService) ChallengeTTL() time.Duration {
+	return s.cfg.ChallengeTTL
+}
+
+// MFAEnabled reports whether the user has finished TOTP enrollment
+func MFAEnabled(profile *models.UserProfile) bool {
+	return profile.TOTPEnabledAt != nil && profile.TOTPSecret != nil
+}
+
+// BeginEnrollment stores a new pending secret and returns it together with
+// the otpauth:// provisioning URI
+func (s *MFAService) BeginEnrollment(profile *models.UserProfile) (string, string, error) {
+	if !RoleRequiresPassword(profile.Role) {
+		return "", "", ErrMFAUnsupportedRole
+	}
+	if MFAEnabled(profile) {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := s.seal(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.db.Model(profile).Updates(map[string]interface{}{
+		"totp_secret":     sealed,
+		"totp_enabled_at": nil,
+		"totp_last_step":  nil,
+	}).Error; err != nil {
+		return "", "", err
+	}
+	profile.TOTPSecret = &sealed
+	profile.TOTPEnabledAt = nil
+
+	account := profile.Name
+	if profile.Username != nil {
+		account = *profile.Username
+	}
+	return secret, TOTPProvisioningURI(s.cfg.Issuer, account, secret), nil
+}
+
+// ConfirmEnrollment enables 2FA once the user proves the app generates valid
+// codes, and returns a fresh set of recovery codes
+func (s *MFAService) ConfirmEnrollment(profile *models.UserProfile, code string) ([]string, error) {
+	if profile.TOTPSecret == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if MFAEnabled(profile) {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.verifyTOTP(s.db, profile, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(profile).Update("totp_enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, profile.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify accepts a TOTP code or an unused recovery code. A TOTP code is only
+// accepted once.
+func (s *MFAService) Verify(profile *models.UserProfile, code string) error {
+	if !MFAEnabled(profile) {
+		return ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(strings.ReplaceAll(code, " ", "")) == totpDigits {
+		return s.verifyTOTP(s.db, profile, code)
+	}
+
+	result := s.db.Model(&models.RecoveryCode{}).
+		Where("user_profile_id = ? AND code_hash = ? AND used_at IS NULL", profile.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user
+func (s *MFAService) RegenerateRecoveryCodes(profile *models.UserProfile) ([]string, error) {
+	if !MFAEnabled(profile) {
+		return nil, ErrMFANotEnrolled
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, profile.ID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable removes the TOTP secret and recovery codes of the user
+func (s *MFAService) Disable(userID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserProfile{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":     nil,
+			"totp_enabled_at": nil,
+			"totp_last_step":  nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_profile_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// verifyTOTP checks the code and records its time step. The conditional
+// update makes a replayed code fail even under concurrent requests.
+func (s *MFAService) verifyTOTP(tx *gorm.DB, profile *models.UserProfile, code string) error {
+	secret, err := s.open(*profile.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	result := tx.Model(&models.UserProfile{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", profile.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	profile.TOTPLastStep = &step
+	return nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_profile_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			ID:            uuid.New(),
+			UserProfileID: userID,
+			CodeHash:      hashRecoveryCode(code),
+			CreatedAt:     time.Now(),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *MFAService) seal(plaintext string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *MFAService) open(sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < s.aead.NonceSize() {
+		return "", errors.New("malformed TOTP secret")
+	}
+	nonce, ciphertext := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// randomRecoveryCode returns a code like "k7qx-m2pa-9tdw"
+func randomRecoveryCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < 12; i++ {
+		if i > 0 && i%4 == 0 {
+			sb.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(recoveryCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// hashRecoveryCode ignores case and separators so codes can be typed loosely
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return utils.HashToken(code)
+}
@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,3 +22,58 @@ func HashPassword(password string) (string, error) {
 func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+const (
+	MinPasswordLength = 10
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password must be at least 10 characters")
+	ErrPasswordTooLong  = errors.New("password must be at most 72 bytes")
+	ErrPasswordTooWeak  = errors.New("password must contain both letters and digits")
+)
+
+// ValidatePasswordPolicy checks the minimum password policy for staff accounts
+func ValidatePasswordPolicy(password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > maxPasswordLength {
+		return ErrPasswordTooLong
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return ErrPasswordTooWeak
+	}
+
+	return nil
+}
+
+// RoleRequiresPassword reports whether a role must log in with a password.
+// Every role except students and parents is staff, including custom roles.
+func RoleRequiresPassword(role string) bool {
+	switch role {
+	case "siswa", "orangtua":
+		return false
+	}
+	return true
+}
+
+// PasswordChangeRequired reports whether the user has to set a new password
+// before using anything else
+func PasswordChangeRequired(profile *models.UserProfile) bool {
+	if !RoleRequiresPassword(profile.Role) {
+		return false
+	}
+	return profile.PasswordHash == nil || profile.PasswordMustChange
+}
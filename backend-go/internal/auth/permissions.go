@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"gorm.io/gorm"
+)
+
+// AdminRole is the built-in role that always has every permission, so an
+// admin can never lock themselves out by editing roles
+const AdminRole = "admin"
+
+// Permissions checked by the API. Roles are mapped to these in the
+// role_permissions table.
+const (
+	PermActivityReview       = "activity.review"
+	PermActivityDeleteAny    = "activity.delete.any"
+	PermSubmissionOverride   = "submission.override"
+	PermCommentModerate      = "comment.moderate"
+	PermReportViewClass      = "report.view.class"
+	PermReportViewSupervised = "report.view.supervised"
+	PermStudentViewAll       = "student.view.all"
+	PermChildView            = "child.view"
+	PermCategoryManage       = "category.manage"
+	PermKegiatanManage       = "kegiatan.manage"
+	PermUserManage           = "user.manage"
+	PermUserImpersonate      = "user.impersonate"
+	PermSecurityManage       = "security.manage"
+	PermSettingsManage       = "settings.manage"
+	PermRoleManage           = "role.manage"
+	PermAuditView            = "audit.view"
+)
+
+const permissionCacheTTL = 30 * time.Second
+
+// Permission describes one entry of the registry. Administrative permissions
+// require a two-factor session when ADMIN_REQUIRE_MFA is on.
+type Permission struct {
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Administrative bool   `json:"administrative"`
+}
+
+var registry = []Permission{
+	{Name: PermActivityReview, Description: "Review activities of students one can see: change their status and edit them"},
+	{Name: PermActivityDeleteAny, Description: "Delete activities of other users"},
+	{Name: PermSubmissionOverride, Description: "Submit, edit and delete one's own activities outside the submission schedule"},
+	{Name: PermCommentModerate, Description: "Edit and delete comments of other users"},
+	{Name: PermReportViewClass, Description: "View students, activities and reports of the classes one teaches"},
+	{Name: PermReportViewSupervised, Description: "View students one supervises as guru wali"},
+	{Name: PermStudentViewAll, Description: "View every student regardless of class"},
+	{Name: PermChildView, Description: "View linked children and their activities"},
+	{Name: PermCategoryManage, Description: "Create, edit and delete categories", Administrative: true},
+	{Name: PermKegiatanManage, Description: "Create, edit and delete kegiatan", Administrative: true},
+	{Name: PermUserManage, Description: "Manage users, credentials, invites and class assignments", Administrative: true},
+	{Name: PermUserImpersonate, Description: "View the app as another user with a read-only token", Administrative: true},
+	{Name: PermSecurityManage, Description: "Manage sessions, 2FA resets and login lockouts", Administrative: true},
+	{Name: PermSettingsManage, Description: "Change school settings such as the submission window", Administrative: true},
+	{Name: PermRoleManage, Description: "Create roles and edit their permissions", Administrative: true},
+	{Name: PermAuditView, Description: "Read the audit log", Administrative: true},
+}
+
+// Permissions returns the registry
+func Permissions() []Permission {
+	return append([]Permission(nil), registry...)
+}
+
+// LookupPermission finds a permission in the registry
+func LookupPermission(name string) (Permission, bool) {
+	for _, p := range registry {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Permission{}, false
+}
+
+// PermissionSet is the permissions granted to a role
+type PermissionSet map[string]struct{}
+
+// Has reports whether the set grants the permission
+func (s PermissionSet) Has(permission string) bool {
+	_, ok := s[permission]
+	return ok
+}
+
+// Names returns the permissions sorted by name
+func (s PermissionSet) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type cachedPermissions struct {
+	set       PermissionSet
+	expiresAt time.Time
+}
+
+// PermissionStore resolves the permissions of a role. Results are cached for
+// a short time; Invalidate drops the cache after an edit on this instance.
+type PermissionStore struct {
+	db    *gorm.DB
+	mu    sync.RWMutex
+	cache map[string]cachedPermissions
+}
+
+func NewPermissionStore(db *gorm.DB) *PermissionStore {
+	return &PermissionStore{db: db, cache: make(map[string]cachedPermissions)}
+}
+
+// ForRole returns the permissions of a role. Unknown roles have none.
+func (s *PermissionStore) ForRole(role string) (PermissionSet, error) {
+	if role == AdminRole {
+		set := make(PermissionSet, len(registry))
+		for _, p := range registry {
+			set[p.Name] = struct{}{}
+		}
+		return set, nil
+	}
+
+	s.mu.RLock()
+	cached, ok := s.cache[role]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.set, nil
+	}
+
+	var names []string
+	if err := s.db.Model(&models.RolePermission{}).
+		Where("role_name = ?", role).
+		Pluck("permission", &names).Error; err != nil {
+		return nil, err
+	}
+
+	set := make(PermissionSet, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+
+	s.mu.Lock()
+	s.cache[role] = cachedPermissions{set: set, expiresAt: time.Now().Add(permissionCacheTTL)}
+	s.mu.Unlock()
+
+	return set, nil
+}
+
+// ForRoles returns the permissions granted by any of the roles
+func (s *PermissionStore) ForRoles(roles []string) (PermissionSet, error) {
+	set := make(PermissionSet)
+	for _, role := range roles {
+		granted, err := s.ForRole(role)
+		if err != nil {
+			return nil, err
+		}
+		for name := range granted {
+			set[name] = struct{}{}
+		}
+	}
+	return set, nil
+}
+
+// HeldRoles returns the primary role of the profile followed by its
+// additional roles
+func (s *PermissionStore) HeldRoles(profile *models.UserProfile) ([]string, error) {
+	var extra []string
+	if err := s.db.Model(&models.UserRole{}).
+		Where("user_profile_id = ? AND role_name <> ?", profile.ID, profile.Role).
+		Order("role_name ASC").
+		Pluck("role_name", &extra).Error; err != nil {
+		return nil, err
+	}
+	return append([]string{profile.Role}, extra...), nil
+}
+
+// ActiveRole picks the role context for a request: the requested role if the
+// user still holds it, otherwise the primary role
+func ActiveRole(requested string, held []string) string {
+	for _, role := range held {
+		if role == requested {
+			return role
+		}
+	}
+	return held[0]
+}
+
+// Invalidate forgets every cached role
+func (s *PermissionStore) Invalidate() {
+	s.mu.Lock()
+	s.cache = make(map[string]cachedPermissions)
+	s.mu.Unlock()
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/config"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const qrPayloadPrefix = "g7kaih-login:"
+
+var ErrInvalidQRToken = errors.New("invalid or expired QR login code")
+
+// QRLoginStore issues the one-time tokens printed on QR login cards. Only the
+// SHA-256 hash of a token is persisted.
+type QRLoginStore struct {
+	db  *gorm.DB
+	cfg config.QRLoginConfig
+}
+
+func NewQRLoginStore(db *gorm.DB, cfg config.QRLoginConfig) *QRLoginStore {
+	return &QRLoginStore{db: db, cfg: cfg}
+}
+
+// WithTx returns a store that runs its queries in the given transaction
+func (s *QRLoginStore) WithTx(tx *gorm.DB) *QRLoginStore {
+	return &QRLoginStore{db: tx, cfg: s.cfg}
+}
+
+// Issue creates a new QR token for the user, revokes their unused older
+// tokens, and returns the payload to encode in the QR code
+func (s *QRLoginStore) Issue(userID uuid.UUID, issuedBy *uuid.UUID) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.QRLoginToken{}).
+			Where("user_profile_id = ? AND used_at IS NULL AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.QRLoginToken{
+			ID:            uuid.New(),
+			UserProfileID: userID,
+			TokenHash:     utils.HashToken(raw),
+			ExpiresAt:     now.Add(s.cfg.TokenTTL),
+			CreatedBy:     issuedBy,
+			CreatedAt:     now,
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return s.payload(raw), nil
+}
+
+// Consume marks the token in a scanned payload as used and returns its owner.
+// The check and the update are a single statement, so a card cannot be used
+// twice even by concurrent requests.
+func (s *QRLoginStore) Consume(payload string) (uuid.UUID, error) {
+	raw := parseQRPayload(payload)
+	if raw == "" {
+		return uuid.Nil, ErrInvalidQRToken
+	}
+
+	var token models.QRLoginToken
+	result := s.db.Model(&token).Clauses(clause.Returning{}).
+		Where("token_hash = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?", utils.HashToken(raw), time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return uuid.Nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return uuid.Nil, ErrInvalidQRToken
+	}
+
+	return token.UserProfileID, nil
+}
+
+func (s *QRLoginStore) payload(raw string) string {
+	if s.cfg.LoginURL == "" {
+		return qrPayloadPrefix + raw
+	}
+
+	sep := "?"
+	if strings.Contains(s.cfg.LoginURL, "?") {
+		sep = "&"
+	}
+	return s.cfg.LoginURL + sep + "qr=" + raw
+}
+
+// parseQRPayload accepts the plain payload, the login link, or the bare token
+func parseQRPayload(payload string) string {
+	payload = strings.TrimSpace(payload)
+	if strings.HasPrefix(payload, qrPayloadPrefix) {
+		return strings.TrimPrefix(payload, qrPayloadPrefix)
+	}
+	if u, err := url.Parse(payload); err == nil && u.Scheme != "" {
+		return u.Query().Get("qr")
+	}
+	return payload
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshTokenStore issues and rotates opaque refresh tokens. Only the SHA-256
+// hash of a token is persisted.
+type RefreshTokenStore struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+func NewRefreshTokenStore(db *gorm.DB, ttl time.Duration) *RefreshTokenStore {
+	return &RefreshTokenStore{db: db, ttl: ttl}
+}
+
+// Issue creates the first refresh token of a session and returns the raw token
+func (s *RefreshTokenStore) Issue(userID, sessionID uuid.UUID) (string, error) {
+	raw, _, err := s.create(s.db, userID, sessionID)
+	return raw, err
+}
+
+// Rotate exchanges a refresh token for a new one in the same session. A token
+// can be used once; presenting it again revokes the whole session, since
+// either the client or an attacker holds a stolen copy.
+func (s *RefreshTokenStore) Rotate(raw string) (*models.RefreshToken, string, error) {
+	var (
+		issued *models.RefreshToken
+		next   string
+		reused bool
+	)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(raw)).
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		now := time.Now()
+		if current.UsedAt != nil {
+			reused = true
+			return revokeSessions(tx, "id = ?", current.SessionID)
+		}
+		if current.RevokedAt != nil || now.After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		raw, replacement, err := s.create(tx, current.UserProfileID, current.SessionID)
+		if err != nil {
+			return err
+		}
+
+		current.UsedAt = &now
+		current.ReplacedByID = &replacement.ID
+		if err := tx.Save(&current).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.UserSession{}).
+			Where("id = ?", current.SessionID).
+			Update("last_used_at", now).Error; err != nil {
+			return err
+		}
+
+		issued = replacement
+		next = raw
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	return issued, next, nil
+}
+
+func (s *RefreshTokenStore) create(tx *gorm.DB, userID, sessionID uuid.UUID) (string, *models.RefreshToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+
+	token := &models.RefreshToken{
+		ID:            uuid.New(),
+		UserProfileID: userID,
+		SessionID:     sessionID,
+		TokenHash:     utils.HashToken(raw),
+		ExpiresAt:     time.Now().Add(s.ttl),
+		CreatedAt:     time.Now(),
+	}
+	if err := tx.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+
+	return raw, token, nil
+}
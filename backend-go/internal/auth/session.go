@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrSessionRevoked = errors.New("session revoked")
+
+// SessionStore tracks logged-in devices. Every access token names its session,
+// so revoking a session (or every session of a user) logs the device out
+// without waiting for the token to expire.
+type SessionStore struct {
+	db *gorm.DB
+}
+
+func NewSessionStore(db *gorm.DB) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+// WithTx returns a store that runs its queries in the given transaction
+func (s *SessionStore) WithTx(tx *gorm.DB) *SessionStore {
+	return &SessionStore{db: tx}
+}
+
+// Create starts a new session for the user. mfa records whether the login
+// passed the second factor.
+func (s *SessionStore) Create(userID uuid.UUID, userAgent, ip string, mfa bool) (*models.UserSession, error) {
+	now := time.Now()
+	session := &models.UserSession{
+		ID:            uuid.New(),
+		UserProfileID: userID,
+		MFA:           mfa,
+		LastUsedAt:    &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if userAgent != "" {
+		session.UserAgent = &userAgent
+	}
+	if ip != "" {
+		session.IPAddress = &ip
+	}
+
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Validate returns the current profile behind a session. It fails with
+// ErrSessionRevoked when the session was revoked, belongs to someone else,
+// or the user has been deleted.
+func (s *SessionStore) Validate(sessionID, userID uuid.UUID) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	err := s.db.Joins("JOIN user_sessions ON user_sessions.user_profile_id = user_profiles.id").
+		Where("user_sessions.id = ? AND user_profiles.id = ? AND user_sessions.revoked_at IS NULL", sessionID, userID).
+		First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Get returns a session that has not been revoked
+func (s *SessionStore) Get(sessionID uuid.UUID) (*models.UserSession, error) {
+	var session models.UserSession
+	err := s.db.Where("id = ? AND revoked_at IS NULL", sessionID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// SetActiveRole stores the role context the session switched to
+func (s *SessionStore) SetActiveRole(sessionID uuid.UUID, role string) error {
+	return s.db.Model(&models.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"active_role": role, "updated_at": time.Now()}).Error
+}
+
+// Active lists the sessions of a user that have not been revoked
+func (s *SessionStore) Active(userID uuid.UUID) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := s.db.Where("user_profile_id = ? AND revoked_at IS NULL", userID).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke ends a single session of the user
+func (s *SessionStore) Revoke(userID, sessionID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, "id = ? AND user_profile_id = ?", sessionID, userID)
+	})
+}
+
+// RevokeAll ends every session of the user
+func (s *SessionStore) RevokeAll(userID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, "user_profile_id = ?", userID)
+	})
+}
+
+// RevokeOthers ends every session of the user except the given one
+func (s *SessionStore) RevokeOthers(userID, keepSessionID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, "user_profile_id = ? AND id <> ?", userID, keepSessionID)
+	})
+}
+
+// revokeSessions revokes the matching sessions and their refresh tokens
+func revokeSessions(tx *gorm.DB, query string, args ...interface{}) error {
+	now := time.Now()
+
+	var ids []uuid.UUID
+	if err := tx.Model(&models.UserSession{}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&models.UserSession{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{"revoked_at": now, "updated_at": now}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("session_id IN ? AND revoked_at IS NULL", ids).
+		Update("revoked_at", now).Error
+}
@@ -0,0 +1,30 @@
+package auth
+
+import "github.com/FirstTirr/G7KAIH-GO/internal/models"
+
+// Account statuses. Only active accounts can log in; the others keep their
+// history but are turned away at login and on every request.
+const (
+	StatusActive      = "active"
+	StatusSuspended   = "suspended"   // temporarily blocked, e.g. a lost phone
+	StatusGraduated   = "graduated"   // finished school
+	StatusTransferred = "transferred" // moved to another school
+)
+
+// AccountStatuses lists the valid statuses
+var AccountStatuses = []string{StatusActive, StatusSuspended, StatusGraduated, StatusTransferred}
+
+// ValidAccountStatus reports whether status is one of AccountStatuses
+func ValidAccountStatus(status string) bool {
+	for _, s := range AccountStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountActive reports whether the user may log in and use the API
+func AccountActive(profile *models.UserProfile) bool {
+	return profile.Status == "" || profile.Status == StatusActive
+}
@@ -15,6 +15,11 @@ type Config struct {
 	Cloudinary    CloudinaryConfig
 	CORS          CORSConfig
 	RateLimit     RateLimitConfig
+	LoginGuard    LoginGuardConfig
+	QRLogin       QRLoginConfig
+	MFA           MFAConfig
+	Audit         AuditConfig
+	School        SchoolConfig
 	Logging       LoggingConfig
 	Microservices MicroservicesConfig
 }
@@ -29,6 +34,9 @@ type ServerConfig struct {
 	TrustedProxies []string
 }
 
+// DatabaseConfig holds the connection settings. With RLSEnabled, requests
+// that read student data run in a transaction as RLSRole so the row-level
+// security policies from migration 016 apply.
 type DatabaseConfig struct {
 	Host            string
 	Port            string
@@ -39,11 +47,20 @@ type DatabaseConfig struct {
 	MaxIdleConns    int
 	MaxOpenConns    int
 	ConnMaxLifetime time.Duration
+	RLSEnabled      bool
+	RLSRole         string
 }
 
+// JWTConfig signs tokens with RS256/EdDSA keys from KeysDir when it is set,
+// otherwise with HS256 and Secret. ImpersonationTTL is the lifetime of the
+// read-only tokens admins get to view the app as another user.
 type JWTConfig struct {
-	Secret          string
-	ExpirationHours int
+	Secret           string
+	KeysDir          string
+	ActiveKeyID      string
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+	ImpersonationTTL time.Duration
 }
 
 type CloudinaryConfig struct {
@@ -74,6 +91,50 @@ type RateLimitConfig struct {
 	Enabled           bool
 }
 
+// LoginGuardConfig controls brute-force protection on the login endpoint.
+// Failures are counted separately per client IP, per device fingerprint and
+// per account; once a counter passes its free attempts every further failure
+// doubles the wait, up to LockoutDuration.
+type LoginGuardConfig struct {
+	Enabled                 bool
+	FailureWindow           time.Duration
+	IPFreeAttempts          int
+	FingerprintFreeAttempts int
+	AccountFreeAttempts     int
+	BackoffBase             time.Duration
+	LockoutDuration         time.Duration
+}
+
+// QRLoginConfig controls the one-time QR login cards. When LoginURL is set the
+// QR code holds a link (LoginURL?qr=<token>) so a phone camera can open the
+// app directly; otherwise it holds a plain "g7kaih-login:<token>" payload.
+type QRLoginConfig struct {
+	TokenTTL time.Duration
+	LoginURL string
+}
+
+// MFAConfig controls TOTP two-factor authentication. TOTP secrets are stored
+// encrypted with EncryptionKey (the JWT secret when empty). With
+// AdminRequireMFA, admin routes only accept sessions that passed 2FA.
+type MFAConfig struct {
+	Issuer          string
+	EncryptionKey   string
+	ChallengeTTL    time.Duration
+	AdminRequireMFA bool
+}
+
+// AuditConfig controls the audit log. Entries older than Retention are
+// purged once a day; zero keeps them forever.
+type AuditConfig struct {
+	Retention time.Duration
+}
+
+// SchoolConfig holds school-wide settings. Timezone is the IANA name the
+// submission window's open and close times are read in.
+type SchoolConfig struct {
+	Timezone string
+}
+
 type LoggingConfig struct {
 	Level  string
 	Format string
@@ -111,10 +172,16 @@ func Load() *Config {
 			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 10),
 			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 100),
 			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", "1h"),
+			RLSEnabled:      getEnvAsBool("DB_RLS_ENABLED", false),
+			RLSRole:         getEnv("DB_RLS_ROLE", "g7kaih_app"),
 		},
 		JWT: JWTConfig{
-			Secret:          getEnv("JWT_SECRET", "your-secret-key"),
-			ExpirationHours: getEnvAsInt("JWT_EXPIRATION_HOURS", 24),
+			Secret:           getEnv("JWT_SECRET", "your-secret-key"),
+			KeysDir:          getEnv("JWT_KEYS_DIR", ""),
+			ActiveKeyID:      getEnv("JWT_ACTIVE_KID", ""),
+			AccessTokenTTL:   getEnvAsDuration("JWT_ACCESS_TOKEN_TTL", "15m"),
+			RefreshTokenTTL:  getEnvAsDuration("JWT_REFRESH_TOKEN_TTL", "720h"),
+			ImpersonationTTL: getEnvAsDuration("JWT_IMPERSONATION_TTL", "15m"),
 		},
 		Cloudinary: CloudinaryConfig{
 			CloudName:    getEnv("CLOUDINARY_CLOUD_NAME", ""),
@@ -140,6 +207,31 @@ func Load() *Config {
 			RequestsPerMinute: getEnvAsInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 60),
 			Enabled:           getEnvAsBool("RATE_LIMIT_ENABLED", true),
 		},
+		LoginGuard: LoginGuardConfig{
+			Enabled:                 getEnvAsBool("LOGIN_GUARD_ENABLED", true),
+			FailureWindow:           getEnvAsDuration("LOGIN_FAILURE_WINDOW", "15m"),
+			IPFreeAttempts:          getEnvAsInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+			FingerprintFreeAttempts: getEnvAsInt("LOGIN_FINGERPRINT_FREE_ATTEMPTS", 5),
+			AccountFreeAttempts:     getEnvAsInt("LOGIN_ACCOUNT_FREE_ATTEMPTS", 10),
+			BackoffBase:             getEnvAsDuration("LOGIN_BACKOFF_BASE", "5s"),
+			LockoutDuration:         getEnvAsDuration("LOGIN_LOCKOUT_DURATION", "30m"),
+		},
+		QRLogin: QRLoginConfig{
+			TokenTTL: getEnvAsDuration("QR_LOGIN_TOKEN_TTL", "720h"),
+			LoginURL: getEnv("QR_LOGIN_URL", ""),
+		},
+		MFA: MFAConfig{
+			Issuer:          getEnv("MFA_ISSUER", "G7KAIH"),
+			EncryptionKey:   getEnv("MFA_ENCRYPTION_KEY", ""),
+			ChallengeTTL:    getEnvAsDuration("MFA_CHALLENGE_TTL", "5m"),
+			AdminRequireMFA: getEnvAsBool("ADMIN_REQUIRE_MFA", true),
+		},
+		Audit: AuditConfig{
+			Retention: getEnvAsDuration("AUDIT_RETENTION", "8760h"),
+		},
+		School: SchoolConfig{
+			Timezone: getEnv("SCHOOL_TIMEZONE", "Asia/Jakarta"),
+		},
 		Logging: LoggingConfig{
 			Level:  getEnv("LOG_LEVEL", "debug"),
 			Format: getEnv("LOG_FORMAT", "json"),
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS submission_windows CASCADE;
+DROP TABLE IF EXISTS parent_students CASCADE;
+DROP TABLE IF EXISTS guruwali_assignments CASCADE;
+DROP TABLE IF EXISTS teacher_roles CASCADE;
+DROP TABLE IF EXISTS comments CASCADE;
+DROP TABLE IF EXISTS activities CASCADE;
+DROP TABLE IF EXISTS kegiatan CASCADE;
+DROP TABLE IF EXISTS categories CASCADE;
+DROP TABLE IF EXISTS user_profiles CASCADE;
+
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -1,31 +1,14 @@
--- ==========================================
--- G7KAIH PostgreSQL Database Schema (Token-Based Auth)
--- ==========================================
--- Version: 2.0.0
--- Description: Token-based authentication system
--- ==========================================
-
--- Enable UUID extension
+-- Initial schema: the token-based layout the original schema.sql created.
+-- Every statement is idempotent so databases set up by hand before
+-- migrations were tracked can be adopted by running this again.
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
--- Drop old tables if they exist
-DROP TABLE IF EXISTS comments CASCADE;
-DROP TABLE IF EXISTS activities CASCADE;
-DROP TABLE IF EXISTS kegiatan CASCADE;
-DROP TABLE IF EXISTS categories CASCADE;
-DROP TABLE IF EXISTS parent_students CASCADE;
-DROP TABLE IF EXISTS guruwali_assignments CASCADE;
-DROP TABLE IF EXISTS teacher_roles CASCADE;
-DROP TABLE IF EXISTS user_profiles CASCADE;
-DROP TABLE IF EXISTS users CASCADE;
-DROP TABLE IF EXISTS submission_windows CASCADE;
-
 -- ==========================================
 -- TABLES
 -- ==========================================
 
 -- User Profiles Table (Main user table with token authentication)
-CREATE TABLE user_profiles (
+CREATE TABLE IF NOT EXISTS user_profiles (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     name VARCHAR(255) NOT NULL,
     class VARCHAR(50),
@@ -39,7 +22,7 @@ CREATE TABLE user_profiles (
 );
 
 -- Categories Table
-CREATE TABLE categories (
+CREATE TABLE IF NOT EXISTS categories (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     name VARCHAR(255) UNIQUE NOT NULL,
     description TEXT,
@@ -51,7 +34,7 @@ CREATE TABLE categories (
 );
 
 -- Kegiatan Table (Activity Types/Templates)
-CREATE TABLE kegiatan (
+CREATE TABLE IF NOT EXISTS kegiatan (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     name VARCHAR(255) NOT NULL,
     description TEXT,
@@ -64,7 +47,7 @@ CREATE TABLE kegiatan (
 );
 
 -- Activities Table (Student Submissions)
-CREATE TABLE activities (
+CREATE TABLE IF NOT EXISTS activities (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     user_profile_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
     kegiatan_id UUID NOT NULL REFERENCES kegiatan(id) ON DELETE RESTRICT,
@@ -78,7 +61,7 @@ CREATE TABLE activities (
 );
 
 -- Comments Table
-CREATE TABLE comments (
+CREATE TABLE IF NOT EXISTS comments (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     activity_id UUID NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
     user_profile_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
@@ -89,7 +72,7 @@ CREATE TABLE comments (
 );
 
 -- Teacher Roles Table (Teacher-Class Assignments)
-CREATE TABLE teacher_roles (
+CREATE TABLE IF NOT EXISTS teacher_roles (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     teacher_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
     class_name VARCHAR(50) NOT NULL,
@@ -100,7 +83,7 @@ CREATE TABLE teacher_roles (
 );
 
 -- Guru Wali Assignments Table (Homeroom Teacher to Student)
-CREATE TABLE guruwali_assignments (
+CREATE TABLE IF NOT EXISTS guruwali_assignments (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     teacher_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
     student_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
@@ -110,7 +93,7 @@ CREATE TABLE guruwali_assignments (
 );
 
 -- Parent-Student Relationships Table
-CREATE TABLE parent_students (
+CREATE TABLE IF NOT EXISTS parent_students (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     parent_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
     student_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
@@ -121,7 +104,7 @@ CREATE TABLE parent_students (
 );
 
 -- Submission Window Table
-CREATE TABLE submission_windows (
+CREATE TABLE IF NOT EXISTS submission_windows (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     is_open BOOLEAN DEFAULT true,
     open_time VARCHAR(10),
@@ -134,41 +117,43 @@ CREATE TABLE submission_windows (
 -- INDEXES
 -- ==========================================
 
-CREATE INDEX idx_user_profiles_role ON user_profiles(role);
-CREATE INDEX idx_user_profiles_class ON user_profiles(class);
-CREATE INDEX idx_user_profiles_token_hash ON user_profiles(token_hash);
-CREATE INDEX idx_user_profiles_deleted_at ON user_profiles(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_user_profiles_role ON user_profiles(role);
+CREATE INDEX IF NOT EXISTS idx_user_profiles_class ON user_profiles(class);
+CREATE INDEX IF NOT EXISTS idx_user_profiles_token_hash ON user_profiles(token_hash);
+CREATE INDEX IF NOT EXISTS idx_user_profiles_deleted_at ON user_profiles(deleted_at);
 
-CREATE INDEX idx_categories_name ON categories(name);
-CREATE INDEX idx_categories_deleted_at ON categories(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_categories_name ON categories(name);
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories(deleted_at);
 
-CREATE INDEX idx_kegiatan_category_id ON kegiatan(category_id);
-CREATE INDEX idx_kegiatan_is_active ON kegiatan(is_active);
-CREATE INDEX idx_kegiatan_deleted_at ON kegiatan(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_kegiatan_category_id ON kegiatan(category_id);
+CREATE INDEX IF NOT EXISTS idx_kegiatan_is_active ON kegiatan(is_active);
+CREATE INDEX IF NOT EXISTS idx_kegiatan_deleted_at ON kegiatan(deleted_at);
 
-CREATE INDEX idx_activities_user_profile_id ON activities(user_profile_id);
-CREATE INDEX idx_activities_kegiatan_id ON activities(kegiatan_id);
-CREATE INDEX idx_activities_date ON activities(date DESC);
-CREATE INDEX idx_activities_status ON activities(status);
-CREATE INDEX idx_activities_user_date ON activities(user_profile_id, date DESC);
-CREATE INDEX idx_activities_deleted_at ON activities(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_activities_user_profile_id ON activities(user_profile_id);
+CREATE INDEX IF NOT EXISTS idx_activities_kegiatan_id ON activities(kegiatan_id);
+CREATE INDEX IF NOT EXISTS idx_activities_kegiatan_date ON activities(kegiatan_id, date DESC);
+CREATE INDEX IF NOT EXISTS idx_activities_date ON activities(date DESC);
+CREATE INDEX IF NOT EXISTS idx_activities_status ON activities(status);
+CREATE INDEX IF NOT EXISTS idx_activities_user_date ON activities(user_profile_id, date DESC);
+CREATE INDEX IF NOT EXISTS idx_activities_deleted_at ON activities(deleted_at);
 
-CREATE INDEX idx_comments_activity_id ON comments(activity_id);
-CREATE INDEX idx_comments_user_profile_id ON comments(user_profile_id);
-CREATE INDEX idx_comments_created_at ON comments(created_at DESC);
-CREATE INDEX idx_comments_deleted_at ON comments(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_comments_activity_id ON comments(activity_id);
+CREATE INDEX IF NOT EXISTS idx_comments_user_profile_id ON comments(user_profile_id);
+CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_comments_activity ON comments(activity_id, created_at DESC);
 
-CREATE INDEX idx_teacher_roles_teacher_id ON teacher_roles(teacher_id);
-CREATE INDEX idx_teacher_roles_class_name ON teacher_roles(class_name);
-CREATE INDEX idx_teacher_roles_deleted_at ON teacher_roles(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_teacher_roles_teacher_id ON teacher_roles(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_teacher_roles_class_name ON teacher_roles(class_name);
+CREATE INDEX IF NOT EXISTS idx_teacher_roles_deleted_at ON teacher_roles(deleted_at);
 
-CREATE INDEX idx_guruwali_assignments_teacher_id ON guruwali_assignments(teacher_id);
-CREATE INDEX idx_guruwali_assignments_student_id ON guruwali_assignments(student_id);
-CREATE INDEX idx_guruwali_assignments_deleted_at ON guruwali_assignments(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_guruwali_assignments_teacher_id ON guruwali_assignments(teacher_id);
+CREATE INDEX IF NOT EXISTS idx_guruwali_assignments_student_id ON guruwali_assignments(student_id);
+CREATE INDEX IF NOT EXISTS idx_guruwali_assignments_deleted_at ON guruwali_assignments(deleted_at);
 
-CREATE INDEX idx_parent_students_parent_id ON parent_students(parent_id);
-CREATE INDEX idx_parent_students_student_id ON parent_students(student_id);
-CREATE INDEX idx_parent_students_deleted_at ON parent_students(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_parent_students_parent_id ON parent_students(parent_id);
+CREATE INDEX IF NOT EXISTS idx_parent_students_student_id ON parent_students(student_id);
+CREATE INDEX IF NOT EXISTS idx_parent_students_deleted_at ON parent_students(deleted_at);
 
 -- ==========================================
 -- TRIGGERS
@@ -182,30 +167,39 @@ BEGIN
 END;
 $$ language 'plpgsql';
 
+DROP TRIGGER IF EXISTS update_user_profiles_updated_at ON user_profiles;
 CREATE TRIGGER update_user_profiles_updated_at BEFORE UPDATE ON user_profiles
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_categories_updated_at ON categories;
 CREATE TRIGGER update_categories_updated_at BEFORE UPDATE ON categories
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_kegiatan_updated_at ON kegiatan;
 CREATE TRIGGER update_kegiatan_updated_at BEFORE UPDATE ON kegiatan
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_activities_updated_at ON activities;
 CREATE TRIGGER update_activities_updated_at BEFORE UPDATE ON activities
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_comments_updated_at ON comments;
 CREATE TRIGGER update_comments_updated_at BEFORE UPDATE ON comments
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_teacher_roles_updated_at ON teacher_roles;
 CREATE TRIGGER update_teacher_roles_updated_at BEFORE UPDATE ON teacher_roles
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_guruwali_assignments_updated_at ON guruwali_assignments;
 CREATE TRIGGER update_guruwali_assignments_updated_at BEFORE UPDATE ON guruwali_assignments
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_parent_students_updated_at ON parent_students;
 CREATE TRIGGER update_parent_students_updated_at BEFORE UPDATE ON parent_students
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+DROP TRIGGER IF EXISTS update_submission_windows_updated_at ON submission_windows;
 CREATE TRIGGER update_submission_windows_updated_at BEFORE UPDATE ON submission_windows
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
@@ -215,8 +209,9 @@ CREATE TRIGGER update_submission_windows_updated_at BEFORE UPDATE ON submission_
 
 -- Insert admin user with token "0000"
 -- Token hash is SHA256 of "0000"
-INSERT INTO user_profiles (name, class, role, token_hash) VALUES 
-('Administrator', 'ADMIN', 'admin', '9af15b336e6a9619928537df30b2e6a2376569fcf9d7e773eccede65606529a0');
+INSERT INTO user_profiles (name, class, role, token_hash)
+SELECT 'Administrator', 'ADMIN', 'admin', '9af15b336e6a9619928537df30b2e6a2376569fcf9d7e773eccede65606529a0'
+WHERE NOT EXISTS (SELECT 1 FROM user_profiles WHERE role = 'admin');
 
 -- Insert default categories
 INSERT INTO categories (id, name, description, icon, color) VALUES 
@@ -224,7 +219,8 @@ INSERT INTO categories (id, name, description, icon, color) VALUES
 ('10000000-0000-0000-0000-000000000002', 'Ekstrakurikuler', 'Kegiatan di luar jam pelajaran', '⚽', '#10B981'),
 ('10000000-0000-0000-0000-000000000003', 'Karakter', 'Pembentukan karakter dan moral', '🌟', '#F59E0B'),
 ('10000000-0000-0000-0000-000000000004', 'Sosial', 'Kegiatan sosial dan kemasyarakatan', '🤝', '#EF4444'),
-('10000000-0000-0000-0000-000000000005', 'Spiritual', 'Kegiatan keagamaan dan spiritual', '🕌', '#8B5CF6');
+('10000000-0000-0000-0000-000000000005', 'Spiritual', 'Kegiatan keagamaan dan spiritual', '🕌', '#8B5CF6')
+ON CONFLICT DO NOTHING;
 
 -- Insert sample kegiatan
 INSERT INTO kegiatan (id, name, description, category_id, is_active, form_schema) VALUES 
@@ -259,21 +255,10 @@ INSERT INTO kegiatan (id, name, description, category_id, is_active, form_schema
     '10000000-0000-0000-0000-000000000003',
     true,
     '{"fields": [{"name": "jenis_pekerjaan", "type": "text", "label": "Jenis Pekerjaan"}, {"name": "durasi", "type": "number", "label": "Durasi (menit)"}]}'::jsonb
-);
+)
+ON CONFLICT DO NOTHING;
 
 -- Insert default submission window
-INSERT INTO submission_windows (is_open, open_time, close_time) VALUES 
-(true, '05:00', '22:00');
-
--- ==========================================
--- COMPLETION
--- ==========================================
-
-DO $$
-BEGIN
-    RAISE NOTICE '=========================================';
-    RAISE NOTICE 'G7KAIH Token-Based Database Schema Created!';
-    RAISE NOTICE '=========================================';
-    RAISE NOTICE 'Admin Login: token = 0000';
-    RAISE NOTICE '=========================================';
-END $$;
\ No newline at end of file
+INSERT INTO submission_windows (is_open, open_time, close_time)
+SELECT true, '05:00', '22:00'
+WHERE NOT EXISTS (SELECT 1 FROM submission_windows);
@@ -0,0 +1,8 @@
+-- token_hash stays nullable: accounts created since have no legacy code
+DROP INDEX IF EXISTS idx_user_profiles_nis;
+DROP INDEX IF EXISTS idx_user_profiles_username;
+
+ALTER TABLE user_profiles
+    DROP COLUMN IF EXISTS pin_hash,
+    DROP COLUMN IF EXISTS nis,
+    DROP COLUMN IF EXISTS username;
@@ -0,0 +1,12 @@
+-- Username/NIS + PIN login. PINs are salted with the profile ID, so they only
+-- need to be unique per account. Existing 4-digit accounts keep token_hash and
+-- move to the new scheme the next time their code is changed.
+ALTER TABLE user_profiles
+    ADD COLUMN IF NOT EXISTS username VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS nis VARCHAR(30),
+    ADD COLUMN IF NOT EXISTS pin_hash VARCHAR(255);
+
+ALTER TABLE user_profiles ALTER COLUMN token_hash DROP NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_profiles_username ON user_profiles(username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_profiles_nis ON user_profiles(nis);
@@ -0,0 +1,6 @@
+-- Sessions become refresh token families again
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS refresh_tokens_session_id_fkey;
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+ALTER INDEX IF EXISTS idx_refresh_tokens_session_id RENAME TO idx_refresh_tokens_family_id;
+
+DROP TABLE IF EXISTS user_sessions;
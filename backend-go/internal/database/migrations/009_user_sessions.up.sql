@@ -0,0 +1,40 @@
+-- Sessions: every access token carries the ID of the session it was issued
+-- for, so logging out (or an admin revoking access) takes effect immediately.
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_profile_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_profile_id ON user_sessions(user_profile_id) WHERE revoked_at IS NULL;
+
+-- Refresh token families become sessions
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'refresh_tokens' AND column_name = 'family_id'
+    ) THEN
+        INSERT INTO user_sessions (id, user_profile_id, last_used_at, revoked_at, created_at, updated_at)
+        SELECT family_id,
+               MIN(user_profile_id::text)::uuid,
+               MAX(created_at),
+               CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END,
+               MIN(created_at),
+               MAX(created_at)
+        FROM refresh_tokens
+        GROUP BY family_id
+        ON CONFLICT (id) DO NOTHING;
+
+        ALTER TABLE refresh_tokens RENAME COLUMN family_id TO session_id;
+        ALTER INDEX IF EXISTS idx_refresh_tokens_family_id RENAME TO idx_refresh_tokens_session_id;
+        ALTER TABLE refresh_tokens
+            ADD CONSTRAINT refresh_tokens_session_id_fkey
+            FOREIGN KEY (session_id) REFERENCES user_sessions(id) ON DELETE CASCADE;
+    END IF;
+END $$;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS registration_settings;
+DROP TABLE IF EXISTS invite_codes;
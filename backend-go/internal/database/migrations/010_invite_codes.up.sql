@@ -0,0 +1,34 @@
+-- Invite codes: signup requires a code bound to a role (and optionally a
+-- class) unless an admin has opened that role for self-registration.
+CREATE TABLE IF NOT EXISTS invite_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(32) NOT NULL UNIQUE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('siswa', 'orangtua', 'guru', 'guruwali', 'admin')),
+    class VARCHAR(50),
+    max_uses INTEGER NOT NULL DEFAULT 1 CHECK (max_uses > 0),
+    used_count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    note TEXT,
+    created_by UUID REFERENCES user_profiles(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (used_count <= max_uses)
+);
+
+CREATE TABLE IF NOT EXISTS registration_settings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    open_roles VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invite_codes_created_at ON invite_codes(created_at DESC);
+
+-- Existing installs start invite-only
+INSERT INTO registration_settings (open_roles)
+SELECT '' WHERE NOT EXISTS (SELECT 1 FROM registration_settings);
+
+DROP TRIGGER IF EXISTS update_invite_codes_updated_at ON invite_codes;
+CREATE TRIGGER update_invite_codes_updated_at BEFORE UPDATE ON invite_codes
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
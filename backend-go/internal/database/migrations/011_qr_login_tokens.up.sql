@@ -0,0 +1,14 @@
+-- One-time QR login cards for students. Issuing a new card revokes the
+-- user's unused ones; each card logs in once.
+CREATE TABLE IF NOT EXISTS qr_login_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_profile_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    token_hash VARCHAR(255) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES user_profiles(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_qr_login_tokens_user_profile_id ON qr_login_tokens(user_profile_id) WHERE used_at IS NULL AND revoked_at IS NULL;
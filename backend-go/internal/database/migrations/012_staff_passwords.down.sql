@@ -0,0 +1,4 @@
+ALTER TABLE user_profiles
+    DROP COLUMN IF EXISTS password_changed_at,
+    DROP COLUMN IF EXISTS password_must_change,
+    DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1,11 @@
+-- Staff accounts (admin, guru, guruwali) log in with a bcrypt password.
+-- Staff without one can still log in with their PIN but have to set a
+-- password before they can use anything else.
+ALTER TABLE user_profiles
+    ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS password_must_change BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE user_profiles
+SET password_must_change = true
+WHERE role IN ('admin', 'guru', 'guruwali') AND password_hash IS NULL;
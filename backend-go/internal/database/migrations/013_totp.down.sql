@@ -0,0 +1,8 @@
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS mfa;
+
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE user_profiles
+    DROP COLUMN IF EXISTS totp_last_step,
+    DROP COLUMN IF EXISTS totp_enabled_at,
+    DROP COLUMN IF EXISTS totp_secret;
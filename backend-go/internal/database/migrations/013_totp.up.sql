@@ -0,0 +1,20 @@
+-- TOTP two-factor authentication for staff accounts. The secret is stored
+-- AES-GCM encrypted; totp_last_step stops a code from being used twice.
+ALTER TABLE user_profiles
+    ADD COLUMN IF NOT EXISTS totp_secret TEXT,
+    ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_profile_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    code_hash VARCHAR(255) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_profile_id ON recovery_codes(user_profile_id);
+
+-- Sessions opened with a second factor; admin endpoints can require this
+ALTER TABLE user_sessions
+    ADD COLUMN IF NOT EXISTS mfa BOOLEAN NOT NULL DEFAULT false;
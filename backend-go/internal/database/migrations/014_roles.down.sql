@@ -0,0 +1,14 @@
+-- Back to the fixed set of roles. The checks are NOT VALID so users and
+-- invites that carry a custom role by now do not block the rollback.
+DROP TRIGGER IF EXISTS update_roles_updated_at ON roles;
+
+ALTER TABLE invite_codes DROP CONSTRAINT IF EXISTS invite_codes_role_fkey;
+ALTER TABLE user_profiles DROP CONSTRAINT IF EXISTS user_profiles_role_fkey;
+
+ALTER TABLE user_profiles ADD CONSTRAINT user_profiles_role_check
+    CHECK (role IN ('admin', 'guru', 'guruwali', 'siswa', 'orangtua')) NOT VALID;
+ALTER TABLE invite_codes ADD CONSTRAINT invite_codes_role_check
+    CHECK (role IN ('siswa', 'orangtua', 'guru', 'guruwali', 'admin')) NOT VALID;
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
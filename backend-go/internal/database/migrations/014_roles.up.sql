@@ -0,0 +1,56 @@
+-- Roles and permissions move from code into the database. A role is a named
+-- set of permissions from the registry in internal/auth/permissions.go; admin
+-- always has every permission and needs no rows here.
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    display_name VARCHAR(100) NOT NULL,
+    description TEXT,
+    built_in BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_name VARCHAR(50) NOT NULL REFERENCES roles(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL,
+    PRIMARY KEY (role_name, permission)
+);
+
+INSERT INTO roles (name, display_name, built_in) VALUES
+('admin', 'Administrator', true),
+('guru', 'Guru', true),
+('guruwali', 'Guru Wali', true),
+('siswa', 'Siswa', true),
+('orangtua', 'Orang Tua', true)
+ON CONFLICT (name) DO NOTHING;
+
+-- Same access the hard-coded role checks used to give
+INSERT INTO role_permissions (role_name, permission) VALUES
+('guru', 'activity.review'),
+('guru', 'report.view.class'),
+('guruwali', 'activity.review'),
+('guruwali', 'report.view.class'),
+('guruwali', 'report.view.supervised'),
+('orangtua', 'child.view')
+ON CONFLICT DO NOTHING;
+
+-- Users and invites may now carry any role from the roles table
+ALTER TABLE user_profiles DROP CONSTRAINT IF EXISTS user_profiles_role_check;
+ALTER TABLE invite_codes DROP CONSTRAINT IF EXISTS invite_codes_role_check;
+ALTER TABLE invite_codes ALTER COLUMN role TYPE VARCHAR(50);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'user_profiles_role_fkey') THEN
+        ALTER TABLE user_profiles ADD CONSTRAINT user_profiles_role_fkey
+            FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'invite_codes_role_fkey') THEN
+        ALTER TABLE invite_codes ADD CONSTRAINT invite_codes_role_fkey
+            FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE ON DELETE CASCADE;
+    END IF;
+END $$;
+
+DROP TRIGGER IF EXISTS update_roles_updated_at ON roles;
+CREATE TRIGGER update_roles_updated_at BEFORE UPDATE ON roles
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,3 @@
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS active_role;
+
+DROP TABLE IF EXISTS user_roles;
@@ -0,0 +1,14 @@
+-- Users may hold several roles, e.g. a teacher who is also guru wali and a
+-- parent. user_profiles.role stays the primary role; user_roles lists the
+-- additional ones. Each session remembers which role context is active.
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_profile_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    role_name VARCHAR(50) NOT NULL REFERENCES roles(name) ON UPDATE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_profile_id, role_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_name ON user_roles(role_name);
+
+-- NULL means the primary role
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS active_role VARCHAR(50);
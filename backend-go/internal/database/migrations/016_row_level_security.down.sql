@@ -0,0 +1,25 @@
+-- The g7kaih_app role is shared by every database in the cluster, so it
+-- and its grants are left in place; without policies it is harmless.
+DROP POLICY IF EXISTS guruwali_assignments_manage ON guruwali_assignments;
+DROP POLICY IF EXISTS guruwali_assignments_select ON guruwali_assignments;
+ALTER TABLE guruwali_assignments DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS parent_students_manage ON parent_students;
+DROP POLICY IF EXISTS parent_students_select ON parent_students;
+ALTER TABLE parent_students DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS comments_delete ON comments;
+DROP POLICY IF EXISTS comments_update ON comments;
+DROP POLICY IF EXISTS comments_insert ON comments;
+DROP POLICY IF EXISTS comments_select ON comments;
+ALTER TABLE comments DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS activities_delete ON activities;
+DROP POLICY IF EXISTS activities_update ON activities;
+DROP POLICY IF EXISTS activities_insert ON activities;
+DROP POLICY IF EXISTS activities_select ON activities;
+ALTER TABLE activities DISABLE ROW LEVEL SECURITY;
+
+DROP FUNCTION IF EXISTS app_can_see_user(UUID);
+DROP FUNCTION IF EXISTS app_has_permission(TEXT);
+DROP FUNCTION IF EXISTS app_user_id();
@@ -0,0 +1,108 @@
+-- Row-level security as a second line of defense behind internal/policy.
+-- With DB_RLS_ENABLED the API runs each request that reads student data in a
+-- transaction that switches to the restricted g7kaih_app role and sets
+-- app.user_id / app.role. The policies below then mirror the app rules, so a
+-- query that forgets its filter still only returns rows the user may see.
+-- The table owner (the normal connection user) is not affected.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'g7kaih_app') THEN
+        CREATE ROLE g7kaih_app NOLOGIN;
+    END IF;
+    EXECUTE format('GRANT g7kaih_app TO %I', current_user);
+END
+$$;
+
+GRANT USAGE ON SCHEMA public TO g7kaih_app;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO g7kaih_app;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO g7kaih_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO g7kaih_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO g7kaih_app;
+
+-- The user the current transaction runs as, NULL outside a request
+CREATE OR REPLACE FUNCTION app_user_id() RETURNS UUID AS $$
+    SELECT NULLIF(current_setting('app.user_id', true), '')::uuid
+$$ LANGUAGE sql STABLE;
+
+-- Whether any role of the current user grants the permission (admin has all)
+CREATE OR REPLACE FUNCTION app_has_permission(perm TEXT) RETURNS BOOLEAN AS $$
+    WITH held AS (
+        SELECT role AS name FROM user_profiles WHERE id = app_user_id() AND deleted_at IS NULL
+        UNION
+        SELECT role_name FROM user_roles WHERE user_profile_id = app_user_id()
+    )
+    SELECT EXISTS (SELECT 1 FROM held WHERE name = 'admin')
+        OR EXISTS (SELECT 1 FROM role_permissions rp JOIN held ON held.name = rp.role_name WHERE rp.permission = perm)
+$$ LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public;
+
+-- Mirror of policy.Subject.CanSeeUser
+CREATE OR REPLACE FUNCTION app_can_see_user(target UUID) RETURNS BOOLEAN AS $$
+    SELECT target = app_user_id()
+        OR EXISTS (
+            SELECT 1 FROM user_profiles s
+            WHERE s.id = target AND s.role = 'siswa' AND s.deleted_at IS NULL
+              AND (app_has_permission('student.view.all')
+                   OR (app_has_permission('report.view.class') AND s.class IN (
+                       SELECT class_name FROM teacher_roles WHERE teacher_id = app_user_id() AND deleted_at IS NULL)))
+        )
+        OR (app_has_permission('report.view.supervised') AND EXISTS (
+            SELECT 1 FROM guruwali_assignments
+            WHERE teacher_id = app_user_id() AND student_id = target AND deleted_at IS NULL))
+        OR (app_has_permission('child.view') AND EXISTS (
+            SELECT 1 FROM parent_students
+            WHERE parent_id = app_user_id() AND student_id = target AND deleted_at IS NULL))
+$$ LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public;
+
+-- Activities: visible to whoever may see the owner. Soft deletes are
+-- updates, so activity.delete.any also allows UPDATE.
+ALTER TABLE activities ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS activities_select ON activities;
+DROP POLICY IF EXISTS activities_insert ON activities;
+DROP POLICY IF EXISTS activities_update ON activities;
+DROP POLICY IF EXISTS activities_delete ON activities;
+CREATE POLICY activities_select ON activities FOR SELECT TO g7kaih_app
+    USING (app_can_see_user(user_profile_id));
+CREATE POLICY activities_insert ON activities FOR INSERT TO g7kaih_app
+    WITH CHECK (user_profile_id = app_user_id());
+CREATE POLICY activities_update ON activities FOR UPDATE TO g7kaih_app
+    USING (user_profile_id = app_user_id()
+        OR (app_has_permission('activity.review') AND app_can_see_user(user_profile_id))
+        OR app_has_permission('activity.delete.any'));
+CREATE POLICY activities_delete ON activities FOR DELETE TO g7kaih_app
+    USING (user_profile_id = app_user_id() OR app_has_permission('activity.delete.any'));
+
+-- Comments: visible with their activity (the subquery is itself filtered by
+-- the activities policies)
+ALTER TABLE comments ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS comments_select ON comments;
+DROP POLICY IF EXISTS comments_insert ON comments;
+DROP POLICY IF EXISTS comments_update ON comments;
+DROP POLICY IF EXISTS comments_delete ON comments;
+CREATE POLICY comments_select ON comments FOR SELECT TO g7kaih_app
+    USING (EXISTS (SELECT 1 FROM activities a WHERE a.id = comments.activity_id));
+CREATE POLICY comments_insert ON comments FOR INSERT TO g7kaih_app
+    WITH CHECK (user_profile_id = app_user_id()
+        AND EXISTS (SELECT 1 FROM activities a WHERE a.id = comments.activity_id));
+CREATE POLICY comments_update ON comments FOR UPDATE TO g7kaih_app
+    USING (user_profile_id = app_user_id() OR app_has_permission('comment.moderate'));
+CREATE POLICY comments_delete ON comments FOR DELETE TO g7kaih_app
+    USING (user_profile_id = app_user_id() OR app_has_permission('comment.moderate'));
+
+-- Parent and guru wali links: visible to both sides, managed with user.manage
+ALTER TABLE parent_students ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS parent_students_select ON parent_students;
+DROP POLICY IF EXISTS parent_students_manage ON parent_students;
+CREATE POLICY parent_students_select ON parent_students FOR SELECT TO g7kaih_app
+    USING (parent_id = app_user_id() OR student_id = app_user_id());
+CREATE POLICY parent_students_manage ON parent_students FOR ALL TO g7kaih_app
+    USING (app_has_permission('user.manage'))
+    WITH CHECK (app_has_permission('user.manage'));
+
+ALTER TABLE guruwali_assignments ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS guruwali_assignments_select ON guruwali_assignments;
+DROP POLICY IF EXISTS guruwali_assignments_manage ON guruwali_assignments;
+CREATE POLICY guruwali_assignments_select ON guruwali_assignments FOR SELECT TO g7kaih_app
+    USING (teacher_id = app_user_id() OR student_id = app_user_id());
+CREATE POLICY guruwali_assignments_manage ON guruwali_assignments FOR ALL TO g7kaih_app
+    USING (app_has_permission('user.manage'))
+    WITH CHECK (app_has_permission('user.manage'));
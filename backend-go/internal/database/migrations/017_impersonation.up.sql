@@ -0,0 +1,19 @@
+-- Impersonation: an admin can view the app as another user with a
+-- short-lived, read-only token. Every such session is kept here as an audit
+-- trail, including how many write requests were refused during it.
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    actor_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    actor_session_id UUID NOT NULL REFERENCES user_sessions(id) ON DELETE CASCADE,
+    target_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    blocked_writes INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_actor_id ON impersonation_sessions(actor_id);
+CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_target_id ON impersonation_sessions(target_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_user_profiles_status;
+
+ALTER TABLE user_profiles DROP CONSTRAINT IF EXISTS user_profiles_status_check;
+ALTER TABLE user_profiles
+    DROP COLUMN IF EXISTS status_changed_by,
+    DROP COLUMN IF EXISTS status_changed_at,
+    DROP COLUMN IF EXISTS status_reason,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,17 @@
+-- Account status: suspend, graduate or transfer a user without deleting
+-- them. Only active accounts can log in; existing sessions stop working as
+-- soon as the status changes.
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS status_changed_by UUID REFERENCES user_profiles(id) ON DELETE SET NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'user_profiles_status_check') THEN
+        ALTER TABLE user_profiles ADD CONSTRAINT user_profiles_status_check
+            CHECK (status IN ('active', 'suspended', 'graduated', 'transferred'));
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_user_profiles_status ON user_profiles(status) WHERE status <> 'active';
@@ -0,0 +1,3 @@
+-- Dropping the table removes its append-only triggers with it
+DROP TABLE IF EXISTS audit_logs;
+DROP FUNCTION IF EXISTS audit_logs_append_only();
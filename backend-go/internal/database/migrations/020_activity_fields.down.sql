@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS activity_files;
+DROP TABLE IF EXISTS activity_field_validations;
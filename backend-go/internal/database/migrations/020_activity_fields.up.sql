@@ -0,0 +1,59 @@
+-- Per-field review flags and submitted files of activities. The Next.js app
+-- kept these in aktivitas_field_values, aktivitas_field_files and
+-- aktivitas_field_images; the legacy importer fills them.
+CREATE TABLE IF NOT EXISTS activity_field_validations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    activity_id UUID NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    field_key VARCHAR(255) NOT NULL,
+    validated_by_teacher BOOLEAN NOT NULL DEFAULT false,
+    validated_by_parent BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (activity_id, field_key)
+);
+
+CREATE TABLE IF NOT EXISTS activity_files (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    activity_id UUID NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    field_key VARCHAR(255) NOT NULL,
+    filename TEXT,
+    content_type VARCHAR(255) NOT NULL,
+    url TEXT,
+    public_id TEXT,
+    data BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (url IS NOT NULL OR data IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_files_activity_id ON activity_files(activity_id);
+
+DROP TRIGGER IF EXISTS update_activity_field_validations_updated_at ON activity_field_validations;
+CREATE TRIGGER update_activity_field_validations_updated_at BEFORE UPDATE ON activity_field_validations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS update_activity_files_updated_at ON activity_files;
+CREATE TRIGGER update_activity_files_updated_at BEFORE UPDATE ON activity_files
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Visible with their activity, like comments. The owner manages files;
+-- reviewers and parents who can see the activity set the validation flags.
+ALTER TABLE activity_field_validations ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS activity_field_validations_select ON activity_field_validations;
+DROP POLICY IF EXISTS activity_field_validations_manage ON activity_field_validations;
+CREATE POLICY activity_field_validations_select ON activity_field_validations FOR SELECT TO g7kaih_app
+    USING (EXISTS (SELECT 1 FROM activities a WHERE a.id = activity_field_validations.activity_id));
+CREATE POLICY activity_field_validations_manage ON activity_field_validations FOR ALL TO g7kaih_app
+    USING ((app_has_permission('activity.review') OR app_has_permission('child.view'))
+        AND EXISTS (SELECT 1 FROM activities a WHERE a.id = activity_field_validations.activity_id))
+    WITH CHECK ((app_has_permission('activity.review') OR app_has_permission('child.view'))
+        AND EXISTS (SELECT 1 FROM activities a WHERE a.id = activity_field_validations.activity_id));
+
+ALTER TABLE activity_files ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS activity_files_select ON activity_files;
+DROP POLICY IF EXISTS activity_files_manage ON activity_files;
+CREATE POLICY activity_files_select ON activity_files FOR SELECT TO g7kaih_app
+    USING (EXISTS (SELECT 1 FROM activities a WHERE a.id = activity_files.activity_id));
+CREATE POLICY activity_files_manage ON activity_files FOR ALL TO g7kaih_app
+    USING (EXISTS (SELECT 1 FROM activities a WHERE a.id = activity_files.activity_id AND a.user_profile_id = app_user_id()))
+    WITH CHECK (EXISTS (SELECT 1 FROM activities a WHERE a.id = activity_files.activity_id AND a.user_profile_id = app_user_id()));
@@ -0,0 +1,36 @@
+-- Comments about a student as a whole. The Next.js app kept these in
+-- komentar (siswaid); they are served by the compatibility API and filled
+-- by the legacy importer.
+CREATE TABLE IF NOT EXISTS student_comments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    student_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    user_profile_id UUID NOT NULL REFERENCES user_profiles(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_student_comments_student_id ON student_comments(student_id);
+CREATE INDEX IF NOT EXISTS idx_student_comments_deleted_at ON student_comments(deleted_at);
+
+DROP TRIGGER IF EXISTS update_student_comments_updated_at ON student_comments;
+CREATE TRIGGER update_student_comments_updated_at BEFORE UPDATE ON student_comments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Visible to whoever may see the student; written by those users except the
+-- student, and changed by the author or with comment.moderate
+ALTER TABLE student_comments ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS student_comments_select ON student_comments;
+DROP POLICY IF EXISTS student_comments_insert ON student_comments;
+DROP POLICY IF EXISTS student_comments_update ON student_comments;
+DROP POLICY IF EXISTS student_comments_delete ON student_comments;
+CREATE POLICY student_comments_select ON student_comments FOR SELECT TO g7kaih_app
+    USING (app_can_see_user(student_id));
+CREATE POLICY student_comments_insert ON student_comments FOR INSERT TO g7kaih_app
+    WITH CHECK (user_profile_id = app_user_id() AND student_id <> app_user_id()
+        AND app_can_see_user(student_id));
+CREATE POLICY student_comments_update ON student_comments FOR UPDATE TO g7kaih_app
+    USING (user_profile_id = app_user_id() OR app_has_permission('comment.moderate'));
+CREATE POLICY student_comments_delete ON student_comments FOR DELETE TO g7kaih_app
+    USING (user_profile_id = app_user_id() OR app_has_permission('comment.moderate'));
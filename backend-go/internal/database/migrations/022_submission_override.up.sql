@@ -0,0 +1,6 @@
+-- Teachers may still enter their own activities while the submission window
+-- is closed; admins hold every permission already
+INSERT INTO role_permissions (role_name, permission) VALUES
+('guru', 'submission.override'),
+('guruwali', 'submission.override')
+ON CONFLICT DO NOTHING;
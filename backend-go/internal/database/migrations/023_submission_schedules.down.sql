@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS submission_windows (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    is_open BOOLEAN DEFAULT true,
+    open_time VARCHAR(10),
+    close_time VARCHAR(10),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_submission_windows_updated_at ON submission_windows;
+CREATE TRIGGER update_submission_windows_updated_at BEFORE UPDATE ON submission_windows
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Weekly hours cannot be kept per day or scope; the unscoped Monday rule
+-- and any open-ended unscoped closure are carried back
+INSERT INTO submission_windows (is_open, open_time, close_time)
+SELECT NOT EXISTS (SELECT 1 FROM submission_exceptions
+                   WHERE closed AND class IS NULL AND kegiatan_id IS NULL AND end_date IS NULL),
+       (SELECT open_time FROM submission_rules WHERE weekday = 1 AND class IS NULL AND kegiatan_id IS NULL ORDER BY created_at DESC LIMIT 1),
+       (SELECT close_time FROM submission_rules WHERE weekday = 1 AND class IS NULL AND kegiatan_id IS NULL ORDER BY created_at DESC LIMIT 1)
+WHERE NOT EXISTS (SELECT 1 FROM submission_windows);
+
+DROP TABLE IF EXISTS submission_exceptions;
+DROP TABLE IF EXISTS submission_rules;
@@ -0,0 +1,57 @@
+-- Submission schedules replace the single submission window. Rules give the
+-- hours of each weekday (0 = Sunday); exceptions replace them for a date
+-- range. Both can be narrowed to a class ("XII*" for a grade) or a kegiatan,
+-- and the most specific match wins. Days without a rule are open all day.
+CREATE TABLE IF NOT EXISTS submission_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    closed BOOLEAN NOT NULL DEFAULT false,
+    open_time VARCHAR(5),
+    close_time VARCHAR(5),
+    class VARCHAR(50),
+    kegiatan_id UUID REFERENCES kegiatan(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS submission_exceptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE CHECK (end_date IS NULL OR end_date >= start_date),
+    closed BOOLEAN NOT NULL DEFAULT true,
+    open_time VARCHAR(5),
+    close_time VARCHAR(5),
+    class VARCHAR(50),
+    kegiatan_id UUID REFERENCES kegiatan(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_submission_rules_weekday ON submission_rules(weekday);
+CREATE INDEX IF NOT EXISTS idx_submission_exceptions_dates ON submission_exceptions(start_date, end_date);
+
+DROP TRIGGER IF EXISTS update_submission_rules_updated_at ON submission_rules;
+CREATE TRIGGER update_submission_rules_updated_at BEFORE UPDATE ON submission_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS update_submission_exceptions_updated_at ON submission_exceptions;
+CREATE TRIGGER update_submission_exceptions_updated_at BEFORE UPDATE ON submission_exceptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- The hours of the old window apply to every weekday; a closed window stays
+-- closed until the exception is removed
+INSERT INTO submission_rules (weekday, open_time, close_time)
+SELECT d, w.open_time, w.close_time
+FROM (SELECT * FROM submission_windows ORDER BY created_at LIMIT 1) w
+CROSS JOIN generate_series(0, 6) AS d
+WHERE w.is_open AND (w.open_time IS NOT NULL OR w.close_time IS NOT NULL)
+  AND NOT EXISTS (SELECT 1 FROM submission_rules);
+
+INSERT INTO submission_exceptions (name, start_date, closed)
+SELECT 'Ditutup', CURRENT_DATE, true
+FROM (SELECT * FROM submission_windows ORDER BY created_at LIMIT 1) w
+WHERE NOT w.is_open
+  AND NOT EXISTS (SELECT 1 FROM submission_exceptions);
+
+DROP TABLE IF EXISTS submission_windows;
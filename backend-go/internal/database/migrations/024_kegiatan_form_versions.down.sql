@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_activities_form_version_id;
+ALTER TABLE activities DROP COLUMN IF EXISTS form_version_id;
+ALTER TABLE kegiatan DROP COLUMN IF EXISTS form_version;
+DROP TABLE IF EXISTS kegiatan_form_versions;
+DROP FUNCTION IF EXISTS kegiatan_form_versions_immutable();
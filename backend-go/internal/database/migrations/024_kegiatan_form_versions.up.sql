@@ -0,0 +1,58 @@
+-- Form versions: every form_schema a kegiatan has had, so activities keep
+-- the form they were submitted against when the kegiatan's form changes.
+-- kegiatan.form_schema stays the current form and form_version its number.
+CREATE TABLE IF NOT EXISTS kegiatan_form_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kegiatan_id UUID NOT NULL REFERENCES kegiatan(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0),
+    form_schema JSONB,
+    created_by UUID REFERENCES user_profiles(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kegiatan_id, version)
+);
+
+ALTER TABLE kegiatan ADD COLUMN IF NOT EXISTS form_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE activities ADD COLUMN IF NOT EXISTS form_version_id UUID REFERENCES kegiatan_form_versions(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_activities_form_version_id ON activities(form_version_id);
+
+-- Versions are immutable; they only go away with their kegiatan
+CREATE OR REPLACE FUNCTION kegiatan_form_versions_immutable()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'kegiatan_form_versions is immutable';
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS kegiatan_form_versions_immutable ON kegiatan_form_versions;
+CREATE TRIGGER kegiatan_form_versions_immutable BEFORE UPDATE ON kegiatan_form_versions
+    FOR EACH ROW EXECUTE FUNCTION kegiatan_form_versions_immutable();
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'g7kaih_app') THEN
+        REVOKE UPDATE ON kegiatan_form_versions FROM g7kaih_app;
+    END IF;
+END $$;
+
+-- The current forms become version 1, and existing activities are taken to
+-- have been submitted against them. Filling in the new columns is not an
+-- edit, so updated_at is left alone.
+INSERT INTO kegiatan_form_versions (kegiatan_id, version, form_schema, created_at)
+SELECT id, 1, form_schema, updated_at
+FROM kegiatan
+WHERE form_schema IS NOT NULL
+ON CONFLICT (kegiatan_id, version) DO NOTHING;
+
+ALTER TABLE kegiatan DISABLE TRIGGER update_kegiatan_updated_at;
+ALTER TABLE activities DISABLE TRIGGER update_activities_updated_at;
+
+UPDATE kegiatan k SET form_version = 1
+WHERE k.form_version = 0
+  AND EXISTS (SELECT 1 FROM kegiatan_form_versions v WHERE v.kegiatan_id = k.id);
+
+UPDATE activities a SET form_version_id = v.id
+FROM kegiatan_form_versions v
+WHERE a.form_version_id IS NULL AND v.kegiatan_id = a.kegiatan_id AND v.version = 1;
+
+ALTER TABLE kegiatan ENABLE TRIGGER update_kegiatan_updated_at;
+ALTER TABLE activities ENABLE TRIGGER update_activities_updated_at;
@@ -0,0 +1,4 @@
+DELETE FROM login_lockouts WHERE scope = 'account';
+ALTER TABLE login_lockouts DROP CONSTRAINT IF EXISTS login_lockouts_scope_check;
+ALTER TABLE login_lockouts ADD CONSTRAINT login_lockouts_scope_check
+    CHECK (scope IN ('ip', 'fingerprint'));
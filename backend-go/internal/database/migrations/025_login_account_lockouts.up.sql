@@ -0,0 +1,6 @@
+-- Login failures are also counted per account (username/NIS or the user of
+-- a 2FA challenge), which a client cannot reset by changing its IP or device
+-- ID
+ALTER TABLE login_lockouts DROP CONSTRAINT IF EXISTS login_lockouts_scope_check;
+ALTER TABLE login_lockouts ADD CONSTRAINT login_lockouts_scope_check
+    CHECK (scope IN ('ip', 'fingerprint', 'account'));
@@ -0,0 +1,2 @@
+-- The backfilled usernames stay: staff may already sign in with them
+SELECT 1;
@@ -0,0 +1,45 @@
+-- Staff from before usernames (the seeded admin, accounts that only had a
+-- legacy token) get one the same way the API derives it: the NIS, otherwise
+-- class + the first two words of the name, numbered when taken. Once such an
+-- account sets a password the token login refuses it, so without a username
+-- it could not sign in at all.
+DO $$
+DECLARE
+    r RECORD;
+    word TEXT;
+    base TEXT;
+    candidate TEXT;
+    n INT;
+BEGIN
+    FOR r IN
+        SELECT id, name, class, nis
+        FROM user_profiles
+        WHERE username IS NULL AND role NOT IN ('siswa', 'orangtua')
+        ORDER BY created_at, id
+    LOOP
+        IF r.nis IS NOT NULL AND r.nis <> '' THEN
+            base := lower(trim(r.nis));
+        ELSE
+            base := regexp_replace(lower(coalesce(r.class, '')), '[^a-z0-9]+', '', 'g');
+            FOREACH word IN ARRAY (regexp_split_to_array(lower(trim(r.name)), '\s+'))[1:2] LOOP
+                word := regexp_replace(word, '[^a-z0-9]+', '', 'g');
+                IF word <> '' THEN
+                    base := concat_ws('.', nullif(base, ''), word);
+                END IF;
+            END LOOP;
+            base := rtrim(left(base, 40), '.');
+            IF base = '' THEN
+                base := 'user';
+            END IF;
+        END IF;
+
+        candidate := base;
+        n := 2;
+        WHILE EXISTS (SELECT 1 FROM user_profiles WHERE username = candidate) LOOP
+            candidate := base || n;
+            n := n + 1;
+        END LOOP;
+
+        UPDATE user_profiles SET username = candidate WHERE id = r.id;
+    END LOOP;
+END $$;
@@ -0,0 +1,3 @@
+-- NOT VALID: rows of users purged in the meantime must not block the rollback
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_actor_id_fkey
+    FOREIGN KEY (actor_id) REFERENCES user_profiles(id) ON DELETE SET NULL NOT VALID;
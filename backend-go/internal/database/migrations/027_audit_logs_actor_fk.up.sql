@@ -0,0 +1,5 @@
+-- audit_logs.actor_id no longer references user_profiles. ON DELETE SET NULL
+-- updated the log row, which the append-only trigger refuses, so hard-deleting
+-- anyone who ever acted failed. Users are only soft-deleted; should one be
+-- purged anyway, their log rows keep the id as written.
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_actor_id_fkey;
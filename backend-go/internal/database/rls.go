@@ -0,0 +1,16 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SetRequestUser switches the transaction to the restricted role and sets the
+// app.user_id and app.role variables the row-level security policies read.
+// Both are reset when the transaction ends.
+func SetRequestUser(tx *gorm.DB, dbRole string, userID uuid.UUID, appRole string) error {
+	return tx.Exec(
+		"SELECT set_config('role', ?, true), set_config('app.user_id', ?, true), set_config('app.role', ?, true)",
+		dbRole, userID.String(), appRole,
+	).Error
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const testRLSRole = "g7kaih_app"
+
+// testDB connects to TEST_DATABASE_URL, a database migrated with
+// `server migrate up`. The connection user must own the tables so fixtures
+// bypass RLS.
+func testDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type rlsFixtures struct {
+	studentA, studentB, teacher, parent models.UserProfile
+	activityA, activityB                models.Activity
+	commentB                            models.Comment
+}
+
+// createFixtures adds two students in different classes with one activity
+// each, a teacher of the first class and a parent of the second student
+func createFixtures(t *testing.T, tx *gorm.DB) *rlsFixtures {
+	suffix := uuid.NewString()[:8]
+	classA, classB := "RLS-A-"+suffix, "RLS-B-"+suffix
+	now := time.Now()
+
+	f := &rlsFixtures{
+		studentA: models.UserProfile{ID: uuid.New(), Name: "Siswa A", Class: classA, Role: "siswa"},
+		studentB: models.UserProfile{ID: uuid.New(), Name: "Siswa B", Class: classB, Role: "siswa"},
+		teacher:  models.UserProfile{ID: uuid.New(), Name: "Guru", Class: classA, Role: "guru"},
+		parent:   models.UserProfile{ID: uuid.New(), Name: "Orang Tua", Role: "orangtua"},
+	}
+	for _, p := range []*models.UserProfile{&f.studentA, &f.studentB, &f.teacher, &f.parent} {
+		if err := tx.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	category := models.Category{ID: uuid.New(), Name: "RLS " + suffix}
+	kegiatan := models.Kegiatan{ID: uuid.New(), Name: "RLS", CategoryID: category.ID, IsActive: true}
+	f.activityA = models.Activity{ID: uuid.New(), UserProfileID: f.studentA.ID, KegiatanID: kegiatan.ID, Date: now, Status: "pending"}
+	f.activityB = models.Activity{ID: uuid.New(), UserProfileID: f.studentB.ID, KegiatanID: kegiatan.ID, Date: now, Status: "pending"}
+	f.commentB = models.Comment{ID: uuid.New(), ActivityID: f.activityB.ID, UserProfileID: f.studentB.ID, Content: "punyaku"}
+
+	rows := []interface{}{
+		&category,
+		&kegiatan,
+		&f.activityA,
+		&f.activityB,
+		&f.commentB,
+		&models.TeacherRole{ID: uuid.New(), TeacherID: f.teacher.ID, ClassName: classA},
+		&models.ParentStudent{ID: uuid.New(), ParentID: f.parent.ID, StudentID: f.studentB.ID},
+	}
+	for _, row := range rows {
+		if err := tx.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// asUser runs fn as the given user inside a savepoint, so the role switch
+// and anything fn does are undone afterwards
+func asUser(t *testing.T, tx *gorm.DB, user models.UserProfile, fn func()) {
+	if err := tx.SavePoint("rls").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRequestUser(tx, testRLSRole, user.ID, user.Role); err != nil {
+		t.Fatal(err)
+	}
+	fn()
+	if err := tx.RollbackTo("rls").Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRowLevelSecurityActivities(t *testing.T) {
+	tx := testDB(t).Begin()
+	defer tx.Rollback()
+	f := createFixtures(t, tx)
+	fixtureIDs := []uuid.UUID{f.activityA.ID, f.activityB.ID}
+
+	tests := []struct {
+		name string
+		user models.UserProfile
+		want []uuid.UUID
+	}{
+		{"student sees only own activity", f.studentA, []uuid.UUID{f.activityA.ID}},
+		{"other student sees only own activity", f.studentB, []uuid.UUID{f.activityB.ID}},
+		{"teacher sees activities of the class taught", f.teacher, []uuid.UUID{f.activityA.ID}},
+		{"parent sees activities of the linked child", f.parent, []uuid.UUID{f.activityB.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asUser(t, tx, tt.user, func() {
+				// no user filter, as if the handler forgot it
+				var activities []models.Activity
+				if err := tx.Where("id IN ?", fixtureIDs).Find(&activities).Error; err != nil {
+					t.Fatal(err)
+				}
+				assertIDs(t, activityIDs(activities), tt.want)
+			})
+		})
+	}
+}
+
+func TestRowLevelSecurityUnfilteredQuery(t *testing.T) {
+	tx := testDB(t).Begin()
+	defer tx.Rollback()
+	f := createFixtures(t, tx)
+
+	asUser(t, tx, f.studentA, func() {
+		var activities []models.Activity
+		if err := tx.Find(&activities).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range activities {
+			if a.UserProfileID != f.studentA.ID {
+				t.Errorf("student read activity %s of user %s", a.ID, a.UserProfileID)
+			}
+		}
+
+		var comments []models.Comment
+		if err := tx.Where("activity_id = ?", f.activityB.ID).Find(&comments).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(comments) != 0 {
+			t.Errorf("student read %d comments on another student's activity", len(comments))
+		}
+
+		var links []models.ParentStudent
+		if err := tx.Find(&links).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(links) != 0 {
+			t.Errorf("student read %d parent links of other students", len(links))
+		}
+	})
+}
+
+func TestRowLevelSecurityWrites(t *testing.T) {
+	tx := testDB(t).Begin()
+	defer tx.Rollback()
+	f := createFixtures(t, tx)
+
+	asUser(t, tx, f.studentA, func() {
+		result := tx.Model(&models.Activity{}).Where("id = ?", f.activityB.ID).Update("status", "approved")
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		if result.RowsAffected != 0 {
+			t.Errorf("student updated another student's activity")
+		}
+	})
+
+	asUser(t, tx, f.studentA, func() {
+		forged := models.Activity{ID: uuid.New(), UserProfileID: f.studentB.ID, KegiatanID: f.activityA.KegiatanID, Date: time.Now(), Status: "pending"}
+		if err := tx.Create(&forged).Error; err == nil {
+			t.Errorf("student created an activity for another student")
+		}
+	})
+}
+
+func activityIDs(activities []models.Activity) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(activities))
+	for _, a := range activities {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+func assertIDs(t *testing.T, got, want []uuid.UUID) {
+	t.Helper()
+	sort.Slice(got, func(i, j int) bool { return got[i].String() < got[j].String() })
+	sort.Slice(want, func(i, j int) bool { return want[i].String() < want[j].String() })
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package forms
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Kinds of Change
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Change is how one field differs between two versions of a form.
+// Properties names what changed: label, type, required, options, min, max,
+// pattern, config or category_id. A breaking change may refuse data that
+// the older version accepted.
+type Change struct {
+	Key        string   `json:"key"`
+	Kind       string   `json:"change"`
+	Properties []string `json:"properties,omitempty"`
+	Breaking   bool     `json:"breaking"`
+	Before     *Field   `json:"before,omitempty"`
+	After      *Field   `json:"after,omitempty"`
+}
+
+// Diff lists the fields that were added, removed or changed from before to
+// after, in the order of before followed by the fields after added
+func Diff(before, after *Schema) []Change {
+	changes := []Change{}
+	for i := range before.Fields {
+		old := &before.Fields[i]
+		updated, ok := after.Field(old.Key)
+		if !ok {
+			// Data for a removed field is refused as an unknown key
+			changes = append(changes, Change{Key: old.Key, Kind: Removed, Breaking: len(after.Fields) > 0, Before: old})
+			continue
+		}
+		if properties := differences(old, updated); len(properties) > 0 {
+			changes = append(changes, Change{
+				Key: old.Key, Kind: Changed, Properties: properties,
+				Breaking: breaking(old, updated), Before: old, After: updated,
+			})
+		}
+	}
+	for i := range after.Fields {
+		added := &after.Fields[i]
+		if _, ok := before.Field(added.Key); !ok {
+			changes = append(changes, Change{Key: added.Key, Kind: Added, Breaking: added.Required, After: added})
+		}
+	}
+	return changes
+}
+
+// Breaking reports whether any of the changes is breaking
+func Breaking(changes []Change) bool {
+	for _, change := range changes {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func differences(a, b *Field) []string {
+	var properties []string
+	add := func(name string, differ bool) {
+		if differ {
+			properties = append(properties, name)
+		}
+	}
+	add("label", a.Label != b.Label)
+	add("type", a.Type != b.Type)
+	add("required", a.Required != b.Required)
+	add("options", !sameStrings(a.Options, b.Options))
+	add("min", !sameBound(a.Min, b.Min))
+	add("max", !sameBound(a.Max, b.Max))
+	add("pattern", a.Pattern != b.Pattern)
+	add("config", !sameJSON(a.Config, b.Config))
+	add("category_id", a.CategoryID != b.CategoryID)
+	return properties
+}
+
+// breaking reports whether a value valid for field a may be invalid for b
+func breaking(a, b *Field) bool {
+	if a.Type != b.Type || (b.Required && !a.Required) {
+		return true
+	}
+	if b.Pattern != "" && b.Pattern != a.Pattern {
+		return true
+	}
+	if b.Min != nil && (a.Min == nil || *b.Min > *a.Min) {
+		return true
+	}
+	if b.Max != nil && (a.Max == nil || *b.Max < *a.Max) {
+		return true
+	}
+	// Fewer options refuse the removed ones; options added to a multiselect
+	// that took any choice restrict it
+	if len(b.Options) > 0 {
+		if len(a.Options) == 0 {
+			return true
+		}
+		for _, option := range a.Options {
+			if !b.option(option) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameBound(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameJSON(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return bytes.Equal(a, b)
+	}
+	// Marshalling sorts the keys of objects
+	ex, _ := json.Marshal(x)
+	ey, _ := json.Marshal(y)
+	return bytes.Equal(ex, ey)
+}
@@ -0,0 +1,104 @@
+package forms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := &Schema{Fields: []Field{
+		{Key: "jam", Label: "Jam", Type: TypeTime, Required: true},
+		{Key: "menu", Label: "Menu", Type: TypeSelect, Options: []string{"Nasi", "Roti"}},
+		{Key: "porsi", Label: "Porsi", Type: TypeNumber, Max: number(3)},
+		{Key: "catatan", Label: "Catatan", Type: TypeText},
+		{Key: "lama", Label: "Lama", Type: TypeText},
+	}}
+	after := &Schema{Fields: []Field{
+		{Key: "jam", Label: "Jam Bangun", Type: TypeTime, Required: true},
+		{Key: "menu", Label: "Menu", Type: TypeSelect, Options: []string{"Nasi", "Roti", "Mie"}},
+		{Key: "porsi", Label: "Porsi", Type: TypeNumber, Max: number(2)},
+		{Key: "catatan", Label: "Catatan", Type: TypeText, Required: true},
+		{Key: "foto", Label: "Foto", Type: TypeImage},
+		{Key: "bukti", Label: "Bukti", Type: TypeImage, Required: true},
+	}}
+
+	type summary struct {
+		kind       string
+		properties []string
+		breaking   bool
+	}
+	want := map[string]summary{
+		"jam":     {Changed, []string{"label"}, false},
+		"menu":    {Changed, []string{"options"}, false},
+		"porsi":   {Changed, []string{"max"}, true},
+		"catatan": {Changed, []string{"required"}, true},
+		"lama":    {Removed, nil, true},
+		"foto":    {Added, nil, false},
+		"bukti":   {Added, nil, true},
+	}
+
+	changes := Diff(before, after)
+	if len(changes) != len(want) {
+		t.Fatalf("Diff = %+v, want %d changes", changes, len(want))
+	}
+	order := []string{"jam", "menu", "porsi", "catatan", "lama", "foto", "bukti"}
+	for i, change := range changes {
+		if change.Key != order[i] {
+			t.Errorf("change %d is %s, want %s", i, change.Key, order[i])
+		}
+		w := want[change.Key]
+		got := summary{change.Kind, change.Properties, change.Breaking}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("%s: %+v, want %+v", change.Key, got, w)
+		}
+	}
+	if !Breaking(changes) {
+		t.Error("Breaking = false, want true")
+	}
+
+	if changes := Diff(before, before); len(changes) != 0 {
+		t.Errorf("Diff of a form with itself = %+v", changes)
+	}
+	if changes := Diff(&Schema{}, before); len(changes) != len(before.Fields) || !Breaking(changes) {
+		t.Errorf("Diff from an empty form = %+v", changes)
+	}
+}
+
+func TestBreaking(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after Field
+		want          bool
+	}{
+		{"type", Field{Type: TypeText}, Field{Type: TypeNumber}, true},
+		{"no longer required", Field{Type: TypeText, Required: true}, Field{Type: TypeText}, false},
+		{"new pattern", Field{Type: TypeText}, Field{Type: TypeText, Pattern: "[0-9]+"}, true},
+		{"pattern dropped", Field{Type: TypeText, Pattern: "[0-9]+"}, Field{Type: TypeText}, false},
+		{"min raised", Field{Type: TypeNumber, Min: number(1)}, Field{Type: TypeNumber, Min: number(2)}, true},
+		{"min lowered", Field{Type: TypeNumber, Min: number(2)}, Field{Type: TypeNumber, Min: number(1)}, false},
+		{"max removed", Field{Type: TypeNumber, Max: number(2)}, Field{Type: TypeNumber}, false},
+		{"option removed", Field{Type: TypeSelect, Options: []string{"a", "b"}}, Field{Type: TypeSelect, Options: []string{"a"}}, true},
+		{"options on a free multiselect", Field{Type: TypeMultiselect}, Field{Type: TypeMultiselect, Options: []string{"a"}}, true},
+	}
+	for _, tt := range tests {
+		if got := breaking(&tt.before, &tt.after); got != tt.want {
+			t.Errorf("%s: breaking = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSame(t *testing.T) {
+	a := `{"fields": [{"key": "jam", "type": "time"}]}`
+	b := `{"fields":[{"type":"time","key":"jam"}]}`
+	c := `{"fields": [{"key": "jam", "type": "text"}]}`
+	blank := "  "
+	if !Same(&a, &b) {
+		t.Error("formatting and key order should not matter")
+	}
+	if Same(&a, &c) {
+		t.Error("different forms reported the same")
+	}
+	if !Same(nil, &blank) || Same(nil, &a) {
+		t.Error("a blank form is only the same as another blank form")
+	}
+}
@@ -0,0 +1,98 @@
+// Package forms describes the form of a kegiatan: the fields, stored as
+// form_schema, that students fill in and that make up an activity's
+// form_data.
+package forms
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Field types. The first five are the ones the Next.js category inputs used.
+const (
+	TypeText        = "text"
+	TypeTime        = "time"
+	TypeImage       = "image"
+	TypeTextImage   = "text_image"
+	TypeMultiselect = "multiselect"
+	TypeNumber      = "number"
+	TypeSelect      = "select"
+	TypeDate        = "date"
+)
+
+// Types lists the field types a form may use
+var Types = []string{TypeText, TypeTime, TypeImage, TypeTextImage, TypeMultiselect, TypeNumber, TypeSelect, TypeDate}
+
+// LegacyTypes lists the field types the Next.js category editor offered
+var LegacyTypes = Types[:5]
+
+// Field is one input of a form. Its value is stored in form_data under Key.
+type Field struct {
+	Key      string          `json:"key"`
+	Label    string          `json:"label"`
+	Type     string          `json:"type"`
+	Required bool            `json:"required"`
+	Options  []string        `json:"options,omitempty"`
+	Config   json.RawMessage `json:"config,omitempty"`
+
+	// Min and Max bound a number, the length of a text or the number of
+	// multiselect choices. Pattern is a regular expression a text must
+	// match as a whole.
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+
+	// CategoryID is the category the field belongs to. The Next.js app
+	// defined fields per category; forms imported from it, or edited
+	// through the compatibility API, keep that grouping.
+	CategoryID string `json:"category_id,omitempty"`
+}
+
+// Schema is the form_schema of a kegiatan: {"fields": [...]}
+type Schema struct {
+	Fields []Field `json:"fields"`
+}
+
+// Parse reads a form_schema. A nil or blank schema is a form without fields.
+func Parse(raw *string) (*Schema, error) {
+	schema := &Schema{Fields: []Field{}}
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return schema, nil
+	}
+	if err := json.Unmarshal([]byte(*raw), schema); err != nil {
+		return nil, err
+	}
+	if schema.Fields == nil {
+		schema.Fields = []Field{}
+	}
+	return schema, nil
+}
+
+// Encode returns the schema as stored in form_schema
+func (s *Schema) Encode() (string, error) {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// Field returns the field with the key
+func (s *Schema) Field(key string) (*Field, bool) {
+	for i := range s.Fields {
+		if s.Fields[i].Key == key {
+			return &s.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// ValidType reports whether t is one of Types
+func ValidType(t string) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
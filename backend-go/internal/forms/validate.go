@@ -0,0 +1,236 @@
+package forms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with one field of a form or of its data. Field is
+// the field key, or for schema problems its position, e.g. "fields[2]".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ParseData reads a form_data. A nil or blank value is an empty form; any
+// other value must be a JSON object.
+func ParseData(raw *string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return data, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(*raw), &value); err != nil {
+		return nil, errors.New("form_data must be valid JSON")
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("form_data must be a JSON object")
+	}
+	return object, nil
+}
+
+// pattern compiles a field pattern, which must match the whole value
+func pattern(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// Check validates the schema itself: every field needs a unique key and a
+// known type, a select needs options, and min, max and pattern must make
+// sense.
+func (s *Schema) Check() []FieldError {
+	var errs []FieldError
+	seen := make(map[string]bool)
+	for i, field := range s.Fields {
+		at := fmt.Sprintf("fields[%d]", i)
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, FieldError{Field: at, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if strings.TrimSpace(field.Key) == "" {
+			fail("key is required")
+		} else if seen[field.Key] {
+			fail("duplicate key %q", field.Key)
+		}
+		seen[field.Key] = true
+
+		if !ValidType(field.Type) {
+			fail("type must be one of %s", strings.Join(Types, ", "))
+			continue
+		}
+		if field.Type == TypeSelect && len(field.Options) == 0 {
+			fail("a select field needs options")
+		}
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			fail("min must not be greater than max")
+		}
+		if field.Pattern != "" {
+			if field.Type != TypeText && field.Type != TypeTextImage {
+				fail("pattern only applies to text fields")
+			} else if _, err := pattern(field.Pattern); err != nil {
+				fail("pattern is not a valid regular expression")
+			}
+		}
+	}
+	return errs
+}
+
+// Validate checks form data against the schema. uploaded holds the keys of
+// fields that received a file, which satisfies a required image field. Keys
+// the form does not define are refused, unless the form has no fields.
+func (s *Schema) Validate(data map[string]interface{}, uploaded map[string]bool) []FieldError {
+	var errs []FieldError
+	for _, field := range s.Fields {
+		value, present := data[field.Key]
+		if !present || empty(value) {
+			if field.Required && !uploaded[field.Key] {
+				errs = append(errs, FieldError{Field: field.Key, Message: "is required"})
+			}
+			continue
+		}
+		if message := field.check(value); message != "" {
+			errs = append(errs, FieldError{Field: field.Key, Message: message})
+		}
+	}
+	if len(s.Fields) > 0 {
+		for key := range data {
+			if _, ok := s.Field(key); !ok {
+				errs = append(errs, FieldError{Field: key, Message: "is not a field of this form"})
+			}
+		}
+	}
+	return errs
+}
+
+// empty reports whether a value counts as not filled in
+func empty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// check returns why a filled-in value is invalid for the field, or ""
+func (f *Field) check(value interface{}) string {
+	switch f.Type {
+	case TypeText:
+		text, ok := value.(string)
+		if !ok {
+			return "must be text"
+		}
+		return f.checkText(text)
+	case TypeNumber:
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+				return "must be a number"
+			}
+			number = parsed
+		default:
+			return "must be a number"
+		}
+		if f.Min != nil && number < *f.Min {
+			return fmt.Sprintf("must be at least %v", *f.Min)
+		}
+		if f.Max != nil && number > *f.Max {
+			return fmt.Sprintf("must be at most %v", *f.Max)
+		}
+	case TypeTime:
+		text, ok := value.(string)
+		if !ok || !(parses("15:04", text) || parses("15:04:05", text)) {
+			return "must be a time in HH:MM format"
+		}
+	case TypeDate:
+		text, ok := value.(string)
+		if !ok || !parses("2006-01-02", text) {
+			return "must be a date in YYYY-MM-DD format"
+		}
+	case TypeSelect:
+		text, ok := value.(string)
+		if !ok || !f.option(text) {
+			return "must be one of " + strings.Join(f.Options, ", ")
+		}
+	case TypeMultiselect:
+		list, ok := value.([]interface{})
+		if !ok {
+			return "must be a list"
+		}
+		// Multiselects imported without options take any choices
+		for _, item := range list {
+			if text, ok := item.(string); !ok || (len(f.Options) > 0 && !f.option(text)) {
+				return "may only contain " + strings.Join(f.Options, ", ")
+			}
+		}
+		if f.Min != nil && float64(len(list)) < *f.Min {
+			return fmt.Sprintf("needs at least %v choices", *f.Min)
+		}
+		if f.Max != nil && float64(len(list)) > *f.Max {
+			return fmt.Sprintf("allows at most %v choices", *f.Max)
+		}
+	case TypeTextImage:
+		// Either the text, or an object holding it next to the image
+		switch v := value.(type) {
+		case string:
+			return f.checkText(v)
+		case map[string]interface{}:
+			if text, ok := v["text"].(string); ok {
+				return f.checkText(text)
+			}
+		default:
+			return "must be text"
+		}
+	}
+	// Image values are references to uploaded files and are not checked
+	return ""
+}
+
+// checkText applies the length limits and the pattern to a text value
+func (f *Field) checkText(text string) string {
+	length := float64(len([]rune(text)))
+	if f.Min != nil && length < *f.Min {
+		return fmt.Sprintf("must be at least %v characters", *f.Min)
+	}
+	if f.Max != nil && length > *f.Max {
+		return fmt.Sprintf("must be at most %v characters", *f.Max)
+	}
+	if f.Pattern != "" {
+		re, err := pattern(f.Pattern)
+		if err != nil || !re.MatchString(text) {
+			return "does not match the expected format"
+		}
+	}
+	return ""
+}
+
+func (f *Field) option(value string) bool {
+	for _, option := range f.Options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+func parses(layout, value string) bool {
+	_, err := time.Parse(layout, value)
+	return err == nil && len(value) == len(layout)
+}
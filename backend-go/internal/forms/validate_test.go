@@ -0,0 +1,136 @@
+package forms
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func number(n float64) *float64 {
+	return &n
+}
+
+func schema(t *testing.T, raw string) *Schema {
+	t.Helper()
+	s, err := Parse(&raw)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", raw, err)
+	}
+	return s
+}
+
+func TestParseData(t *testing.T) {
+	for _, raw := range []string{"", "  ", `{}`, `{"a": 1}`} {
+		if _, err := ParseData(&raw); err != nil {
+			t.Errorf("ParseData(%q): %v", raw, err)
+		}
+	}
+	for _, raw := range []string{`{`, `[]`, `"text"`, `null`} {
+		if _, err := ParseData(&raw); err == nil {
+			t.Errorf("ParseData(%q) accepted", raw)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	valid := schema(t, `{"fields": [
+		{"key": "jam", "label": "Jam", "type": "time", "required": true},
+		{"key": "menu", "label": "Menu", "type": "select", "options": ["Nasi", "Roti"]},
+		{"key": "porsi", "label": "Porsi", "type": "number", "min": 1, "max": 3},
+		{"key": "nisn", "label": "NISN", "type": "text", "pattern": "[0-9]{10}"}
+	]}`)
+	if errs := valid.Check(); len(errs) > 0 {
+		t.Errorf("valid schema refused: %v", errs)
+	}
+
+	for raw, want := range map[string]string{
+		`{"fields": [{"label": "A", "type": "text"}]}`:                             "fields[0]: key is required",
+		`{"fields": [{"key": "a", "type": "text"}, {"key": "a", "type": "time"}]}`: `fields[1]: duplicate key "a"`,
+		`{"fields": [{"key": "a", "type": "color"}]}`:                              "fields[0]: type must be one of text, time, image, text_image, multiselect, number, select, date",
+		`{"fields": [{"key": "a", "type": "select"}]}`:                             "fields[0]: a select field needs options",
+		`{"fields": [{"key": "a", "type": "number", "min": 5, "max": 1}]}`:         "fields[0]: min must not be greater than max",
+		`{"fields": [{"key": "a", "type": "text", "pattern": "("}]}`:               "fields[0]: pattern is not a valid regular expression",
+		`{"fields": [{"key": "a", "type": "number", "pattern": "[0-9]+"}]}`:        "fields[0]: pattern only applies to text fields",
+	} {
+		errs := schema(t, raw).Check()
+		if len(errs) != 1 || errs[0].Error() != want {
+			t.Errorf("Check(%s) = %v, want %q", raw, errs, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := &Schema{Fields: []Field{
+		{Key: "jam", Type: TypeTime, Required: true},
+		{Key: "tanggal", Type: TypeDate},
+		{Key: "menu", Type: TypeSelect, Options: []string{"Nasi", "Roti"}},
+		{Key: "olahraga", Type: TypeMultiselect, Options: []string{"Lari", "Renang", "Senam"}, Max: number(2)},
+		{Key: "tag", Type: TypeMultiselect},
+		{Key: "porsi", Type: TypeNumber, Min: number(1), Max: number(3)},
+		{Key: "nisn", Type: TypeText, Pattern: "[0-9]{10}"},
+		{Key: "cerita", Type: TypeText, Max: number(5)},
+		{Key: "foto", Type: TypeImage, Required: true},
+		{Key: "bukti", Type: TypeTextImage, Min: number(3)},
+	}}
+	base := map[string]interface{}{"jam": "05:30", "foto": "https://example.com/foto.jpg"}
+
+	tests := []struct {
+		name     string
+		data     string
+		uploaded map[string]bool
+		want     map[string]string
+	}{
+		{"minimal", `{}`, nil, nil},
+		{"all valid", `{"tanggal": "2026-03-02", "menu": "Nasi", "olahraga": ["Lari", "Senam"], "tag": ["apa saja"], "porsi": 2, "nisn": "0012345678", "cerita": "Makan", "bukti": {"text": "Sudah"}}`, nil, nil},
+		{"numeric string", `{"porsi": "3"}`, nil, nil},
+		{"missing required", `{"jam": "", "foto": null}`, nil, map[string]string{"jam": "is required", "foto": "is required"}},
+		{"uploaded image", `{"foto": null}`, map[string]bool{"foto": true}, nil},
+		{"bad time", `{"jam": "5:30"}`, nil, map[string]string{"jam": "must be a time in HH:MM format"}},
+		{"bad date", `{"tanggal": "02-03-2026"}`, nil, map[string]string{"tanggal": "must be a date in YYYY-MM-DD format"}},
+		{"unknown option", `{"menu": "Mie"}`, nil, map[string]string{"menu": "must be one of Nasi, Roti"}},
+		{"unknown choice", `{"olahraga": ["Lari", "Voli"]}`, nil, map[string]string{"olahraga": "may only contain Lari, Renang, Senam"}},
+		{"too many choices", `{"olahraga": ["Lari", "Renang", "Senam"]}`, nil, map[string]string{"olahraga": "allows at most 2 choices"}},
+		{"below min", `{"porsi": 0}`, nil, map[string]string{"porsi": "must be at least 1"}},
+		{"above max", `{"porsi": "4"}`, nil, map[string]string{"porsi": "must be at most 3"}},
+		{"not a number", `{"porsi": "dua"}`, nil, map[string]string{"porsi": "must be a number"}},
+		{"pattern", `{"nisn": "12345"}`, nil, map[string]string{"nisn": "does not match the expected format"}},
+		{"pattern is anchored", `{"nisn": "x0012345678"}`, nil, map[string]string{"nisn": "does not match the expected format"}},
+		{"too long", `{"cerita": "Makan pagi"}`, nil, map[string]string{"cerita": "must be at most 5 characters"}},
+		{"text_image text", `{"bukti": "ok"}`, nil, map[string]string{"bukti": "must be at least 3 characters"}},
+		{"wrong type", `{"cerita": 5}`, nil, map[string]string{"cerita": "must be text"}},
+		{"unknown key", `{"lain": 1}`, nil, map[string]string{"lain": "is not a field of this form"}},
+	}
+
+	for _, tt := range tests {
+		data := map[string]interface{}{}
+		for k, v := range base {
+			data[k] = v
+		}
+		var extra map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.data), &extra); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range extra {
+			data[k] = v
+		}
+
+		got := map[string]string{}
+		for _, err := range s.Validate(data, tt.uploaded) {
+			got[err.Field] = err.Message
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: errors = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for field, message := range tt.want {
+			if got[field] != message {
+				t.Errorf("%s: %s = %q, want %q", tt.name, field, got[field], message)
+			}
+		}
+	}
+}
+
+func TestValidateWithoutFields(t *testing.T) {
+	if errs := (&Schema{}).Validate(map[string]interface{}{"anything": "goes"}, nil); len(errs) > 0 {
+		t.Errorf("a form without fields refused data: %v", errs)
+	}
+}
@@ -0,0 +1,86 @@
+package forms
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Publish makes the kegiatan's form_schema its current form version. A form
+// that differs from the latest version becomes the next version; versions
+// are never changed. Call it after the kegiatan is saved. It returns the
+// current version, or nil while the kegiatan never had a form.
+func Publish(db *gorm.DB, kegiatan *models.Kegiatan, author *uuid.UUID) (*models.KegiatanFormVersion, error) {
+	var latest models.KegiatanFormVersion
+	err := db.Where("kegiatan_id = ?", kegiatan.ID).Order("version DESC").First(&latest).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if blank(kegiatan.FormSchema) {
+			return nil, nil
+		}
+	case err != nil:
+		return nil, err
+	case Same(latest.FormSchema, kegiatan.FormSchema):
+		if kegiatan.FormVersion != latest.Version {
+			if err := setVersion(db, kegiatan, latest.Version); err != nil {
+				return nil, err
+			}
+		}
+		return &latest, nil
+	}
+
+	version := models.KegiatanFormVersion{
+		KegiatanID: kegiatan.ID,
+		Version:    latest.Version + 1,
+		FormSchema: kegiatan.FormSchema,
+		CreatedBy:  author,
+	}
+	if blank(version.FormSchema) {
+		version.FormSchema = nil
+	}
+	if err := db.Create(&version).Error; err != nil {
+		return nil, err
+	}
+	if err := setVersion(db, kegiatan, version.Version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func setVersion(db *gorm.DB, kegiatan *models.Kegiatan, version int) error {
+	if err := db.Model(&models.Kegiatan{}).Where("id = ?", kegiatan.ID).UpdateColumn("form_version", version).Error; err != nil {
+		return err
+	}
+	kegiatan.FormVersion = version
+	return nil
+}
+
+// Current returns the current form version of a kegiatan, or nil when it
+// has no form
+func Current(db *gorm.DB, kegiatan *models.Kegiatan) (*models.KegiatanFormVersion, error) {
+	if kegiatan.FormVersion == 0 {
+		return nil, nil
+	}
+	var version models.KegiatanFormVersion
+	if err := db.Where("kegiatan_id = ? AND version = ?", kegiatan.ID, kegiatan.FormVersion).First(&version).Error; err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// Same reports whether two form_schemas describe the same form, ignoring
+// formatting and key order
+func Same(a, b *string) bool {
+	if blank(a) || blank(b) {
+		return blank(a) && blank(b)
+	}
+	return sameJSON(json.RawMessage(*a), json.RawMessage(*b))
+}
+
+func blank(raw *string) bool {
+	return raw == nil || strings.TrimSpace(*raw) == ""
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SetUserStatusRequest changes whether an account may log in. Status is one
+// of active, suspended, graduated or transferred.
+type SetUserStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// BulkSetUserStatusRequest changes the status of every user in a class,
+// optionally only those with the given role (e.g. graduating a whole class
+// of students)
+type BulkSetUserStatusRequest struct {
+	Class  string `json:"class" binding:"required"`
+	Role   string `json:"role"`
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+type BulkSetUserStatusResponse struct {
+	Status  string      `json:"status"`
+	Updated int         `json:"updated"`
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+// setStatus stores the new status of the users and, unless it is active,
+// signs them out everywhere. It runs in one transaction.
+func (h *AdminHandler) setStatus(c *gin.Context, ids []uuid.UUID, status, reason string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            status,
+		"status_reason":     nil,
+		"status_changed_at": now,
+		"status_changed_by": nil,
+		"updated_at":        now,
+	}
+	if reason != "" {
+		updates["status_reason"] = reason
+	}
+	if adminID, err := middleware.GetUserID(c); err == nil {
+		updates["status_changed_by"] = adminID
+	}
+
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserProfile{}).Where("id IN ?", ids).Updates(updates).Error; err != nil {
+			return err
+		}
+		if status == auth.StatusActive {
+			return nil
+		}
+		sessions := h.sessions.WithTx(tx)
+		for _, id := range ids {
+			if err := sessions.RevokeAll(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetUserStatus godoc
+// @Summary Set account status
+// @Description Suspend, graduate, transfer or reactivate a user. Accounts that are not active cannot log in and are signed out everywhere, but keep their history.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param status body SetUserStatusRequest true "Status and reason"
+// @Success 200 {object} models.UserProfile
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/status [put]
+func (h *AdminHandler) SetUserStatus(c *gin.Context) {
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", c.Param("id")).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req SetUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !auth.ValidAccountStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status", "valid_statuses": auth.AccountStatuses})
+		return
+	}
+	if adminID, err := middleware.GetUserID(c); err == nil && adminID == profile.ID && req.Status != auth.StatusActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate your own account"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile) {
+		return
+	}
+
+	before := gin.H{"status": profile.Status, "status_reason": profile.StatusReason}
+	if err := h.setStatus(c, []uuid.UUID{profile.ID}, req.Status, strings.TrimSpace(req.Reason)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		return
+	}
+
+	if err := h.db.First(&profile, "id = ?", profile.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     "user.status",
+		TargetType: "user",
+		TargetID:   profile.ID.String(),
+		Before:     before,
+		After:      gin.H{"status": profile.Status, "status_reason": profile.StatusReason},
+	})
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// BulkSetUserStatus godoc
+// @Summary Set account status for a class
+// @Description Change the status of every user in a class, optionally limited to one role, e.g. graduating all students of 9A. The requesting admin and users with roles they cannot manage are skipped.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BulkSetUserStatusRequest true "Class, role, status and reason"
+// @Success 200 {object} BulkSetUserStatusResponse
+// @Router /admin/users/status [post]
+func (h *AdminHandler) BulkSetUserStatus(c *gin.Context) {
+	var req BulkSetUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Class = strings.TrimSpace(req.Class)
+	if req.Class == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "class is required"})
+		return
+	}
+	if !auth.ValidAccountStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status", "valid_statuses": auth.AccountStatuses})
+		return
+	}
+
+	roles, err := manageableRoleNames(c, h.db, h.permissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	query := scopeManageableUsers(h.db.Model(&models.UserProfile{}), roles).
+		Where("class = ? AND status <> ?", req.Class, req.Status)
+	if req.Role != "" {
+		query = query.Where("role = ?", req.Role)
+	}
+	if adminID, err := middleware.GetUserID(c); err == nil {
+		query = query.Where("id <> ?", adminID)
+	}
+
+	var ids []uuid.UUID
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	if len(ids) > 0 {
+		if err := h.setStatus(c, ids, req.Status, strings.TrimSpace(req.Reason)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+			return
+		}
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     "user.bulk_status",
+		TargetType: "user",
+		Details:    gin.H{"class": req.Class, "role": req.Role, "status": req.Status, "reason": req.Reason, "user_ids": ids},
+	})
+
+	c.JSON(http.StatusOK, BulkSetUserStatusResponse{
+		Status:  req.Status,
+		Updated: len(ids),
+		UserIDs: ids,
+	})
+}
@@ -5,19 +5,26 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/forms"
 	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/policy"
+	"github.com/FirstTirr/G7KAIH-GO/internal/submission"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ActivityHandler serves activities. Students may only create, edit and
+// delete their own activities while the submission schedule is open.
 type ActivityHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	schedule *submission.Scheduler
 }
 
-func NewActivityHandler(db *gorm.DB) *ActivityHandler {
-	return &ActivityHandler{db: db}
+func NewActivityHandler(db *gorm.DB, schedule *submission.Scheduler) *ActivityHandler {
+	return &ActivityHandler{db: db, schedule: schedule}
 }
 
 type CreateActivityRequest struct {
@@ -34,10 +41,20 @@ type UpdateActivityRequest struct {
 	Notes    *string `json:"notes"`
 }
 
+// GetActivities lists the activities the user may see: their own, and those
+// of students they teach, supervise or are the parent of
 func (h *ActivityHandler) GetActivities(c *gin.Context) {
-	query := h.db.Model(&models.Activity{}).
+	db := middleware.DB(c, h.db)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
+
+	query := subject.ScopeActivities(db.Model(&models.Activity{})).
 		Preload("UserProfile").
 		Preload("Kegiatan").
+		Preload("Form").
 		Preload("Comments.UserProfile")
 
 	if userID := c.Query("user_id"); userID != "" {
@@ -79,14 +96,22 @@ func (h *ActivityHandler) GetActivities(c *gin.Context) {
 }
 
 func (h *ActivityHandler) GetActivity(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	id := c.Param("id")
 
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
+
 	var activity models.Activity
-	if err := h.db.Preload("UserProfile").
+	if err := db.Preload("UserProfile").
 		Preload("Kegiatan").
+		Preload("Form").
 		Preload("Comments.UserProfile").
 		Where("id = ?", id).
-		First(&activity).Error; err != nil {
+		First(&activity).Error; err != nil || !subject.Can(policy.ActionView, &activity) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Activity not found"})
 		return
 	}
@@ -95,6 +120,8 @@ func (h *ActivityHandler) GetActivity(c *gin.Context) {
 }
 
 func (h *ActivityHandler) CreateActivity(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -113,50 +140,76 @@ func (h *ActivityHandler) CreateActivity(c *gin.Context) {
 		return
 	}
 
+	if !checkSubmissionWindow(c, db, h.schedule, userID, req.KegiatanID) {
+		return
+	}
+
 	var kegiatan models.Kegiatan
-	if err := h.db.Where("id = ? AND is_active = ?", req.KegiatanID, true).First(&kegiatan).Error; err != nil {
+	if err := db.Where("id = ? AND is_active = ?", req.KegiatanID, true).First(&kegiatan).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Kegiatan not found or inactive"})
 		return
 	}
+	if !checkFormData(c, &kegiatan, req.FormData) {
+		return
+	}
+	formVersionID, err := currentFormVersion(db, &kegiatan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load form version"})
+		return
+	}
 
 	activity := models.Activity{
 		UserProfileID: userID,
 		KegiatanID:    req.KegiatanID,
 		Date:          date,
 		FormData:      req.FormData,
+		FormVersionID: formVersionID,
 		Notes:         req.Notes,
 		Status:        "pending",
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
-	if err := h.db.Create(&activity).Error; err != nil {
+	if err := db.Create(&activity).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create activity"})
 		return
 	}
 
-	h.db.Preload("UserProfile").
+	db.Preload("UserProfile").
 		Preload("Kegiatan").
+		Preload("Form").
 		First(&activity, activity.ID)
 
 	c.JSON(http.StatusCreated, activity)
 }
 
 func (h *ActivityHandler) UpdateActivity(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	id := c.Param("id")
-	userID, _ := middleware.GetUserID(c)
-	userRole, _ := middleware.GetUserRole(c)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
 
 	var activity models.Activity
-	if err := h.db.Where("id = ?", id).First(&activity).Error; err != nil {
+	if err := db.Preload("UserProfile").Where("id = ?", id).First(&activity).Error; err != nil || !subject.Can(policy.ActionView, &activity) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Activity not found"})
 		return
 	}
 
-	if activity.UserProfileID != userID && userRole != "admin" && userRole != "guru" && userRole != "guruwali" {
+	if !subject.Can(policy.ActionUpdate, &activity) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
+	canReview := subject.Can(policy.ActionReview, &activity)
+	reviewing := activity.UserProfileID != subject.UserID
+	if !reviewing && !checkSubmissionWindow(c, db, h.schedule, subject.UserID, activity.KegiatanID) {
+		return
+	}
+	activity.UserProfile = nil
+	before := activity
 
 	var req UpdateActivityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -174,11 +227,26 @@ func (h *ActivityHandler) UpdateActivity(c *gin.Context) {
 	}
 
 	if req.FormData != nil {
+		var kegiatan models.Kegiatan
+		if err := db.First(&kegiatan, "id = ?", activity.KegiatanID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load kegiatan"})
+			return
+		}
+		if !checkFormData(c, &kegiatan, req.FormData) {
+			return
+		}
+		// The new data was checked against the current form
+		formVersionID, err := currentFormVersion(db, &kegiatan)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load form version"})
+			return
+		}
 		activity.FormData = req.FormData
+		activity.FormVersionID = formVersionID
 	}
 
 	if req.Status != nil {
-		if userRole == "admin" || userRole == "guru" || userRole == "guruwali" {
+		if canReview {
 			activity.Status = *req.Status
 		}
 	}
@@ -189,13 +257,18 @@ func (h *ActivityHandler) UpdateActivity(c *gin.Context) {
 
 	activity.UpdatedAt = time.Now()
 
-	if err := h.db.Save(&activity).Error; err != nil {
+	if err := db.Save(&activity).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update activity"})
 		return
 	}
+	// students editing their own activities are routine; reviews are audited
+	if reviewing {
+		audit.Record(c, audit.Entry{Action: "activity.review", TargetType: "activity", TargetID: activity.ID.String(), Before: before, After: activity})
+	}
 
-	h.db.Preload("UserProfile").
+	db.Preload("UserProfile").
 		Preload("Kegiatan").
+		Preload("Form").
 		Preload("Comments.UserProfile").
 		First(&activity, activity.ID)
 
@@ -203,25 +276,67 @@ func (h *ActivityHandler) UpdateActivity(c *gin.Context) {
 }
 
 func (h *ActivityHandler) DeleteActivity(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	id := c.Param("id")
-	userID, _ := middleware.GetUserID(c)
-	userRole, _ := middleware.GetUserRole(c)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
 
 	var activity models.Activity
-	if err := h.db.Where("id = ?", id).First(&activity).Error; err != nil {
+	if err := db.Preload("UserProfile").Where("id = ?", id).First(&activity).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Activity not found"})
 		return
 	}
 
-	if activity.UserProfileID != userID && userRole != "admin" {
+	if !subject.Can(policy.ActionDelete, &activity) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
+	if activity.UserProfileID == subject.UserID && !checkSubmissionWindow(c, db, h.schedule, subject.UserID, activity.KegiatanID) {
+		return
+	}
 
-	if err := h.db.Delete(&activity).Error; err != nil {
+	if err := db.Delete(&activity).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete activity"})
 		return
 	}
+	if activity.UserProfileID != subject.UserID {
+		activity.UserProfile = nil
+		audit.Record(c, audit.Entry{Action: "activity.delete", TargetType: "activity", TargetID: activity.ID.String(), Before: activity})
+	}
 
 	c.Status(http.StatusNoContent)
-}
\ No newline at end of file
+}
+
+// checkFormData validates form_data against the form of the kegiatan and
+// answers 400 with the problems of each field when it does not fit
+func checkFormData(c *gin.Context, kegiatan *models.Kegiatan, raw *string) bool {
+	schema, err := forms.Parse(kegiatan.FormSchema)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid form schema"})
+		return false
+	}
+	data, err := forms.ParseData(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if errs := schema.Validate(data, nil); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form_data", "fields": errs})
+		return false
+	}
+	return true
+}
+
+// currentFormVersion returns the ID of the kegiatan's current form version,
+// which new form_data is submitted against, or nil without a form
+func currentFormVersion(db *gorm.DB, kegiatan *models.Kegiatan) (*uuid.UUID, error) {
+	version, err := forms.Current(db, kegiatan)
+	if err != nil || version == nil {
+		return nil, err
+	}
+	return &version.ID, nil
+}
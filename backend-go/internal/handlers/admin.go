@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"encoding/csv"
-	"fmt"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,22 +15,37 @@ import (
 )
 
 type AdminHandler struct {
-	db *gorm.DB
+	db          *gorm.DB
+	sessions    *auth.SessionStore
+	permissions *auth.PermissionStore
 }
 
-func NewAdminHandler(db *gorm.DB) *AdminHandler {
-	return &AdminHandler{db: db}
+func NewAdminHandler(db *gorm.DB, sessions *auth.SessionStore, permissions *auth.PermissionStore) *AdminHandler {
+	return &AdminHandler{db: db, sessions: sessions, permissions: permissions}
 }
 
 type CreateUserRequest struct {
-	Name  string `json:"name" binding:"required"`
-	Class string `json:"class" binding:"required"`
-	Role  string `json:"role" binding:"required,oneof=siswa orangtua guru guruwali admin"`
+	Name  string  `json:"name" binding:"required"`
+	Class string  `json:"class" binding:"required"`
+	NIS   *string `json:"nis"`
+	Role  string  `json:"role" binding:"required"`
+}
+
+// SetUserRolesRequest is the full list of roles a user should hold
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required,min=1"`
+}
+
+type UserRolesResponse struct {
+	UserID      string   `json:"user_id"`
+	PrimaryRole string   `json:"primary_role"`
+	Roles       []string `json:"roles"`
 }
 
 type CreateUserResponse struct {
-	UserID string `json:"user_id"`
-	Token  string `json:"token"` // 4-digit token
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	PIN      string `json:"pin"`
 }
 
 type LinkParentStudentRequest struct {
@@ -48,14 +64,6 @@ type AssignTeacherRoleRequest struct {
 	Subject   *string   `json:"subject"`
 }
 
-type UpdateSubmissionWindowRequest struct {
-	IsOpen    *bool   `json:"is_open"`
-	OpenTime  *string `json:"open_time"`
-	CloseTime *string `json:"close_time"`
-}
-
-
-
 // GetUsers godoc
 // @Summary Get all users
 // @Description Get list of all users with optional filters
@@ -64,6 +72,7 @@ type UpdateSubmissionWindowRequest struct {
 // @Security BearerAuth
 // @Param role query string false "Filter by role"
 // @Param class query string false "Filter by class"
+// @Param status query string false "Filter by account status"
 // @Success 200 {array} models.UserProfile
 // @Router /admin/users [get]
 func (h *AdminHandler) GetUsers(c *gin.Context) {
@@ -77,6 +86,10 @@ func (h *AdminHandler) GetUsers(c *gin.Context) {
 		query = query.Where("class = ?", class)
 	}
 
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
 	var profiles []models.UserProfile
 	if err := query.Find(&profiles).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
@@ -102,32 +115,25 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	token, err := random4Digits()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if !checkManageableRoles(c, h.db, h.permissions, req.Role) {
 		return
 	}
-	hash := hashToken(token)
 
-	profile := &models.UserProfile{
-		ID:        uuid.New(),
-		Name:      req.Name,
-		Class:     req.Class,
-		Role:      req.Role,
-		TokenHash: hash,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if err := h.db.Create(profile).Error; err != nil {
+	profile, pin, err := h.CreateUserAccount(req)
+	if err != nil {
+		if errors.Is(err, errNISTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "user.create", TargetType: "user", TargetID: profile.ID.String(), After: profile})
 
 	c.JSON(http.StatusCreated, CreateUserResponse{
-		UserID: profile.ID.String(),
-		Token:  token,
+		UserID:   profile.ID.String(),
+		Username: *profile.Username,
+		PIN:      pin,
 	})
 }
 
@@ -156,19 +162,134 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile, req.Role) {
+		return
+	}
+	before := profile
 
 	profile.Name = req.Name
 	profile.Role = req.Role
 	profile.Class = req.Class
+	if req.NIS != nil {
+		profile.NIS = req.NIS
+	}
 
 	if err := h.db.Save(&profile).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "user.update", TargetType: "user", TargetID: profile.ID.String(), Before: before, After: profile})
 
 	c.JSON(http.StatusOK, profile)
 }
 
+// SetUserRoles godoc
+// @Summary Set user roles
+// @Description Replace the roles a user holds, e.g. a teacher who is also guru wali and a parent. The primary role is kept if it is in the list, otherwise the first role becomes primary; a staff role always wins over siswa and orangtua so the account logs in with a password.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param roles body SetUserRolesRequest true "Roles"
+// @Success 200 {object} UserRolesResponse
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/roles [put]
+func (h *AdminHandler) SetUserRoles(c *gin.Context) {
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", c.Param("id")).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles := make([]string, 0, len(req.Roles))
+	seen := make(map[string]bool, len(req.Roles))
+	for _, role := range req.Roles {
+		role = strings.TrimSpace(role)
+		if role == "" || seen[role] {
+			continue
+		}
+		seen[role] = true
+		roles = append(roles, role)
+	}
+	if len(roles) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one role is required"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile, roles...) {
+		return
+	}
+	previous, err := h.permissions.HeldRoles(&profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	primary := profile.Role
+	if !seen[primary] {
+		primary = roles[0]
+	}
+	if !auth.RoleRequiresPassword(primary) {
+		for _, role := range roles {
+			if auth.RoleRequiresPassword(role) {
+				primary = role
+				break
+			}
+		}
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if primary != profile.Role {
+			if err := tx.Model(&profile).Updates(map[string]interface{}{"role": primary, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("user_profile_id = ?", profile.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+
+		rows := make([]models.UserRole, 0, len(roles))
+		for _, role := range roles {
+			if role != primary {
+				rows = append(rows, models.UserRole{UserProfileID: profile.ID, RoleName: role, CreatedAt: time.Now()})
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roles"})
+		return
+	}
+
+	held, err := h.permissions.HeldRoles(&profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	audit.Record(c, audit.Entry{
+		Action:     "user.roles",
+		TargetType: "user",
+		TargetID:   profile.ID.String(),
+		Before:     gin.H{"roles": previous},
+		After:      gin.H{"roles": held},
+	})
+
+	c.JSON(http.StatusOK, UserRolesResponse{
+		UserID:      profile.ID.String(),
+		PrimaryRole: profile.Role,
+		Roles:       held,
+	})
+}
+
 // DeleteUser godoc
 // @Summary Delete user
 // @Description Delete a user (Admin only)
@@ -180,17 +301,27 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", id).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile) {
+		return
+	}
+
 	if err := h.db.Where("id = ?", id).Delete(&models.UserProfile{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "user.delete", TargetType: "user", TargetID: profile.ID.String(), Before: profile})
 
 	c.Status(http.StatusNoContent)
 }
 
 // BulkImportUsers godoc
 // @Summary Bulk import users from CSV
-// @Description Import multiple users from CSV file (format: name,class,role)
+// @Description Import multiple users from CSV file (format: name,class,role[,nis])
 // @Tags admin
 // @Accept multipart/form-data
 // @Produce json
@@ -212,74 +343,40 @@ func (h *AdminHandler) BulkImportUsers(c *gin.Context) {
 	}
 	defer f.Close()
 
-	reader := csv.NewReader(f)
-	records, err := reader.ReadAll()
+	manageable, err := manageableRoles(c, h.db, h.permissions)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV format"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
 		return
 	}
 
-	if len(records) < 2 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is empty"})
+	result, err := h.ImportUsers(f, func(role string) bool { return manageable[role] })
+	if err != nil {
+		message := "Invalid CSV format"
+		if errors.Is(err, errEmptyCSV) {
+			message = "CSV file is empty"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
 		return
 	}
 
-	// Skip header row
-	successCount := 0
-	errorCount := 0
-	errors := []string{}
-	tokens := []map[string]string{}
-
-	for i, record := range records[1:] {
-		if len(record) < 3 {
-			errors = append(errors, fmt.Sprintf("Row %d: Invalid format (expected: name,class,role)", i+2))
-			errorCount++
-			continue
-		}
-
-		name := strings.TrimSpace(record[0])
-		class := strings.TrimSpace(record[1])
-		role := strings.TrimSpace(record[2])
-
-		// Generate token
-		token, err := random4Digits()
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Row %d: Failed to generate token", i+2))
-			errorCount++
-			continue
-		}
-		hash := hashToken(token)
-
-		profile := models.UserProfile{
-			ID:        uuid.New(),
-			Name:      name,
-			Class:     class,
-			Role:      role,
-			TokenHash: hash,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-
-		if err := h.db.Create(&profile).Error; err != nil {
-			errors = append(errors, fmt.Sprintf("Row %d: Failed to create user - %s", i+2, err.Error()))
-			errorCount++
-			continue
-		}
-
-		tokens = append(tokens, map[string]string{
-			"name":  name,
-			"token": token,
-			"id":    profile.ID.String(),
-		})
-		successCount++
+	created := make([]string, 0, len(result.Tokens))
+	for _, t := range result.Tokens {
+		created = append(created, t.ID)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success_count": successCount,
-		"error_count":   errorCount,
-		"errors":        errors,
-		"tokens":        tokens, // Return tokens for users to save
+	// PINs stay out of the log
+	audit.Record(c, audit.Entry{
+		Action:     "user.bulk_import",
+		TargetType: "user",
+		Details: gin.H{
+			"file":          file.Filename,
+			"success_count": result.SuccessCount,
+			"error_count":   result.ErrorCount,
+			"created":       created,
+		},
 	})
+
+	c.JSON(http.StatusOK, result)
 }
 
 // LinkParentStudent godoc
@@ -299,17 +396,12 @@ func (h *AdminHandler) LinkParentStudent(c *gin.Context) {
 		return
 	}
 
-	link := models.ParentStudent{
-		ParentID:  req.ParentID,
-		StudentID: req.StudentID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if err := h.db.Create(&link).Error; err != nil {
+	link, err := h.CreateParentLink(req.ParentID, req.StudentID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create link"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "parent_student.link", TargetType: "parent_student", TargetID: link.ID.String(), After: link})
 
 	c.JSON(http.StatusCreated, link)
 }
@@ -325,10 +417,17 @@ func (h *AdminHandler) LinkParentStudent(c *gin.Context) {
 func (h *AdminHandler) UnlinkParentStudent(c *gin.Context) {
 	id := c.Param("id")
 
+	var link models.ParentStudent
+	if err := h.db.Where("id = ?", id).First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		return
+	}
+
 	if err := h.db.Where("id = ?", id).Delete(&models.ParentStudent{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete link"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "parent_student.unlink", TargetType: "parent_student", TargetID: link.ID.String(), Before: link})
 
 	c.Status(http.StatusNoContent)
 }
@@ -350,19 +449,14 @@ func (h *AdminHandler) AssignGuruWali(c *gin.Context) {
 		return
 	}
 
-	assignment := models.GuruWaliAssignment{
-		TeacherID: req.TeacherID,
-		StudentID: req.StudentID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if err := h.db.Create(&assignment).Error; err != nil {
+	assignment, err := h.CreateGuruWaliAssignment(req.TeacherID, req.StudentID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create assignment"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "guruwali.assign", TargetType: "guruwali_assignment", TargetID: assignment.ID.String(), After: assignment})
 
-	h.db.Preload("Teacher").Preload("Student").First(&assignment, assignment.ID)
+	h.db.Preload("Teacher").Preload("Student").First(assignment, assignment.ID)
 
 	c.JSON(http.StatusCreated, assignment)
 }
@@ -378,10 +472,17 @@ func (h *AdminHandler) AssignGuruWali(c *gin.Context) {
 func (h *AdminHandler) UnassignGuruWali(c *gin.Context) {
 	id := c.Param("id")
 
+	var assignment models.GuruWaliAssignment
+	if err := h.db.Where("id = ?", id).First(&assignment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return
+	}
+
 	if err := h.db.Where("id = ?", id).Delete(&models.GuruWaliAssignment{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete assignment"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "guruwali.unassign", TargetType: "guruwali_assignment", TargetID: assignment.ID.String(), Before: assignment})
 
 	c.Status(http.StatusNoContent)
 }
@@ -433,6 +534,7 @@ func (h *AdminHandler) AssignTeacherRole(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "teacher_role.assign", TargetType: "teacher_role", TargetID: role.ID.String(), After: role})
 
 	h.db.Preload("Teacher").First(&role, role.ID)
 
@@ -450,10 +552,17 @@ func (h *AdminHandler) AssignTeacherRole(c *gin.Context) {
 func (h *AdminHandler) UnassignTeacherRole(c *gin.Context) {
 	id := c.Param("id")
 
+	var role models.TeacherRole
+	if err := h.db.Where("id = ?", id).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
 	if err := h.db.Where("id = ?", id).Delete(&models.TeacherRole{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
 		return
 	}
+	audit.Record(c, audit.Entry{Action: "teacher_role.unassign", TargetType: "teacher_role", TargetID: role.ID.String(), Before: role})
 
 	c.Status(http.StatusNoContent)
 }
@@ -475,74 +584,3 @@ func (h *AdminHandler) GetTeacherRoles(c *gin.Context) {
 
 	c.JSON(http.StatusOK, roles)
 }
-
-// GetSubmissionWindow godoc
-// @Summary Get submission window settings
-// @Description Get current submission window configuration
-// @Tags admin
-// @Produce json
-// @Security BearerAuth
-// @Success 200 {object} models.SubmissionWindow
-// @Router /admin/submission-window [get]
-func (h *AdminHandler) GetSubmissionWindow(c *gin.Context) {
-	var window models.SubmissionWindow
-	if err := h.db.First(&window).Error; err != nil {
-		// Create default if not exists
-		window = models.SubmissionWindow{
-			IsOpen:    true,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-		h.db.Create(&window)
-	}
-
-	c.JSON(http.StatusOK, window)
-}
-
-// UpdateSubmissionWindow godoc
-// @Summary Update submission window settings
-// @Description Update submission window configuration
-// @Tags admin
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param window body UpdateSubmissionWindowRequest true "Window settings"
-// @Success 200 {object} models.SubmissionWindow
-// @Router /admin/submission-window [put]
-func (h *AdminHandler) UpdateSubmissionWindow(c *gin.Context) {
-	var window models.SubmissionWindow
-	if err := h.db.First(&window).Error; err != nil {
-		// Create if not exists
-		window = models.SubmissionWindow{
-			IsOpen:    true,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-		h.db.Create(&window)
-	}
-
-	var req UpdateSubmissionWindowRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if req.IsOpen != nil {
-		window.IsOpen = *req.IsOpen
-	}
-	if req.OpenTime != nil {
-		window.OpenTime = req.OpenTime
-	}
-	if req.CloseTime != nil {
-		window.CloseTime = req.CloseTime
-	}
-
-	window.UpdatedAt = time.Now()
-
-	if err := h.db.Save(&window).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update window"})
-		return
-	}
-
-	c.JSON(http.StatusOK, window)
-}
\ No newline at end of file
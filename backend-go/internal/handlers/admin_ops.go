@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+)
+
+// The operations in this file have no HTTP context so the admin CLI
+// (cmd/g7kaih-admin) runs the same code as the API. Permission checks stay
+// in the HTTP handlers.
+
+var (
+	errInvalidCSV = errors.New("invalid CSV format")
+	errEmptyCSV   = errors.New("CSV file is empty")
+)
+
+type ImportedUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Class    string `json:"class"`
+	Username string `json:"username"`
+	PIN      string `json:"pin"`
+}
+
+type ImportUsersResult struct {
+	SuccessCount int            `json:"success_count"`
+	ErrorCount   int            `json:"error_count"`
+	Errors       []string       `json:"errors"`
+	Tokens       []ImportedUser `json:"tokens"` // Return tokens for users to save
+}
+
+// CreateUserAccount creates a user with a generated username and PIN and
+// returns the PIN
+func (h *AdminHandler) CreateUserAccount(req CreateUserRequest) (*models.UserProfile, string, error) {
+	profile := &models.UserProfile{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		Class:     req.Class,
+		NIS:       req.NIS,
+		Role:      req.Role,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	pin, err := issueCredentials(h.db, profile, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return profile, pin, nil
+}
+
+// ImportUsers creates a user for every row of a CSV file with a header row
+// and the columns name,class,role[,nis]. Rows that fail, or whose role
+// allowed rejects, are reported and skipped.
+func (h *AdminHandler) ImportUsers(r io.Reader, allowed func(role string) bool) (*ImportUsersResult, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errInvalidCSV
+	}
+	if len(records) < 2 {
+		return nil, errEmptyCSV
+	}
+
+	result := &ImportUsersResult{Errors: []string{}, Tokens: []ImportedUser{}}
+
+	// Skip header row
+	for i, record := range records[1:] {
+		if len(record) < 3 {
+			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: Invalid format (expected: name,class,role[,nis])", i+2))
+			result.ErrorCount++
+			continue
+		}
+
+		req := CreateUserRequest{
+			Name:  strings.TrimSpace(record[0]),
+			Class: strings.TrimSpace(record[1]),
+			Role:  strings.TrimSpace(record[2]),
+		}
+		if !allowed(req.Role) {
+			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: Unknown or not allowed role %q", i+2, req.Role))
+			result.ErrorCount++
+			continue
+		}
+		if len(record) > 3 {
+			if nis := strings.TrimSpace(record[3]); nis != "" {
+				req.NIS = &nis
+			}
+		}
+
+		// Generate username and PIN
+		profile, pin, err := h.CreateUserAccount(req)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: Failed to create user - %s", i+2, err.Error()))
+			result.ErrorCount++
+			continue
+		}
+
+		result.Tokens = append(result.Tokens, ImportedUser{
+			ID:       profile.ID.String(),
+			Name:     profile.Name,
+			Class:    profile.Class,
+			Username: *profile.Username,
+			PIN:      pin,
+		})
+		result.SuccessCount++
+	}
+
+	return result, nil
+}
+
+// CreateParentLink links a parent to a student
+func (h *AdminHandler) CreateParentLink(parentID, studentID uuid.UUID) (*models.ParentStudent, error) {
+	link := models.ParentStudent{
+		ParentID:  parentID,
+		StudentID: studentID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.db.Create(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// CreateGuruWaliAssignment makes the teacher guru wali of the student
+func (h *AdminHandler) CreateGuruWaliAssignment(teacherID, studentID uuid.UUID) (*models.GuruWaliAssignment, error) {
+	assignment := models.GuruWaliAssignment{
+		TeacherID: teacherID,
+		StudentID: studentID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.db.Create(&assignment).Error; err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AuditHandler struct {
+	db *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+// parseAuditTime accepts RFC 3339 timestamps and plain dates. A plain "to"
+// date includes the whole day.
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// GetAuditLog godoc
+// @Summary Get audit log
+// @Description List audit log entries, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param actor_id query string false "Filter by the user who acted"
+// @Param target_type query string false "Filter by target type (user, activity, ...)"
+// @Param target_id query string false "Filter by target ID"
+// @Param action query string false "Filter by action"
+// @Param from query string false "Entries at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "Entries before this time (RFC 3339, or YYYY-MM-DD inclusive)"
+// @Param limit query int false "Page size (default 100)"
+// @Param offset query int false "Page offset"
+// @Success 200 {array} models.AuditLog
+// @Router /admin/audit-log [get]
+func (h *AuditHandler) GetAuditLog(c *gin.Context) {
+	query := h.db.Model(&models.AuditLog{})
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseAuditTime(from, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time"})
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := parseAuditTime(to, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time"})
+			return
+		}
+		query = query.Where("created_at < ?", t)
+	}
+
+	limit := 100
+	offset := 0
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil {
+			limit = parsedLimit
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil {
+			offset = parsedOffset
+		}
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetAuditLogEntry godoc
+// @Summary Get audit log entry
+// @Description Get a single audit log entry
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Entry ID"
+// @Success 200 {object} models.AuditLog
+// @Failure 404 {object} map[string]string
+// @Router /admin/audit-log/{id} [get]
+func (h *AuditHandler) GetAuditLogEntry(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	var entry models.AuditLog
+	if err := h.db.First(&entry, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Entry not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -12,32 +18,92 @@ import (
 )
 
 type AuthHandler struct {
-	db         *gorm.DB
-	jwtService *auth.JWTService
+	db            *gorm.DB
+	jwtService    *auth.JWTService
+	loginGuard    *auth.LoginGuard
+	refreshTokens *auth.RefreshTokenStore
+	sessions      *auth.SessionStore
+	qrTokens      *auth.QRLoginStore
+	mfa           *auth.MFAService
+	permissions   *auth.PermissionStore
 }
 
-func NewAuthHandler(db *gorm.DB, jwtService *auth.JWTService) *AuthHandler {
-	return &AuthHandler{db: db, jwtService: jwtService}
+func NewAuthHandler(db *gorm.DB, jwtService *auth.JWTService, loginGuard *auth.LoginGuard, refreshTokens *auth.RefreshTokenStore, sessions *auth.SessionStore, qrTokens *auth.QRLoginStore, mfa *auth.MFAService, permissions *auth.PermissionStore) *AuthHandler {
+	return &AuthHandler{db: db, jwtService: jwtService, loginGuard: loginGuard, refreshTokens: refreshTokens, sessions: sessions, qrTokens: qrTokens, mfa: mfa, permissions: permissions}
 }
 
+// SignupRequest needs an invite code, which fixes the role (and class if the
+// invite has one). Without a code, only roles opened by an admin may sign up.
 type SignupRequest struct {
-	Name  string `json:"name" binding:"required"`
-	Class string `json:"class" binding:"required"`
-	Role  string `json:"role" binding:"required,oneof=siswa orangtua guru guruwali admin"`
+	Name       string  `json:"name" binding:"required"`
+	Class      string  `json:"class"`
+	NIS        *string `json:"nis"`
+	Role       string  `json:"role" binding:"omitempty,oneof=siswa orangtua guru guruwali"`
+	InviteCode string  `json:"invite_code"`
 }
 
 type SignupResponse struct {
-	UserID string `json:"user_id"`
-	Token  string `json:"token"`
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	PIN      string `json:"pin"`
 }
 
+// LoginRequest accepts either username (or NIS) + PIN, username + password
+// for staff who have set one, or the legacy 4-digit token that accounts
+// created before usernames existed still use.
 type LoginRequest struct {
-	Token string `json:"token" binding:"required,len=4"`
+	Username string `json:"username"`
+	PIN      string `json:"pin"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
 }
 
+// LoginResponse flags PasswordChangeRequired for staff who still have to set
+// a password; until they do, only /auth/password, /auth/me and logout work.
+// /auth/me also lists the roles the user holds, the active one and the
+// permissions they grant together. For an impersonation token it names the
+// admin in ImpersonatedBy and issues no new token.
 type LoginResponse struct {
-	Token   string              `json:"token"`
-	Profile *models.UserProfile `json:"profile,omitempty"`
+	Token                  string              `json:"token,omitempty"`
+	RefreshToken           string              `json:"refresh_token,omitempty"`
+	ExpiresAt              int64               `json:"expires_at,omitempty"`
+	PasswordChangeRequired bool                `json:"password_change_required,omitempty"`
+	Profile                *models.UserProfile `json:"profile,omitempty"`
+	ActiveRole             string              `json:"active_role,omitempty"`
+	Roles                  []string            `json:"roles,omitempty"`
+	Permissions            []string            `json:"permissions,omitempty"`
+	ImpersonatedBy         *uuid.UUID          `json:"impersonated_by,omitempty"`
+}
+
+// QRLoginRequest carries the text scanned from a QR login card
+type QRLoginRequest struct {
+	Payload string `json:"payload" binding:"required"`
+}
+
+// MFAChallengeResponse is returned by the login endpoints instead of tokens
+// when the account has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+type LoginTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type SwitchRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type SessionResponse struct {
+	models.UserSession
+	Current bool `json:"current"`
 }
 
 func (h *AuthHandler) Signup(c *gin.Context) {
@@ -47,29 +113,74 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	token, err := random4Digits()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
-		return
+	if req.InviteCode == "" {
+		settings, err := loadRegistrationSettings(h.db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		open := false
+		for _, role := range openRoles(settings) {
+			if role == req.Role {
+				open = true
+				break
+			}
+		}
+		if !open {
+			c.JSON(http.StatusForbidden, gin.H{"error": "an invite code is required to sign up"})
+			return
+		}
+		if req.Class == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "class is required"})
+			return
+		}
 	}
-	hash := hashToken(token)
 
 	profile := &models.UserProfile{
 		ID:        uuid.New(),
 		Name:      req.Name,
 		Class:     req.Class,
+		NIS:       req.NIS,
 		Role:      req.Role,
-		TokenHash: hash,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if err := h.db.Create(profile).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create profile"})
+	var pin string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if req.InviteCode != "" {
+			invite, err := redeemInvite(tx, req.InviteCode)
+			if err != nil {
+				return err
+			}
+			profile.Role = invite.Role
+			if invite.Class != nil {
+				profile.Class = *invite.Class
+			}
+			if profile.Class == "" {
+				return errClassRequired
+			}
+		}
+
+		var err error
+		pin, err = issueCredentials(tx, profile, true)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidInvite):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, errClassRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errNISTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create profile"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, SignupResponse{UserID: profile.ID.String(), Token: token})
+	c.JSON(http.StatusOK, SignupResponse{UserID: profile.ID.String(), Username: *profile.Username, PIN: pin})
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -78,26 +189,348 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if (req.Username == "" || (req.PIN == "" && req.Password == "")) && len(req.Token) != 4 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and pin or password are required"})
+		return
+	}
 
-	hash := hashToken(req.Token)
+	subject := loginSubject(c)
+	if req.Username != "" {
+		subject.Account = normalizeUsername(req.Username)
+	}
+	if !h.allowLoginAttempt(c, subject) {
+		return
+	}
+
+	profile, err := h.findByCredentials(req)
+	if err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			h.rejectLogin(c, subject, "invalid_credentials")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	h.completeLogin(c, subject, profile)
+}
+
+// LoginQR logs in with the one-time payload of a QR login card. Each card
+// works once; the login itself goes through the same path as Login.
+func (h *AuthHandler) LoginQR(c *gin.Context) {
+	var req QRLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subject := loginSubject(c)
+	if !h.allowLoginAttempt(c, subject) {
+		return
+	}
+
+	userID, err := h.qrTokens.Consume(req.Payload)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidQRToken) {
+			h.rejectLogin(c, subject, "invalid_qr_token")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
 
 	var profile models.UserProfile
-	if err := h.db.Where("token_hash = ?", hash).First(&profile).Error; err != nil {
+	if err := h.db.Where("id = ?", userID).First(&profile).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			h.rejectLogin(c, subject, "invalid_qr_token")
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 		return
 	}
+	// Cards printed before the account got a password must not bypass it
+	if !qrLoginAllowed(&profile) {
+		h.rejectLogin(c, subject, "invalid_qr_token")
+		return
+	}
+
+	h.completeLogin(c, subject, &profile)
+}
 
-	tokenStr, _, err := h.jwtService.GenerateToken(profile.ID, profile.Role)
+// LoginTwoFactor is the second login step for accounts with 2FA: it trades
+// the challenge token from the first step and a TOTP or recovery code for
+// tokens marked as MFA-authenticated
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.jwtService.ValidateMFAChallenge(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token, please log in again"})
+		return
+	}
+
+	// Codes are counted per user, whichever device or IP sends them
+	subject := loginSubject(c)
+	subject.Account = "user:" + userID.String()
+	if !h.allowLoginAttempt(c, subject) {
+		return
+	}
+
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", userID).First(&profile).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token, please log in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if err := h.mfa.Verify(&profile, req.Code); err != nil {
+		if errors.Is(err, auth.ErrInvalidMFACode) || errors.Is(err, auth.ErrMFANotEnrolled) {
+			h.rejectLogin(c, subject, "invalid_mfa_code")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !h.allowAccountStatus(c, subject, &profile) {
+		return
+	}
+
+	h.finishLogin(c, subject, &profile, true)
+}
+
+// completeLogin is where every login method ends once the first factor is
+// verified. Accounts with 2FA get a challenge for /auth/login/2fa instead of
+// tokens.
+func (h *AuthHandler) completeLogin(c *gin.Context, subject auth.LoginSubject, profile *models.UserProfile) {
+	if !h.allowAccountStatus(c, subject, profile) {
+		return
+	}
+
+	if !auth.MFAEnabled(profile) {
+		h.finishLogin(c, subject, profile, false)
+		return
+	}
+
+	challenge, expiresAt, err := h.jwtService.GenerateMFAChallenge(profile.ID, h.mfa.ChallengeTTL())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    challenge,
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// finishLogin records the successful attempt and answers with a new token pair
+func (h *AuthHandler) finishLogin(c *gin.Context, subject auth.LoginSubject, profile *models.UserProfile, mfa bool) {
+	if err := h.loginGuard.RecordSuccess(subject, profile.ID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	pair, err := h.issueTokenPair(c, profile, mfa)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, LoginResponse{Token: tokenStr, Profile: &profile})
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:                  pair.AccessToken,
+		RefreshToken:           pair.RefreshToken,
+		ExpiresAt:              pair.ExpiresAt,
+		PasswordChangeRequired: auth.PasswordChangeRequired(profile),
+		Profile:                profile,
+	})
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var session *models.UserSession
+	issued, refreshToken, err := h.refreshTokens.Rotate(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token was already used, please log in again"})
+		case errors.Is(err, auth.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	profile, err := h.sessions.Validate(issued.SessionID, issued.UserProfileID)
+	if err == nil {
+		session, err = h.sessions.Get(issued.SessionID)
+	}
+	if err != nil {
+		if errors.Is(err, auth.ErrSessionRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if !auth.AccountActive(profile) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is not active", "account_status": profile.Status})
+		return
+	}
+
+	roles, err := h.permissions.HeldRoles(profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	activeRole := profile.Role
+	if session.ActiveRole != nil {
+		activeRole = auth.ActiveRole(*session.ActiveRole, roles)
+	}
+
+	accessToken, expiresAt, err := h.jwtService.GenerateToken(profile.ID, activeRole, roles, issued.SessionID, session.MFA)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, auth.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	})
+}
+
+// issueTokenPair starts a new session for a fresh login
+func (h *AuthHandler) issueTokenPair(c *gin.Context, profile *models.UserProfile, mfa bool) (*auth.TokenPair, error) {
+	roles, err := h.permissions.HeldRoles(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := h.sessions.Create(profile.ID, c.Request.UserAgent(), c.ClientIP(), mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := h.jwtService.GenerateToken(profile.ID, profile.Role, roles, session.ID, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := h.refreshTokens.Issue(profile.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// findByCredentials resolves a login request to a profile. Unknown usernames
+// and wrong codes both return errInvalidCredentials. Once an account has a
+// password, its PIN and legacy token no longer work.
+func (h *AuthHandler) findByCredentials(req LoginRequest) (*models.UserProfile, error) {
+	var profile models.UserProfile
+
+	if req.Username != "" {
+		// A username wins over another user's NIS that happens to match it
+		err := h.db.Where("username = ?", normalizeUsername(req.Username)).First(&profile).Error
+		if err == gorm.ErrRecordNotFound {
+			err = h.db.Where("nis = ?", strings.TrimSpace(req.Username)).First(&profile).Error
+		}
+		if err == gorm.ErrRecordNotFound {
+			return nil, errInvalidCredentials
+		}
+		if err != nil {
+			return nil, err
+		}
+		if profile.PasswordHash != nil {
+			if req.Password == "" || auth.VerifyPassword(*profile.PasswordHash, req.Password) != nil {
+				return nil, errInvalidCredentials
+			}
+			return &profile, nil
+		}
+		if req.PIN == "" || !credentialMatches(&profile, req.PIN) {
+			return nil, errInvalidCredentials
+		}
+		return &profile, nil
+	}
+
+	err := h.db.Where("token_hash = ?", hashToken(req.Token)).First(&profile).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if profile.PasswordHash != nil {
+		return nil, errInvalidCredentials
+	}
+	return &profile, nil
+}
+
+// allowLoginAttempt answers 429 and returns false while the client is locked out
+func (h *AuthHandler) allowLoginAttempt(c *gin.Context, subject auth.LoginSubject) bool {
+	wait, err := h.loginGuard.Check(subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return false
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	if err := h.loginGuard.RecordBlocked(subject); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":               "too many failed login attempts",
+		"retry_after_seconds": retryAfter,
+	})
+	return false
+}
+
+// allowAccountStatus answers 403 and returns false when the account is
+// suspended, graduated or transferred
+func (h *AuthHandler) allowAccountStatus(c *gin.Context, subject auth.LoginSubject, profile *models.UserProfile) bool {
+	if auth.AccountActive(profile) {
+		return true
+	}
+
+	if err := h.loginGuard.RecordInactive(subject, profile.ID, profile.Status); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "account is not active", "account_status": profile.Status})
+	return false
+}
+
+// rejectLogin records a failed attempt and answers 401
+func (h *AuthHandler) rejectLogin(c *gin.Context, subject auth.LoginSubject, reason string) {
+	if err := h.loginGuard.RecordFailure(subject, reason); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 }
 
 func (h *AuthHandler) Me(c *gin.Context) {
@@ -117,11 +550,140 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		return
 	}
 
-	tokenStr, _, err := h.jwtService.GenerateToken(profile.ID, profile.Role)
+	sessionID, _ := middleware.GetSessionID(c)
+	activeRole, _ := middleware.GetUserRole(c)
+	roles := middleware.GetUserRoles(c)
+
+	if actorID, ok := middleware.GetImpersonatorID(c); ok {
+		c.JSON(http.StatusOK, LoginResponse{
+			Profile:        &profile,
+			ActiveRole:     activeRole,
+			Roles:          roles,
+			Permissions:    middleware.GetPermissions(c).Names(),
+			ImpersonatedBy: &actorID,
+		})
+		return
+	}
+
+	tokenStr, _, err := h.jwtService.GenerateToken(profile.ID, activeRole, roles, sessionID, middleware.HasMFA(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, LoginResponse{Token: tokenStr, Profile: &profile})
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:       tokenStr,
+		Profile:     &profile,
+		ActiveRole:  activeRole,
+		Roles:       roles,
+		Permissions: middleware.GetPermissions(c).Names(),
+	})
+}
+
+// SwitchRole changes the active role context of the current session, e.g. a
+// teacher who is also a parent opening the parent dashboard. Permissions stay
+// those of every held role; the active role decides which views the client
+// shows. The refresh token keeps working and remembers the choice.
+func (h *AuthHandler) SwitchRole(c *gin.Context) {
+	var req SwitchRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, ok := h.currentProfile(c)
+	if !ok {
+		return
+	}
+	sessionID, err := middleware.GetSessionID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	roles := middleware.GetUserRoles(c)
+	if auth.ActiveRole(req.Role, roles) != req.Role {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have role " + req.Role})
+		return
+	}
+
+	if err := h.sessions.SetActiveRole(sessionID, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	tokenStr, expiresAt, err := h.jwtService.GenerateToken(profile.ID, req.Role, roles, sessionID, middleware.HasMFA(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:       tokenStr,
+		ExpiresAt:   expiresAt,
+		Profile:     profile,
+		ActiveRole:  req.Role,
+		Roles:       roles,
+		Permissions: middleware.GetPermissions(c).Names(),
+	})
+}
+
+// JWKS publishes the public keys that verify access tokens, so other
+// services can check tokens without sharing a secret. It is empty when tokens
+// are signed with JWT_SECRET.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
+
+// Logout revokes the current session and its refresh tokens
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	sessionID, err := middleware.GetSessionID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.sessions.Revoke(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// LogoutAll revokes every session of the current user, signing out all devices
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.sessions.RevokeAll(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out from all devices"})
+}
+
+// GetSessions lists the devices the current user is logged in on
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	currentID, _ := middleware.GetSessionID(c)
+
+	sessions, err := h.sessions.Active(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, SessionResponse{UserSession: s, Current: s.ID == currentID})
+	}
+
+	c.JSON(http.StatusOK, response)
 }
\ No newline at end of file
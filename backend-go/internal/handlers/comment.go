@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
 	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/policy"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -28,15 +30,60 @@ type UpdateCommentRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
+// activity loads an activity with its owner when the subject may perform the
+// action on it, answering the request itself otherwise
+func (h *CommentHandler) activity(c *gin.Context, subject *policy.Subject, id interface{}, action policy.Action) (*models.Activity, bool) {
+	db := middleware.DB(c, h.db)
+
+	var activity models.Activity
+	if err := db.Preload("UserProfile").Where("id = ?", id).First(&activity).Error; err != nil || !subject.Can(policy.ActionView, &activity) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Activity not found"})
+		return nil, false
+	}
+	if !subject.Can(action, &activity) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return nil, false
+	}
+	return &activity, true
+}
+
+// comment loads a comment when the subject may perform the action on it,
+// answering the request itself otherwise
+func (h *CommentHandler) comment(c *gin.Context, subject *policy.Subject, id string, action policy.Action) (*models.Comment, bool) {
+	db := middleware.DB(c, h.db)
+
+	var comment models.Comment
+	if err := db.Preload("Activity.UserProfile").Where("id = ?", id).First(&comment).Error; err != nil || !subject.Can(policy.ActionView, &comment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, false
+	}
+	if !subject.Can(action, &comment) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return nil, false
+	}
+	comment.Activity = nil
+	return &comment, true
+}
+
 func (h *CommentHandler) GetComments(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	activityID := c.Query("activity_id")
 	if activityID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "activity_id is required"})
 		return
 	}
 
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
+	if _, ok := h.activity(c, subject, activityID, policy.ActionView); !ok {
+		return
+	}
+
 	var comments []models.Comment
-	if err := h.db.Preload("UserProfile").
+	if err := db.Preload("UserProfile").
 		Where("activity_id = ?", activityID).
 		Order("created_at ASC").
 		Find(&comments).Error; err != nil {
@@ -48,9 +95,10 @@ func (h *CommentHandler) GetComments(c *gin.Context) {
 }
 
 func (h *CommentHandler) CreateComment(c *gin.Context) {
-	userID, err := middleware.GetUserID(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	db := middleware.DB(c, h.db)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
 		return
 	}
 
@@ -60,43 +108,40 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
-	var activity models.Activity
-	if err := h.db.Where("id = ?", req.ActivityID).First(&activity).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Activity not found"})
+	if _, ok := h.activity(c, subject, req.ActivityID, policy.ActionComment); !ok {
 		return
 	}
 
 	comment := models.Comment{
 		ActivityID:    req.ActivityID,
-		UserProfileID: userID,
+		UserProfileID: subject.UserID,
 		Content:       req.Content,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
-	if err := h.db.Create(&comment).Error; err != nil {
+	if err := db.Create(&comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
 		return
 	}
 
-	h.db.Preload("UserProfile").First(&comment, comment.ID)
+	db.Preload("UserProfile").First(&comment, comment.ID)
 
 	c.JSON(http.StatusCreated, comment)
 }
 
 func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	id := c.Param("id")
-	userID, _ := middleware.GetUserID(c)
-	userRole, _ := middleware.GetUserRole(c)
 
-	var comment models.Comment
-	if err := h.db.Where("id = ?", id).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+	subject, ok := loadSubject(c, db)
+	if !ok {
 		return
 	}
 
-	if comment.UserProfileID != userID && userRole != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+	comment, ok := h.comment(c, subject, id, policy.ActionUpdate)
+	if !ok {
 		return
 	}
 
@@ -106,39 +151,45 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		return
 	}
 
+	before := *comment
 	comment.Content = req.Content
 	comment.UpdatedAt = time.Now()
 
-	if err := h.db.Save(&comment).Error; err != nil {
+	if err := db.Save(comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
 		return
 	}
+	if comment.UserProfileID != subject.UserID {
+		audit.Record(c, audit.Entry{Action: "comment.moderate", TargetType: "comment", TargetID: comment.ID.String(), Before: before, After: comment})
+	}
 
-	h.db.Preload("UserProfile").First(&comment, comment.ID)
+	db.Preload("UserProfile").First(comment, comment.ID)
 
 	c.JSON(http.StatusOK, comment)
 }
 
 func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	id := c.Param("id")
-	userID, _ := middleware.GetUserID(c)
-	userRole, _ := middleware.GetUserRole(c)
 
-	var comment models.Comment
-	if err := h.db.Where("id = ?", id).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+	subject, ok := loadSubject(c, db)
+	if !ok {
 		return
 	}
 
-	if comment.UserProfileID != userID && userRole != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+	comment, ok := h.comment(c, subject, id, policy.ActionDelete)
+	if !ok {
 		return
 	}
 
-	if err := h.db.Delete(&comment).Error; err != nil {
+	if err := db.Delete(comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 		return
 	}
+	if comment.UserProfileID != subject.UserID {
+		audit.Record(c, audit.Entry{Action: "comment.delete", TargetType: "comment", TargetID: comment.ID.String(), Before: comment})
+	}
 
 	c.Status(http.StatusNoContent)
 }
\ No newline at end of file
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/submission"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CompatHandler serves the routes of the Next.js API (/api/aktivitas,
+// /api/komentar, /api/category/:categoryid, /api/user-profiles/:userid and
+// /api/submission-window) with their request and response shapes on top of
+// the Go models, so the frontend can move off Supabase one page at a time.
+// Field names follow the legacy tables: userid, kegiatanid, roleid, and
+// username for the display name.
+type CompatHandler struct {
+	db          *gorm.DB
+	permissions *auth.PermissionStore
+	schedule    *submission.Scheduler
+}
+
+func NewCompatHandler(db *gorm.DB, permissions *auth.PermissionStore, schedule *submission.Scheduler) *CompatHandler {
+	return &CompatHandler{db: db, permissions: permissions, schedule: schedule}
+}
+
+// compatTimezone is the timezone the Next.js app counted days in
+var compatTimezone = time.FixedZone("WIB", 7*60*60)
+
+// legacyUnknownRoleID is the roleid of accounts an admin has not approved.
+// Custom roles, which the Next.js app did not have, are reported as it too.
+const legacyUnknownRoleID = 1
+
+// legacyRoles are the built-in roles with the roleid and rolename of the
+// Next.js role table
+var legacyRoles = []struct {
+	ID   int
+	Name string
+	Role string
+}{
+	{2, "teacher", "guru"},
+	{3, "admin", "admin"},
+	{4, "parent", "orangtua"},
+	{5, "student", "siswa"},
+	{6, "guruwali", "guruwali"},
+}
+
+func legacyRoleID(role string) int {
+	for _, r := range legacyRoles {
+		if r.Role == role {
+			return r.ID
+		}
+	}
+	return legacyUnknownRoleID
+}
+
+func legacyRoleName(role string) string {
+	for _, r := range legacyRoles {
+		if r.Role == role {
+			return r.Name
+		}
+	}
+	return "Unknown"
+}
+
+// roleForLegacyID returns the role of a legacy roleid; ok is false for
+// Unknown and IDs that do not exist
+func roleForLegacyID(id int) (string, bool) {
+	for _, r := range legacyRoles {
+		if r.ID == id {
+			return r.Role, true
+		}
+	}
+	return "", false
+}
+
+// CompatRoleRef is role:roleid(rolename)
+type CompatRoleRef struct {
+	RoleName string `json:"rolename"`
+}
+
+// CompatUpdater is the user who last changed the submission window
+type CompatUpdater struct {
+	UserID   string        `json:"userid"`
+	Username string        `json:"username"`
+	Role     CompatRoleRef `json:"role"`
+}
+
+// CompatSubmissionWindow is the payload of /api/submission-window
+type CompatSubmissionWindow struct {
+	Open      bool           `json:"open"`
+	UpdatedAt *time.Time     `json:"updatedAt"`
+	UpdatedBy *CompatUpdater `json:"updatedBy"`
+}
+
+type CompatUpdateSubmissionWindowRequest struct {
+	Open *bool `json:"open"`
+}
+
+// compatSubmissionWindow builds the payload from whether submissions are
+// open for everyone and the last change to the schedule
+func compatSubmissionWindow(open bool, updatedAt *time.Time, updater *models.UserProfile) CompatSubmissionWindow {
+	result := CompatSubmissionWindow{Open: open, UpdatedAt: updatedAt}
+	if updater != nil {
+		result.UpdatedBy = &CompatUpdater{
+			UserID:   updater.ID.String(),
+			Username: updater.Name,
+			Role:     CompatRoleRef{RoleName: legacyRoleName(updater.Role)},
+		}
+	}
+	return result
+}
+
+// lastScheduleChange finds, from the audit log, when and by whom the
+// submission schedule was last changed
+func (h *CompatHandler) lastScheduleChange() (*time.Time, *models.UserProfile, error) {
+	var entry models.AuditLog
+	err := h.db.Where("action LIKE ? AND actor_id IS NOT NULL", "submission%").
+		Order("created_at DESC").
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var updater models.UserProfile
+	if err := h.db.Unscoped().Where("id = ?", entry.ActorID).First(&updater).Error; err != nil {
+		return &entry.CreatedAt, nil, nil
+	}
+	return &entry.CreatedAt, &updater, nil
+}
+
+// GetSubmissionWindow godoc
+// @Summary Get submission window (Next.js contract)
+// @Description Whether the schedule lets students without a class or kegiatan scope submit now, with when and by whom the schedule was last changed
+// @Tags compat
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /submission-window [get]
+func (h *CompatHandler) GetSubmissionWindow(c *gin.Context) {
+	status, err := h.schedule.Status(h.db, submission.Scope{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submission window"})
+		return
+	}
+	updatedAt, updater, err := h.lastScheduleChange()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submission window"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": compatSubmissionWindow(status.Open, updatedAt, updater)})
+}
+
+// UpdateSubmissionWindow godoc
+// @Summary Open or close the submission window (Next.js contract)
+// @Description Closing adds an exception that closes submissions for everyone from today until reopened; opening ends such exceptions. Weekly hours still apply.
+// @Tags compat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param window body CompatUpdateSubmissionWindowRequest true "Window state"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /submission-window [post]
+func (h *CompatHandler) UpdateSubmissionWindow(c *gin.Context) {
+	var req CompatUpdateSubmissionWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Open == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter 'open' wajib bertipe boolean"})
+		return
+	}
+
+	loc := h.schedule.Location()
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if !*req.Open {
+			closure, err := closeSubmissions(tx, loc, "Ditutup")
+			if closure != nil && err == nil {
+				audit.Record(c, audit.Entry{Action: "submission_exception.create", TargetType: "submission_exception", TargetID: closure.ID.String(), After: closure})
+			}
+			return err
+		}
+		closures, err := reopenSubmissions(tx, loc)
+		for _, closure := range closures {
+			audit.Record(c, audit.Entry{Action: "submission_exception.end", TargetType: "submission_exception", TargetID: closure.ID.String(), Before: closure})
+		}
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update window"})
+		return
+	}
+
+	status, err := h.schedule.Status(h.db, submission.Scope{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submission window"})
+		return
+	}
+	var updater *models.UserProfile
+	if userID, err := middleware.GetUserID(c); err == nil {
+		var profile models.UserProfile
+		if h.db.Where("id = ?", userID).First(&profile).Error == nil {
+			updater = &profile
+		}
+	}
+	now := time.Now()
+
+	c.JSON(http.StatusOK, gin.H{"data": compatSubmissionWindow(status.Open, &now, updater)})
+}
+
+// closeSubmissions adds an exception closing submissions for everyone from
+// today until removed, unless one is already in place
+func closeSubmissions(tx *gorm.DB, loc *time.Location, name string) (*models.SubmissionException, error) {
+	today := time.Now().In(loc).Format("2006-01-02")
+
+	var existing models.SubmissionException
+	err := tx.Where("closed AND class IS NULL AND kegiatan_id IS NULL AND end_date IS NULL AND start_date <= ?", today).
+		First(&existing).Error
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	start, _ := time.Parse("2006-01-02", today)
+	exception := models.SubmissionException{Name: name, StartDate: start, Closed: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	return &exception, tx.Create(&exception).Error
+}
+
+// reopenSubmissions ends the closures for everyone that have no end date:
+// they end yesterday, or are removed if they start today or later
+func reopenSubmissions(tx *gorm.DB, loc *time.Location) ([]models.SubmissionException, error) {
+	today := time.Now().In(loc)
+	yesterday, _ := time.Parse("2006-01-02", today.AddDate(0, 0, -1).Format("2006-01-02"))
+
+	var closures []models.SubmissionException
+	if err := tx.Where("closed AND class IS NULL AND kegiatan_id IS NULL AND end_date IS NULL").Find(&closures).Error; err != nil {
+		return nil, err
+	}
+	for i := range closures {
+		if closures[i].StartDate.After(yesterday) {
+			if err := tx.Delete(&closures[i]).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := tx.Model(&closures[i]).Updates(map[string]interface{}{"end_date": yesterday, "updated_at": time.Now()}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return closures, nil
+}
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/forms"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// compatMaxFileSize is the largest file POST /api/aktivitas stores
+const compatMaxFileSize = 5 * 1024 * 1024
+
+// CompatKegiatanRef is kegiatan:kegiatanid(kegiatanid, kegiatanname)
+type CompatKegiatanRef struct {
+	KegiatanID   string `json:"kegiatanid"`
+	KegiatanName string `json:"kegiatanname"`
+}
+
+type CompatActivityProfile struct {
+	Username *string `json:"username"`
+}
+
+// CompatActivity is an aktivitas row as GET /api/aktivitas returns it
+type CompatActivity struct {
+	ActivityID      string                 `json:"activityid"`
+	ActivityName    string                 `json:"activityname"`
+	ActivityContent string                 `json:"activitycontent"`
+	KegiatanID      string                 `json:"kegiatanid"`
+	UserID          string                 `json:"userid"`
+	Status          string                 `json:"status"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	Kegiatan        *CompatKegiatanRef     `json:"kegiatan"`
+	Profile         *CompatActivityProfile `json:"profile"`
+}
+
+// CompatFieldValue is one submitted field of a category
+type CompatFieldValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// CompatValueGroup holds the submitted fields of one category
+type CompatValueGroup struct {
+	CategoryID string             `json:"categoryid"`
+	Fields     []CompatFieldValue `json:"fields"`
+}
+
+// CompatCreateActivityRequest is the body of POST /api/aktivitas, as JSON or
+// as multipart form with values JSON-encoded and files named
+// file:<categoryid>:<field key>. activityname and status are accepted but not
+// stored: names are derived from the kegiatan and new activities are always
+// pending review.
+type CompatCreateActivityRequest struct {
+	KegiatanID      string             `json:"kegiatanid"`
+	ActivityName    string             `json:"activityname"`
+	ActivityContent string             `json:"activitycontent"`
+	Status          string             `json:"status"`
+	Values          []CompatValueGroup `json:"values"`
+}
+
+type CompatCreateActivityResult struct {
+	ActivityID string   `json:"activityid"`
+	Inserted   int      `json:"inserted"`
+	Warnings   []string `json:"warnings"`
+}
+
+// legacyActivityStatus maps pending, approved and rejected to the aktivitas
+// statuses the frontend shows
+func legacyActivityStatus(status string) string {
+	switch status {
+	case "approved":
+		return "teacher_validated"
+	case "rejected":
+		return "rejected"
+	}
+	return "pending"
+}
+
+func compatActivity(a *models.Activity) CompatActivity {
+	result := CompatActivity{
+		ActivityID: a.ID.String(),
+		KegiatanID: a.KegiatanID.String(),
+		UserID:     a.UserProfileID.String(),
+		Status:     legacyActivityStatus(a.Status),
+		CreatedAt:  a.CreatedAt,
+		UpdatedAt:  a.UpdatedAt,
+	}
+
+	// The Go schema has no activity name; the Next.js app defaulted it to the
+	// kegiatan name and the submission time
+	name := "Aktivitas"
+	if a.Kegiatan != nil {
+		name = a.Kegiatan.Name
+		result.Kegiatan = &CompatKegiatanRef{KegiatanID: a.Kegiatan.ID.String(), KegiatanName: a.Kegiatan.Name}
+	}
+	result.ActivityName = name + " - " + a.CreatedAt.UTC().Format("2006-01-02 15:04")
+	if a.Notes != nil {
+		result.ActivityContent = *a.Notes
+	}
+	if a.UserProfile != nil {
+		username := a.UserProfile.Name
+		result.Profile = &CompatActivityProfile{Username: &username}
+	} else {
+		result.Profile = &CompatActivityProfile{}
+	}
+	return result
+}
+
+// compatFormData maps submitted category values to form_data keys of the
+// kegiatan's form. A field matches by key and, when the form field has one,
+// by category. Values are normalized like the Next.js app did: multiselect
+// to a list, text_image to its text, image to null (the file is sent
+// separately), anything else to a string.
+func compatFormData(schema *forms.Schema, groups []CompatValueGroup) (map[string]interface{}, []string) {
+	data := make(map[string]interface{})
+	warnings := []string{}
+	for _, group := range groups {
+		if group.CategoryID == "" {
+			continue
+		}
+		for _, value := range group.Fields {
+			field, ok := compatField(schema, group.CategoryID, value.Key)
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("Field tidak dikenal: category=%s key=%s", group.CategoryID, value.Key))
+				continue
+			}
+			fieldType := value.Type
+			if fieldType == "" {
+				fieldType = field.Type
+			}
+			data[field.Key] = compatValue(fieldType, value.Value)
+		}
+	}
+	return data, warnings
+}
+
+func compatField(schema *forms.Schema, categoryID, key string) (*forms.Field, bool) {
+	for i := range schema.Fields {
+		f := &schema.Fields[i]
+		if f.Key == key && (f.CategoryID == "" || strings.EqualFold(f.CategoryID, categoryID)) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func compatValue(fieldType string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch fieldType {
+	case forms.TypeMultiselect:
+		if list, ok := value.([]interface{}); ok {
+			options := make([]string, 0, len(list))
+			for _, option := range list {
+				options = append(options, compatString(option))
+			}
+			return options
+		}
+		return []string{compatString(value)}
+	case forms.TypeTextImage:
+		if object, ok := value.(map[string]interface{}); ok {
+			if text, ok := object["text"]; ok {
+				if text == nil {
+					return nil
+				}
+				return compatString(text)
+			}
+		}
+		return compatString(value)
+	case forms.TypeImage:
+		return nil
+	}
+	return compatString(value)
+}
+
+// compatString converts a JSON value to text the way String() does in
+// JavaScript for the values forms send
+func compatString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// GetActivities godoc
+// @Summary List activities (Next.js contract)
+// @Description Activities the user may see, newest first, in the shape of the aktivitas table
+// @Tags compat
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /aktivitas [get]
+func (h *CompatHandler) GetActivities(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
+
+	var activities []models.Activity
+	if err := subject.ScopeActivities(db.Model(&models.Activity{})).
+		Preload("UserProfile").
+		Preload("Kegiatan", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+		Order("created_at DESC").
+		Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activities"})
+		return
+	}
+
+	result := make([]CompatActivity, 0, len(activities))
+	for i := range activities {
+		result = append(result, compatActivity(&activities[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+type compatUpload struct {
+	categoryID string
+	key        string
+	filename   string
+	size       int64
+	open       func() ([]byte, string, error)
+}
+
+// bindCreateActivity reads the JSON or multipart body of POST /api/aktivitas
+func bindCreateActivity(c *gin.Context) (*CompatCreateActivityRequest, []compatUpload, error) {
+	var req CompatCreateActivityRequest
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		// An unreadable body counts as empty, as in the Next.js app
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return &CompatCreateActivityRequest{}, nil, nil
+		}
+		return &req, nil, nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil, err
+	}
+	get := func(key string) string {
+		if values := form.Value[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	req.KegiatanID = get("kegiatanid")
+	req.ActivityName = get("activityname")
+	req.ActivityContent = get("activitycontent")
+	req.Status = get("status")
+	if raw := get("values"); raw != "" {
+		// Malformed values are ignored, as the Next.js app did
+		_ = json.Unmarshal([]byte(raw), &req.Values)
+	}
+
+	var uploads []compatUpload
+	for name, headers := range form.File {
+		parts := strings.Split(name, ":")
+		if len(parts) != 3 || parts[0] != "file" {
+			continue
+		}
+		for _, header := range headers {
+			header := header
+			uploads = append(uploads, compatUpload{
+				categoryID: parts[1],
+				key:        parts[2],
+				filename:   header.Filename,
+				size:       header.Size,
+				open: func() ([]byte, string, error) {
+					file, err := header.Open()
+					if err != nil {
+						return nil, "", err
+					}
+					defer file.Close()
+					data, err := io.ReadAll(file)
+					return data, header.Header.Get("Content-Type"), err
+				},
+			})
+		}
+	}
+	return &req, uploads, nil
+}
+
+// CreateActivity godoc
+// @Summary Submit an activity (Next.js contract)
+// @Description One submission per kegiatan per day (Asia/Jakarta). Field values are grouped by category; files are sent as multipart parts named file:<categoryid>:<field key>, at most 5MB each.
+// @Tags compat
+// @Accept json,mpfd
+// @Produce json
+// @Security BearerAuth
+// @Param activity body CompatCreateActivityRequest true "Activity"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /aktivitas [post]
+func (h *CompatHandler) CreateActivity(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
+	req, uploads, err := bindCreateActivity(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+		return
+	}
+	if req.KegiatanID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kegiatanid wajib diisi"})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	kegiatanID, err := uuid.Parse(req.KegiatanID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kegiatan tidak ditemukan"})
+		return
+	}
+	if !checkSubmissionWindow(c, db, h.schedule, userID, kegiatanID) {
+		return
+	}
+
+	now := time.Now().In(compatTimezone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var existing models.Activity
+	err = db.Where("user_profile_id = ? AND kegiatan_id = ? AND DATE(date) = ?", userID, kegiatanID, today.Format("2006-01-02")).
+		First(&existing).Error
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "Kamu sudah mengirim aktivitas untuk kegiatan ini hari ini. Silakan coba lagi besok.",
+			"last_submission": existing.CreatedAt,
+		})
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check today's submissions"})
+		return
+	}
+
+	var kegiatan models.Kegiatan
+	if err := db.Where("id = ? AND is_active = ?", kegiatanID, true).First(&kegiatan).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kegiatan tidak ditemukan"})
+		return
+	}
+	schema, err := forms.Parse(kegiatan.FormSchema)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid form schema"})
+		return
+	}
+
+	data, warnings := compatFormData(schema, req.Values)
+
+	var files []models.ActivityFile
+	for _, upload := range uploads {
+		field, ok := compatField(schema, upload.categoryID, upload.key)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("File field tidak dikenal: category=%s key=%s", upload.categoryID, upload.key))
+			continue
+		}
+		if upload.size > compatMaxFileSize {
+			warnings = append(warnings, fmt.Sprintf("File %s terlalu besar (%dMB). Maksimal 5MB.", upload.filename, (upload.size+512*1024)/(1024*1024)))
+			continue
+		}
+		content, contentType, err := upload.open()
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Gagal upload %s: %v", upload.filename, err))
+			continue
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		file := models.ActivityFile{FieldKey: field.Key, ContentType: contentType, Data: content}
+		if upload.filename != "" {
+			filename := upload.filename
+			file.Filename = &filename
+		}
+		files = append(files, file)
+	}
+
+	uploaded := make(map[string]bool, len(files))
+	for _, file := range files {
+		uploaded[file.FieldKey] = true
+	}
+	if errs := schema.Validate(data, uploaded); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data form tidak valid", "fields": errs, "warnings": warnings})
+		return
+	}
+
+	formVersionID, err := currentFormVersion(db, &kegiatan)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load form version"})
+		return
+	}
+
+	activity := models.Activity{
+		UserProfileID: userID,
+		KegiatanID:    kegiatanID,
+		Date:          today,
+		FormVersionID: formVersionID,
+		Status:        "pending",
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if len(data) > 0 {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid field values"})
+			return
+		}
+		formData := string(encoded)
+		activity.FormData = &formData
+	}
+	if req.ActivityContent != "" {
+		activity.Notes = &req.ActivityContent
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&activity).Error; err != nil {
+			return err
+		}
+		for i := range files {
+			files[i].ActivityID = activity.ID
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		return tx.Create(&files).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create activity"})
+		return
+	}
+
+	if len(req.Values) == 0 && len(uploads) == 0 {
+		warnings = append(warnings, "Tidak ada nilai field yang dikirim.")
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": CompatCreateActivityResult{
+		ActivityID: activity.ID.String(),
+		Inserted:   len(data),
+		Warnings:   warnings,
+	}})
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/forms"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CompatCategoryInput is a category_fields row as the Next.js category
+// editor sends and reads it
+type CompatCategoryInput struct {
+	Key      string          `json:"key"`
+	Label    string          `json:"label"`
+	Type     string          `json:"type"`
+	Required bool            `json:"required"`
+	Order    int             `json:"order"`
+	Config   json.RawMessage `json:"config,omitempty"`
+}
+
+// CompatCategory is the payload of PATCH /api/category/:categoryid
+type CompatCategory struct {
+	CategoryID   string                `json:"categoryid"`
+	CategoryName string                `json:"categoryname"`
+	Inputs       []CompatCategoryInput `json:"inputs"`
+}
+
+// compatInputs validates category inputs the way the Next.js editor did and
+// returns them sorted by order. The error is the message for the client.
+func compatInputs(raw json.RawMessage) ([]CompatCategoryInput, error) {
+	var items []interface{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return []CompatCategoryInput{}, nil
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, errors.New("inputs must be an array")
+	}
+
+	inputs := make([]CompatCategoryInput, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("inputs[%d] must be an object", i)
+		}
+		text := func(name string) string {
+			if v, ok := object[name]; ok && v != nil && v != false && v != "" {
+				return strings.TrimSpace(compatString(v))
+			}
+			return ""
+		}
+
+		input := CompatCategoryInput{Key: text("key"), Label: text("label"), Type: text("type"), Order: i}
+		if input.Key == "" {
+			return nil, fmt.Errorf("inputs[%d].key is required", i)
+		}
+		if input.Label == "" {
+			return nil, fmt.Errorf("inputs[%d].label is required", i)
+		}
+		if !compatInputType(input.Type) {
+			return nil, fmt.Errorf("inputs[%d].type must be one of %s", i, strings.Join(forms.LegacyTypes, ", "))
+		}
+		if seen[input.Key] {
+			return nil, fmt.Errorf("inputs contain duplicate key: %s", input.Key)
+		}
+		seen[input.Key] = true
+
+		if required, ok := object["required"].(bool); ok {
+			input.Required = required
+		}
+		switch order := object["order"].(type) {
+		case float64:
+			input.Order = int(order)
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(order), 64); err == nil {
+				input.Order = int(n)
+			}
+		}
+		if config, ok := object["config"].(map[string]interface{}); ok {
+			input.Config, _ = json.Marshal(config)
+		}
+		inputs = append(inputs, input)
+	}
+
+	sort.SliceStable(inputs, func(i, j int) bool { return inputs[i].Order < inputs[j].Order })
+	return inputs, nil
+}
+
+// categoryFields returns the form fields of a category's inputs
+func categoryFields(categoryID uuid.UUID, inputs []CompatCategoryInput) []forms.Field {
+	fields := make([]forms.Field, 0, len(inputs))
+	for _, input := range inputs {
+		field := forms.Field{
+			Key:        input.Key,
+			Label:      input.Label,
+			Type:       input.Type,
+			Required:   input.Required,
+			Config:     input.Config,
+			CategoryID: categoryID.String(),
+		}
+		if len(input.Config) > 0 {
+			var config struct {
+				Options []string `json:"options"`
+			}
+			if json.Unmarshal(input.Config, &config) == nil {
+				field.Options = config.Options
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// compatCategoryInputs returns the fields of the category in a form, in the
+// shape of category inputs
+func compatCategoryInputs(schema *forms.Schema, categoryID uuid.UUID) []CompatCategoryInput {
+	inputs := []CompatCategoryInput{}
+	for _, f := range schema.Fields {
+		if !strings.EqualFold(f.CategoryID, categoryID.String()) {
+			continue
+		}
+		inputs = append(inputs, CompatCategoryInput{
+			Key:      f.Key,
+			Label:    f.Label,
+			Type:     f.Type,
+			Required: f.Required,
+			Order:    len(inputs),
+			Config:   f.Config,
+		})
+	}
+	return inputs
+}
+
+// replaceCategoryFields replaces the fields of the category in a form with
+// the given ones, where the first of them was. A key already used by a field
+// of another category is refused.
+func replaceCategoryFields(schema *forms.Schema, categoryID uuid.UUID, fields []forms.Field) error {
+	position := -1
+	kept := make([]forms.Field, 0, len(schema.Fields))
+	used := make(map[string]bool)
+	for _, f := range schema.Fields {
+		if strings.EqualFold(f.CategoryID, categoryID.String()) {
+			if position < 0 {
+				position = len(kept)
+			}
+			continue
+		}
+		kept = append(kept, f)
+		used[f.Key] = true
+	}
+	if position < 0 {
+		position = len(kept)
+	}
+	for _, f := range fields {
+		if used[f.Key] {
+			return fmt.Errorf("key %s is already used by another field of the form", f.Key)
+		}
+	}
+
+	result := make([]forms.Field, 0, len(kept)+len(fields))
+	result = append(result, kept[:position]...)
+	result = append(result, fields...)
+	result = append(result, kept[position:]...)
+	schema.Fields = result
+	return nil
+}
+
+// categoryKegiatan loads the kegiatan of a category, and those whose form
+// has fields of the category
+func categoryKegiatan(db *gorm.DB, categoryID uuid.UUID) ([]models.Kegiatan, error) {
+	// Matches forms with at least one field of the category
+	probe := fmt.Sprintf(`{"fields":[{"category_id":%q}]}`, categoryID.String())
+
+	var kegiatan []models.Kegiatan
+	err := db.Where("category_id = ? OR form_schema @> ?::jsonb", categoryID, probe).
+		Order("created_at ASC").
+		Find(&kegiatan).Error
+	return kegiatan, err
+}
+
+// UpdateCategory godoc
+// @Summary Rename a category or replace its inputs (Next.js contract)
+// @Description Replacing inputs rewrites the fields of the category in the form of every kegiatan that uses it, as a new form version; needs kegiatan.manage as well. Submitted activities keep their values and form version.
+// @Tags compat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param categoryid path string true "Category ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /category/{categoryid} [patch]
+func (h *CompatHandler) UpdateCategory(c *gin.Context) {
+	var body map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var category models.Category
+	if _, err := uuid.Parse(c.Param("categoryid")); err != nil || h.db.Where("id = ?", c.Param("categoryid")).First(&category).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+	before := category
+
+	var name *string
+	if raw, ok := body["categoryname"]; ok {
+		var value string
+		if json.Unmarshal(raw, &value) == nil {
+			value = strings.TrimSpace(value)
+			name = &value
+		}
+	}
+
+	var inputs []CompatCategoryInput
+	rawInputs, replaceInputs := body["inputs"]
+	if replaceInputs {
+		if !middleware.HasPermission(c, auth.PermKegiatanManage) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		var err error
+		if inputs, err = compatInputs(rawInputs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var kegiatan []models.Kegiatan
+	var badRequest error
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if name != nil && *name != category.Name {
+			category.Name = *name
+			category.UpdatedAt = time.Now()
+			if err := tx.Save(&category).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if kegiatan, err = categoryKegiatan(tx, category.ID); err != nil {
+			return err
+		}
+		if !replaceInputs {
+			return nil
+		}
+
+		fields := categoryFields(category.ID, inputs)
+		for i := range kegiatan {
+			schema, err := forms.Parse(kegiatan[i].FormSchema)
+			if err != nil {
+				return err
+			}
+			if err := replaceCategoryFields(schema, category.ID, fields); err != nil {
+				badRequest = fmt.Errorf("kegiatan %s: %w", kegiatan[i].Name, err)
+				return badRequest
+			}
+			encoded, err := schema.Encode()
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&kegiatan[i]).Updates(map[string]interface{}{"form_schema": encoded, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+			kegiatan[i].FormSchema = &encoded
+			if _, err := forms.Publish(tx, &kegiatan[i], formAuthor(c)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if badRequest != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": badRequest.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+
+	if !replaceInputs {
+		inputs = []CompatCategoryInput{}
+		for i := range kegiatan {
+			schema, err := forms.Parse(kegiatan[i].FormSchema)
+			if err != nil {
+				continue
+			}
+			if found := compatCategoryInputs(schema, category.ID); len(found) > 0 {
+				inputs = found
+				break
+			}
+		}
+	}
+
+	entry := audit.Entry{Action: "category.update", TargetType: "category", TargetID: category.ID.String(), Before: before, After: category}
+	if replaceInputs {
+		ids := make([]string, 0, len(kegiatan))
+		for _, k := range kegiatan {
+			ids = append(ids, k.ID.String())
+		}
+		entry.Details = gin.H{"inputs": inputs, "kegiatan": ids}
+	}
+	audit.Record(c, entry)
+
+	c.JSON(http.StatusOK, gin.H{"data": CompatCategory{
+		CategoryID:   category.ID.String(),
+		CategoryName: category.Name,
+		Inputs:       inputs,
+	}})
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category (Next.js contract)
+// @Description Removes the fields of the category from the forms of kegiatan; submitted activities keep their values
+// @Tags compat
+// @Produce json
+// @Security BearerAuth
+// @Param categoryid path string true "Category ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Router /category/{categoryid} [delete]
+func (h *CompatHandler) DeleteCategory(c *gin.Context) {
+	var category models.Category
+	if _, err := uuid.Parse(c.Param("categoryid")); err != nil || h.db.Where("id = ?", c.Param("categoryid")).First(&category).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		kegiatan, err := categoryKegiatan(tx, category.ID)
+		if err != nil {
+			return err
+		}
+		for i := range kegiatan {
+			schema, err := forms.Parse(kegiatan[i].FormSchema)
+			if err != nil || len(compatCategoryInputs(schema, category.ID)) == 0 {
+				continue
+			}
+			if err := replaceCategoryFields(schema, category.ID, nil); err != nil {
+				return err
+			}
+			encoded, err := schema.Encode()
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&kegiatan[i]).Updates(map[string]interface{}{"form_schema": encoded, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+			kegiatan[i].FormSchema = &encoded
+			if _, err := forms.Publish(tx, &kegiatan[i], formAuthor(c)); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "category.delete", TargetType: "category", TargetID: category.ID.String(), Before: category})
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// compatInputType reports whether t is a type the Next.js editor offered
+func compatInputType(t string) bool {
+	for _, known := range forms.LegacyTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CompatCommentAuthor is user_profiles(userid, username, roleid)
+type CompatCommentAuthor struct {
+	UserID   string `json:"userid"`
+	Username string `json:"username"`
+	RoleID   int    `json:"roleid"`
+}
+
+// CompatComment is a komentar row as /api/komentar returns it
+type CompatComment struct {
+	KomentarID   string               `json:"komentarid"`
+	Content      string               `json:"content"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	UserID       string               `json:"userid"`
+	UserProfiles *CompatCommentAuthor `json:"user_profiles"`
+}
+
+type CompatCreateCommentRequest struct {
+	Content string `json:"content"`
+	SiswaID string `json:"siswaid"`
+}
+
+func compatComment(comment *models.StudentComment) CompatComment {
+	result := CompatComment{
+		KomentarID: comment.ID.String(),
+		Content:    comment.Content,
+		CreatedAt:  comment.CreatedAt,
+		UpdatedAt:  comment.UpdatedAt,
+		UserID:     comment.UserProfileID.String(),
+	}
+	if author := comment.UserProfile; author != nil {
+		result.UserProfiles = &CompatCommentAuthor{
+			UserID:   author.ID.String(),
+			Username: author.Name,
+			RoleID:   legacyRoleID(author.Role),
+		}
+	}
+	return result
+}
+
+// student loads the student a comment is about when the subject may see
+// them, answering the request itself otherwise
+func (h *CompatHandler) student(c *gin.Context, subject *policy.Subject, id string) (*models.UserProfile, bool) {
+	db := middleware.DB(c, h.db)
+
+	var student models.UserProfile
+	if _, err := uuid.Parse(id); err != nil || db.Where("id = ?", id).First(&student).Error != nil || !subject.Can(policy.ActionView, &student) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return nil, false
+	}
+	return &student, true
+}
+
+// GetComments godoc
+// @Summary List comments about a student (Next.js contract)
+// @Tags compat
+// @Produce json
+// @Security BearerAuth
+// @Param siswa_id query string true "Student ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /komentar [get]
+func (h *CompatHandler) GetComments(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
+	siswaID := c.Query("siswa_id")
+	if siswaID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing siswa_id parameter"})
+		return
+	}
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
+	student, ok := h.student(c, subject, siswaID)
+	if !ok {
+		return
+	}
+
+	var comments []models.StudentComment
+	if err := db.Preload("UserProfile").
+		Where("student_id = ?", student.ID).
+		Order("created_at ASC").
+		Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	result := make([]CompatComment, 0, len(comments))
+	for i := range comments {
+		result = append(result, compatComment(&comments[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// CreateComment godoc
+// @Summary Comment on a student (Next.js contract)
+// @Tags compat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param comment body CompatCreateCommentRequest true "Comment"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /komentar [post]
+func (h *CompatHandler) CreateComment(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
+	var req CompatCreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content is required"})
+		return
+	}
+	if req.SiswaID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Student ID is required"})
+		return
+	}
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
+	student, ok := h.student(c, subject, req.SiswaID)
+	if !ok {
+		return
+	}
+	if !subject.Can(policy.ActionComment, student) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	comment := models.StudentComment{
+		StudentID:     student.ID,
+		UserProfileID: subject.UserID,
+		Content:       content,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if err := db.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	db.Preload("UserProfile").First(&comment, comment.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": compatComment(&comment), "message": "Comment created successfully"})
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment about a student (Next.js contract)
+// @Tags compat
+// @Produce json
+// @Security BearerAuth
+// @Param comment_id query string true "Comment ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /komentar [delete]
+func (h *CompatHandler) DeleteComment(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
+	commentID := c.Query("comment_id")
+	if commentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing comment_id parameter"})
+		return
+	}
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
+
+	var comment models.StudentComment
+	if _, err := uuid.Parse(commentID); err != nil ||
+		db.Preload("Student").Where("id = ?", commentID).First(&comment).Error != nil ||
+		!subject.Can(policy.ActionView, &comment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if !subject.Can(policy.ActionDelete, &comment) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this comment"})
+		return
+	}
+
+	if err := db.Delete(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	if comment.UserProfileID != subject.UserID {
+		comment.Student = nil
+		audit.Record(c, audit.Entry{Action: "student_comment.delete", TargetType: "student_comment", TargetID: comment.ID.String(), Before: comment})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/forms"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// The tests below pin the JSON the Next.js frontend reads from the compat
+// routes. A change here breaks pages that have moved off Supabase.
+
+var (
+	compatTime     = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	compatStudent  = &models.UserProfile{ID: uuid.MustParse("11111111-1111-1111-1111-111111111111"), Name: "Budi", Class: "7A", Role: "siswa", CreatedAt: compatTime, UpdatedAt: compatTime}
+	compatTeacher  = &models.UserProfile{ID: uuid.MustParse("22222222-2222-2222-2222-222222222222"), Name: "Bu Sari", Role: "guru"}
+	compatKegiatan = &models.Kegiatan{ID: uuid.MustParse("33333333-3333-3333-3333-333333333333"), Name: "Bangun Pagi"}
+	compatCategory = uuid.MustParse("44444444-4444-4444-4444-444444444444")
+)
+
+func assertJSON(t *testing.T, name string, value interface{}, want string) {
+	t.Helper()
+	got, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("%s: invalid expected JSON: %v", name, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("%s:\n got  %s\n want %s", name, got, want)
+	}
+}
+
+func TestCompatActivityShape(t *testing.T) {
+	notes := "Bangun jam 5"
+	activity := &models.Activity{
+		ID:            uuid.MustParse("55555555-5555-5555-5555-555555555555"),
+		UserProfileID: compatStudent.ID,
+		KegiatanID:    compatKegiatan.ID,
+		Status:        "approved",
+		Notes:         &notes,
+		CreatedAt:     compatTime,
+		UpdatedAt:     compatTime,
+		UserProfile:   compatStudent,
+		Kegiatan:      compatKegiatan,
+	}
+	assertJSON(t, "aktivitas", gin.H{"data": []CompatActivity{compatActivity(activity)}}, `{"data": [{
+		"activityid": "55555555-5555-5555-5555-555555555555",
+		"activityname": "Bangun Pagi - 2025-01-02 03:04",
+		"activitycontent": "Bangun jam 5",
+		"kegiatanid": "33333333-3333-3333-3333-333333333333",
+		"userid": "11111111-1111-1111-1111-111111111111",
+		"status": "teacher_validated",
+		"created_at": "2025-01-02T03:04:05Z",
+		"updated_at": "2025-01-02T03:04:05Z",
+		"kegiatan": {"kegiatanid": "33333333-3333-3333-3333-333333333333", "kegiatanname": "Bangun Pagi"},
+		"profile": {"username": "Budi"}
+	}]}`)
+
+	activity.Notes, activity.Kegiatan, activity.UserProfile = nil, nil, nil
+	activity.Status = "pending"
+	assertJSON(t, "aktivitas without relations", compatActivity(activity), `{
+		"activityid": "55555555-5555-5555-5555-555555555555",
+		"activityname": "Aktivitas - 2025-01-02 03:04",
+		"activitycontent": "",
+		"kegiatanid": "33333333-3333-3333-3333-333333333333",
+		"userid": "11111111-1111-1111-1111-111111111111",
+		"status": "pending",
+		"created_at": "2025-01-02T03:04:05Z",
+		"updated_at": "2025-01-02T03:04:05Z",
+		"kegiatan": null,
+		"profile": {"username": null}
+	}`)
+
+	assertJSON(t, "created", gin.H{"data": CompatCreateActivityResult{ActivityID: "55555555-5555-5555-5555-555555555555", Inserted: 2, Warnings: []string{}}},
+		`{"data": {"activityid": "55555555-5555-5555-5555-555555555555", "inserted": 2, "warnings": []}}`)
+}
+
+func TestCompatFormData(t *testing.T) {
+	schema := &forms.Schema{Fields: []forms.Field{
+		{Key: "jam", Type: forms.TypeTime, CategoryID: compatCategory.String()},
+		{Key: "perasaan", Type: forms.TypeMultiselect, CategoryID: compatCategory.String()},
+		{Key: "foto", Type: forms.TypeTextImage, CategoryID: compatCategory.String()},
+		{Key: "catatan", Type: forms.TypeText},
+	}}
+	var groups []CompatValueGroup
+	err := json.Unmarshal([]byte(`[
+		{"categoryid": "44444444-4444-4444-4444-444444444444", "fields": [
+			{"key": "jam", "value": "05:00"},
+			{"key": "perasaan", "type": "multiselect", "value": ["Senang", "Semangat"]},
+			{"key": "foto", "type": "text_image", "value": {"text": "Sarapan"}},
+			{"key": "lain", "value": "x"}
+		]},
+		{"categoryid": "99999999-9999-9999-9999-999999999999", "fields": [
+			{"key": "jam", "value": "06:00"},
+			{"key": "catatan", "value": 7}
+		]}
+	]`), &groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, warnings := compatFormData(schema, groups)
+	assertJSON(t, "form_data", data, `{"jam": "05:00", "perasaan": ["Senang", "Semangat"], "foto": "Sarapan", "catatan": "7"}`)
+	assertJSON(t, "warnings", warnings, `[
+		"Field tidak dikenal: category=44444444-4444-4444-4444-444444444444 key=lain",
+		"Field tidak dikenal: category=99999999-9999-9999-9999-999999999999 key=jam"
+	]`)
+}
+
+func TestCompatCommentShape(t *testing.T) {
+	comment := &models.StudentComment{
+		ID:            uuid.MustParse("66666666-6666-6666-6666-666666666666"),
+		StudentID:     compatStudent.ID,
+		UserProfileID: compatTeacher.ID,
+		Content:       "Pertahankan!",
+		CreatedAt:     compatTime,
+		UpdatedAt:     compatTime,
+		UserProfile:   compatTeacher,
+	}
+	assertJSON(t, "komentar", gin.H{"data": compatComment(comment), "message": "Comment created successfully"}, `{
+		"data": {
+			"komentarid": "66666666-6666-6666-6666-666666666666",
+			"content": "Pertahankan!",
+			"created_at": "2025-01-02T03:04:05Z",
+			"updated_at": "2025-01-02T03:04:05Z",
+			"userid": "22222222-2222-2222-2222-222222222222",
+			"user_profiles": {"userid": "22222222-2222-2222-2222-222222222222", "username": "Bu Sari", "roleid": 2}
+		},
+		"message": "Comment created successfully"
+	}`)
+
+	comment.UserProfile = nil
+	if got := compatComment(comment); got.UserProfiles != nil {
+		t.Errorf("user_profiles of an unknown author = %+v, want null", got.UserProfiles)
+	}
+}
+
+func TestCompatCategoryShape(t *testing.T) {
+	inputs, err := compatInputs(json.RawMessage(`[
+		{"key": "perasaan", "label": "Perasaan", "type": "multiselect", "order": 2, "config": {"options": ["Senang", "Sedih"]}},
+		{"key": "jam", "label": "Jam bangun", "type": "time", "required": true, "order": "1"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	category := CompatCategory{CategoryID: compatCategory.String(), CategoryName: "Bangun Pagi", Inputs: inputs}
+	assertJSON(t, "category", gin.H{"data": category}, `{"data": {
+		"categoryid": "44444444-4444-4444-4444-444444444444",
+		"categoryname": "Bangun Pagi",
+		"inputs": [
+			{"key": "jam", "label": "Jam bangun", "type": "time", "required": true, "order": 1},
+			{"key": "perasaan", "label": "Perasaan", "type": "multiselect", "required": false, "order": 2, "config": {"options": ["Senang", "Sedih"]}}
+		]
+	}}`)
+
+	// Inputs round-trip through the form of a kegiatan
+	schema := &forms.Schema{Fields: []forms.Field{{Key: "catatan", Type: forms.TypeText}}}
+	if err := replaceCategoryFields(schema, compatCategory, categoryFields(compatCategory, inputs)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(compatCategoryInputs(schema, compatCategory), []CompatCategoryInput{
+		{Key: "jam", Label: "Jam bangun", Type: "time", Required: true, Order: 0},
+		{Key: "perasaan", Label: "Perasaan", Type: "multiselect", Order: 1, Config: inputs[1].Config},
+	}) {
+		t.Errorf("inputs read back from the form: %+v", compatCategoryInputs(schema, compatCategory))
+	}
+	if f, _ := schema.Field("perasaan"); !reflect.DeepEqual(f.Options, []string{"Senang", "Sedih"}) {
+		t.Errorf("multiselect options = %v", f.Options)
+	}
+	if err := replaceCategoryFields(schema, uuid.New(), []forms.Field{{Key: "catatan"}}); err == nil {
+		t.Error("a key used by another category should be refused")
+	}
+
+	for raw, want := range map[string]string{
+		`{}`:                                 "inputs must be an array",
+		`[1]`:                                "inputs[0] must be an object",
+		`[{"label": "Jam", "type": "time"}]`: "inputs[0].key is required",
+		`[{"key": "jam", "type": "time"}]`:   "inputs[0].label is required",
+		`[{"key": "jam", "label": "Jam", "type": "date"}]`:                                         "inputs[0].type must be one of text, time, image, text_image, multiselect",
+		`[{"key": "a", "label": "A", "type": "text"}, {"key": "a", "label": "B", "type": "text"}]`: "inputs contain duplicate key: a",
+	} {
+		if _, err := compatInputs(json.RawMessage(raw)); err == nil || err.Error() != want {
+			t.Errorf("compatInputs(%s) error = %v, want %q", raw, err, want)
+		}
+	}
+}
+
+func TestCompatUserProfileShape(t *testing.T) {
+	assertJSON(t, "student", gin.H{"data": compatUserProfile(compatStudent, nil)}, `{"data": {
+		"userid": "11111111-1111-1111-1111-111111111111",
+		"username": "Budi",
+		"email": null,
+		"roleid": 5,
+		"kelas": "7A",
+		"parent_of_userid": null,
+		"created_at": "2025-01-02T03:04:05Z",
+		"updated_at": "2025-01-02T03:04:05Z"
+	}}`)
+
+	parent := &models.UserProfile{ID: uuid.MustParse("77777777-7777-7777-7777-777777777777"), Name: "Pak Budi", Role: "orangtua", CreatedAt: compatTime, UpdatedAt: compatTime}
+	got := compatUserProfile(parent, &compatStudent.ID)
+	if got.RoleID != 4 || got.Kelas != nil || got.ParentOfUserID == nil || *got.ParentOfUserID != compatStudent.ID.String() {
+		t.Errorf("parent profile = %+v", got)
+	}
+
+	if id := legacyRoleID("bk"); id != legacyUnknownRoleID {
+		t.Errorf("custom role roleid = %d, want %d", id, legacyUnknownRoleID)
+	}
+	for _, r := range legacyRoles {
+		if role, ok := roleForLegacyID(r.ID); !ok || role != r.Role || legacyRoleID(role) != r.ID {
+			t.Errorf("roleid %d does not round-trip: %q", r.ID, role)
+		}
+	}
+	if _, ok := roleForLegacyID(legacyUnknownRoleID); ok {
+		t.Error("Unknown should not map to a role")
+	}
+}
+
+func TestCompatSubmissionWindowShape(t *testing.T) {
+	assertJSON(t, "never changed", gin.H{"data": compatSubmissionWindow(true, nil, nil)},
+		`{"data": {"open": true, "updatedAt": null, "updatedBy": null}}`)
+
+	updatedAt := compatTime
+	admin := &models.UserProfile{ID: uuid.MustParse("88888888-8888-8888-8888-888888888888"), Name: "Admin", Role: "admin"}
+	assertJSON(t, "window", gin.H{"data": compatSubmissionWindow(false, &updatedAt, admin)}, `{"data": {
+		"open": false,
+		"updatedAt": "2025-01-02T03:04:05Z",
+		"updatedBy": {"userid": "88888888-8888-8888-8888-888888888888", "username": "Admin", "role": {"rolename": "admin"}}
+	}}`)
+}
+
+// TestCompatValidation checks the error responses that are answered before
+// the database is used
+func TestCompatValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewCompatHandler(nil, nil, nil)
+	r := gin.New()
+	r.POST("/api/aktivitas", h.CreateActivity)
+	r.GET("/api/komentar", h.GetComments)
+	r.DELETE("/api/komentar", h.DeleteComment)
+	r.POST("/api/komentar", h.CreateComment)
+	r.POST("/api/submission-window", h.UpdateSubmissionWindow)
+	r.PATCH("/api/user-profiles/:userid", h.UpdateUserProfile)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"POST", "/api/aktivitas", `{"values": []}`, http.StatusBadRequest, `{"error": "kegiatanid wajib diisi"}`},
+		{"POST", "/api/aktivitas", `not json`, http.StatusBadRequest, `{"error": "kegiatanid wajib diisi"}`},
+		{"GET", "/api/komentar", ``, http.StatusBadRequest, `{"error": "Missing siswa_id parameter"}`},
+		{"DELETE", "/api/komentar", ``, http.StatusBadRequest, `{"error": "Missing comment_id parameter"}`},
+		{"POST", "/api/komentar", `{"content": " ", "siswaid": "x"}`, http.StatusBadRequest, `{"error": "Content is required"}`},
+		{"POST", "/api/komentar", `{"content": "Bagus"}`, http.StatusBadRequest, `{"error": "Student ID is required"}`},
+		{"POST", "/api/submission-window", `{"open": "yes"}`, http.StatusBadRequest, `{"error": "Parameter 'open' wajib bertipe boolean"}`},
+		{"POST", "/api/submission-window", `{}`, http.StatusBadRequest, `{"error": "Parameter 'open' wajib bertipe boolean"}`},
+		{"PATCH", "/api/user-profiles/" + compatStudent.ID.String(), `{"nis": "123"}`, http.StatusBadRequest, `{"error": "No updatable fields provided"}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		name := tt.method + " " + tt.path + " " + strings.TrimSpace(tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", name, w.Code, tt.status)
+		}
+		var got interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		assertJSON(t, name, got, tt.want)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CompatUserProfile is a user_profiles row as /api/user-profiles returns it.
+// Email is always null: accounts have no email in the Go schema.
+type CompatUserProfile struct {
+	UserID         string    `json:"userid"`
+	Username       string    `json:"username"`
+	Email          *string   `json:"email"`
+	RoleID         int       `json:"roleid"`
+	Kelas          *string   `json:"kelas"`
+	ParentOfUserID *string   `json:"parent_of_userid"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// compatUserProfile builds the row of a user; parentOf is the child linked
+// to a parent, of which the Next.js app allowed only one
+func compatUserProfile(profile *models.UserProfile, parentOf *uuid.UUID) CompatUserProfile {
+	result := CompatUserProfile{
+		UserID:    profile.ID.String(),
+		Username:  profile.Name,
+		RoleID:    legacyRoleID(profile.Role),
+		CreatedAt: profile.CreatedAt,
+		UpdatedAt: profile.UpdatedAt,
+	}
+	if profile.Class != "" {
+		class := profile.Class
+		result.Kelas = &class
+	}
+	if parentOf != nil {
+		child := parentOf.String()
+		result.ParentOfUserID = &child
+	}
+	return result
+}
+
+// loadCompatUser loads a user and the first child linked to them
+func (h *CompatHandler) loadCompatUser(id string) (*models.UserProfile, *uuid.UUID, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", id).First(&profile).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var link models.ParentStudent
+	err := h.db.Where("parent_id = ?", profile.ID).Order("created_at ASC").First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &profile, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &profile, &link.StudentID, nil
+}
+
+// GetUserProfile godoc
+// @Summary Get a user (Next.js contract)
+// @Description Users the caller may see, or any user with user.manage
+// @Tags compat
+// @Produce json
+// @Security BearerAuth
+// @Param userid path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Router /user-profiles/{userid} [get]
+func (h *CompatHandler) GetUserProfile(c *gin.Context) {
+	profile, parentOf, err := h.loadCompatUser(c.Param("userid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !middleware.HasPermission(c, auth.PermUserManage) {
+		subject, ok := loadSubject(c, h.db)
+		if !ok {
+			return
+		}
+		if !subject.Can(policy.ActionView, profile) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": compatUserProfile(profile, parentOf)})
+}
+
+// UpdateUserProfile godoc
+// @Summary Update a user (Next.js contract)
+// @Description Updates username (display name), roleid, kelas and parent_of_userid, which replaces the children linked to the user. email is accepted and ignored.
+// @Tags compat
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userid path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user-profiles/{userid} [patch]
+func (h *CompatHandler) UpdateUserProfile(c *gin.Context) {
+	var body map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updatable := false
+	for _, key := range []string{"username", "email", "roleid", "kelas", "parent_of_userid"} {
+		if _, ok := body[key]; ok {
+			updatable = true
+		}
+	}
+	if !updatable {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No updatable fields provided"})
+		return
+	}
+
+	profile, parentOf, err := h.loadCompatUser(c.Param("userid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	before := compatUserProfile(profile, parentOf)
+
+	var role string
+	if raw, ok := body["roleid"]; ok {
+		var roleID int
+		known := false
+		if json.Unmarshal(raw, &roleID) == nil {
+			role, known = roleForLegacyID(roleID)
+		}
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown roleid"})
+			return
+		}
+	}
+
+	// Checked against the stored roles, before any field is changed
+	var extraRoles []string
+	if role != "" {
+		extraRoles = append(extraRoles, role)
+	}
+	if !checkManageableUser(c, h.db, h.permissions, profile, extraRoles...) {
+		return
+	}
+
+	if raw, ok := body["username"]; ok {
+		var name string
+		if json.Unmarshal(raw, &name) != nil || strings.TrimSpace(name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username must be a non-empty string"})
+			return
+		}
+		profile.Name = strings.TrimSpace(name)
+	}
+	if raw, ok := body["kelas"]; ok {
+		var class *string
+		if json.Unmarshal(raw, &class) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kelas must be a string or null"})
+			return
+		}
+		profile.Class = ""
+		if class != nil {
+			profile.Class = strings.TrimSpace(*class)
+		}
+	}
+	if role != "" {
+		profile.Role = role
+	}
+
+	var child *uuid.UUID
+	raw, linkChild := body["parent_of_userid"]
+	if linkChild {
+		if json.Unmarshal(raw, &child) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_of_userid must be a user ID or null"})
+			return
+		}
+		if child != nil {
+			var student models.UserProfile
+			if err := h.db.Where("id = ?", *child).First(&student).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "parent_of_userid does not exist"})
+				return
+			}
+		}
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		profile.UpdatedAt = time.Now()
+		if err := tx.Save(profile).Error; err != nil {
+			return err
+		}
+		if !linkChild {
+			return nil
+		}
+		unlink := tx.Where("parent_id = ?", profile.ID)
+		if child != nil {
+			unlink = unlink.Where("student_id <> ?", *child)
+		}
+		if err := unlink.Delete(&models.ParentStudent{}).Error; err != nil {
+			return err
+		}
+		if child == nil {
+			return nil
+		}
+
+		// The pair is unique even when unlinked, so an old link is restored
+		var link models.ParentStudent
+		err := tx.Unscoped().Where("parent_id = ? AND student_id = ?", profile.ID, *child).First(&link).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&models.ParentStudent{ParentID: profile.ID, StudentID: *child, CreatedAt: time.Now(), UpdatedAt: time.Now()}).Error
+		}
+		if err != nil || !link.DeletedAt.Valid {
+			return err
+		}
+		return tx.Unscoped().Model(&link).Updates(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if linkChild {
+		parentOf = child
+	}
+
+	after := compatUserProfile(profile, parentOf)
+	audit.Record(c, audit.Entry{Action: "user.update", TargetType: "user", TargetID: profile.ID.String(), Before: before, After: after})
+
+	c.JSON(http.StatusOK, gin.H{"data": after})
+}
+
+// DeleteUserProfile godoc
+// @Summary Delete a user (Next.js contract)
+// @Tags compat
+// @Produce json
+// @Security BearerAuth
+// @Param userid path string true "User ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Router /user-profiles/{userid} [delete]
+func (h *CompatHandler) DeleteUserProfile(c *gin.Context) {
+	profile, _, err := h.loadCompatUser(c.Param("userid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, profile) {
+		return
+	}
+
+	if err := h.db.Delete(profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "user.delete", TargetType: "user", TargetID: profile.ID.String(), Before: profile})
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// PINs only have to be unique per username, so students keep a short code
+	// while staff accounts get a longer one.
+	defaultPINLength = 6
+	staffPINLength   = 8
+
+	maxCredentialAttempts = 10
+	maxUsernameBaseLength = 40
+)
+
+var (
+	errNISTaken           = errors.New("NIS is already registered")
+	errInvalidCredentials = errors.New("invalid credentials")
+
+	usernameUnsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// pinLength returns the number of digits in a generated PIN for a role
+func pinLength(role string) int {
+	if auth.RoleRequiresPassword(role) {
+		return staffPINLength
+	}
+	return defaultPINLength
+}
+
+// randomDigits returns a cryptographically-random string of n digits
+func randomDigits(n int) (string, error) {
+	var sb strings.Builder
+	ten := big.NewInt(10)
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, ten)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(byte('0' + d.Int64()))
+	}
+	return sb.String(), nil
+}
+
+// hashPIN salts the PIN with the profile ID so equal PINs never share a hash
+func hashPIN(userID uuid.UUID, pin string) string {
+	return hashToken(userID.String() + ":" + pin)
+}
+
+// credentialMatches checks a PIN, or the legacy 4-digit token for accounts
+// that have not been migrated yet
+func credentialMatches(profile *models.UserProfile, code string) bool {
+	var expected, actual string
+	switch {
+	case profile.PINHash != nil:
+		expected, actual = *profile.PINHash, hashPIN(profile.ID, code)
+	case profile.TokenHash != nil:
+		expected, actual = *profile.TokenHash, hashToken(code)
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+// normalizeUsername lowercases and trims a login identifier
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// usernameBase derives the preferred username: the NIS when there is one,
+// otherwise class + the first two words of the name (e.g. "7a.budi.santoso")
+func usernameBase(profile *models.UserProfile) string {
+	if profile.NIS != nil && *profile.NIS != "" {
+		return normalizeUsername(*profile.NIS)
+	}
+
+	words := strings.Fields(strings.ToLower(profile.Name))
+	if len(words) > 2 {
+		words = words[:2]
+	}
+	parts := []string{}
+	if class := usernameUnsafeChars.ReplaceAllString(strings.ToLower(profile.Class), ""); class != "" {
+		parts = append(parts, class)
+	}
+	for _, w := range words {
+		if w = usernameUnsafeChars.ReplaceAllString(w, ""); w != "" {
+			parts = append(parts, w)
+		}
+	}
+
+	base := strings.Join(parts, ".")
+	if len(base) > maxUsernameBaseLength {
+		base = strings.TrimRight(base[:maxUsernameBaseLength], ".")
+	}
+	if base == "" {
+		base = "user"
+	}
+	return base
+}
+
+// nextFreeUsername returns base, or base followed by the lowest free number
+func nextFreeUsername(db *gorm.DB, base string) (string, error) {
+	var taken []string
+	if err := db.Unscoped().Model(&models.UserProfile{}).
+		Where("username = ? OR username LIKE ?", base, escapeLike(base)+"%").
+		Pluck("username", &taken).Error; err != nil {
+		return "", err
+	}
+
+	used := make(map[string]bool, len(taken))
+	for _, u := range taken {
+		used[u] = true
+	}
+	if !used[base] {
+		return base, nil
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s%d", base, n)
+		if !used[candidate] {
+			return candidate, nil
+		}
+	}
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern; Postgres uses the
+// backslash as the escape character by default
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// issueCredentials gives the profile a fresh PIN and, if it has none yet, a
+// username, then creates (create=true) or saves the profile. A username that
+// was taken between lookup and insert is retried with the next candidate.
+// Legacy 4-digit tokens are cleared, which migrates the account to the new
+// username + PIN login.
+func issueCredentials(db *gorm.DB, profile *models.UserProfile, create bool) (string, error) {
+	if create && profile.NIS != nil {
+		var count int64
+		if err := db.Unscoped().Model(&models.UserProfile{}).
+			Where("nis = ?", *profile.NIS).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count > 0 {
+			return "", errNISTaken
+		}
+	}
+
+	pin, err := randomDigits(pinLength(profile.Role))
+	if err != nil {
+		return "", err
+	}
+	pinHash := hashPIN(profile.ID, pin)
+	profile.PINHash = &pinHash
+	profile.TokenHash = nil
+
+	generateUsername := profile.Username == nil
+	for attempt := 1; ; attempt++ {
+		if generateUsername {
+			username, err := nextFreeUsername(db, usernameBase(profile))
+			if err != nil {
+				return "", err
+			}
+			profile.Username = &username
+		}
+
+		// Each write runs in its own (nested) transaction so a duplicate key
+		// rolls back to a savepoint instead of aborting the caller's transaction
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if create {
+				return tx.Create(profile).Error
+			}
+			return tx.Save(profile).Error
+		})
+		if err == nil {
+			return pin, nil
+		}
+		if !generateUsername || !errors.Is(err, gorm.ErrDuplicatedKey) || attempt >= maxCredentialAttempts {
+			return "", err
+		}
+	}
+}
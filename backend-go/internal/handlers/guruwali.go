@@ -7,6 +7,7 @@ import (
 	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -18,11 +19,37 @@ func NewGuruWaliHandler(db *gorm.DB) *GuruWaliHandler {
 	return &GuruWaliHandler{db: db}
 }
 
+// student loads the student in the path when the guru wali supervises them,
+// answering the request itself otherwise
+func (h *GuruWaliHandler) student(c *gin.Context) (*models.UserProfile, bool) {
+	db := middleware.DB(c, h.db)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil || !subject.Supervises(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found or not assigned to you"})
+		return nil, false
+	}
+
+	var student models.UserProfile
+	if err := db.Where("id = ?", id).First(&student).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return nil, false
+	}
+	return &student, true
+}
+
 func (h *GuruWaliHandler) GetStudents(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	teacherID, _ := middleware.GetUserID(c)
 
 	var assignments []models.GuruWaliAssignment
-	if err := h.db.Where("teacher_id = ?", teacherID).
+	if err := db.Where("teacher_id = ?", teacherID).
 		Preload("Student").
 		Find(&assignments).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
@@ -45,19 +72,8 @@ func (h *GuruWaliHandler) GetStudents(c *gin.Context) {
 }
 
 func (h *GuruWaliHandler) GetStudent(c *gin.Context) {
-	id := c.Param("id")
-	teacherID, _ := middleware.GetUserID(c)
-
-	var assignment models.GuruWaliAssignment
-	if err := h.db.Where("teacher_id = ? AND student_id = ?", teacherID, id).
-		First(&assignment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found or not assigned to you"})
-		return
-	}
-
-	var student models.UserProfile
-	if err := h.db.Where("id = ?", id).First(&student).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+	student, ok := h.student(c)
+	if !ok {
 		return
 	}
 
@@ -65,25 +81,17 @@ func (h *GuruWaliHandler) GetStudent(c *gin.Context) {
 }
 
 func (h *GuruWaliHandler) GetStudentDetails(c *gin.Context) {
-	id := c.Param("id")
-	teacherID, _ := middleware.GetUserID(c)
+	db := middleware.DB(c, h.db)
 
-	var assignment models.GuruWaliAssignment
-	if err := h.db.Where("teacher_id = ? AND student_id = ?", teacherID, id).
-		First(&assignment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found or not assigned to you"})
+	student, ok := h.student(c)
+	if !ok {
 		return
 	}
 
-	var student models.UserProfile
-	if err := h.db.Where("id = ?", id).First(&student).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
-		return
-	}
-
-	query := h.db.Model(&models.Activity{}).
+	query := db.Model(&models.Activity{}).
 		Where("user_profile_id = ?", student.ID).
 		Preload("Kegiatan").
+		Preload("Form").
 		Preload("Comments.UserProfile")
 
 	if startDate := c.Query("start_date"); startDate != "" {
@@ -104,6 +112,8 @@ func (h *GuruWaliHandler) GetStudentDetails(c *gin.Context) {
 }
 
 func (h *GuruWaliHandler) GetDailyInactiveReport(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	dateStr := c.Query("date")
 	if dateStr == "" {
 		dateStr = time.Now().Format("2006-01-02")
@@ -112,7 +122,7 @@ func (h *GuruWaliHandler) GetDailyInactiveReport(c *gin.Context) {
 	teacherID, _ := middleware.GetUserID(c)
 
 	var assignments []models.GuruWaliAssignment
-	if err := h.db.Where("teacher_id = ?", teacherID).
+	if err := db.Where("teacher_id = ?", teacherID).
 		Preload("Student").
 		Find(&assignments).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
@@ -134,7 +144,7 @@ func (h *GuruWaliHandler) GetDailyInactiveReport(c *gin.Context) {
 	}
 
 	var activeUserIDs []string
-	h.db.Model(&models.Activity{}).
+	db.Model(&models.Activity{}).
 		Where("DATE(date) = ? AND user_profile_id IN ?", dateStr, studentIDs).
 		Distinct("user_profile_id").
 		Pluck("user_profile_id", &activeUserIDs)
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ImpersonationHandler struct {
+	db             *gorm.DB
+	jwtService     *auth.JWTService
+	impersonations *auth.ImpersonationStore
+	permissions    *auth.PermissionStore
+}
+
+func NewImpersonationHandler(db *gorm.DB, jwtService *auth.JWTService, impersonations *auth.ImpersonationStore, permissions *auth.PermissionStore) *ImpersonationHandler {
+	return &ImpersonationHandler{db: db, jwtService: jwtService, impersonations: impersonations, permissions: permissions}
+}
+
+// ImpersonateRequest states why support needs to see the app as the user;
+// the reason is kept in the audit trail
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ImpersonateResponse struct {
+	ImpersonationID string              `json:"impersonation_id"`
+	Token           string              `json:"token"`
+	ExpiresAt       int64               `json:"expires_at"`
+	Profile         *models.UserProfile `json:"profile"`
+	ActiveRole      string              `json:"active_role"`
+	Roles           []string            `json:"roles"`
+}
+
+// Impersonate godoc
+// @Summary Impersonate a user
+// @Description Issue a short-lived, read-only access token for the user with the admin as actor (act claim). Write requests made with it are refused. The session is recorded in the impersonation log.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body ImpersonateRequest true "Reason"
+// @Success 201 {object} ImpersonateResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/impersonate [post]
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required"})
+		return
+	}
+
+	actorID, _ := middleware.GetUserID(c)
+	actorSessionID, err := middleware.GetSessionID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if targetID == actorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot impersonate yourself"})
+		return
+	}
+
+	var target models.UserProfile
+	if err := h.db.First(&target, "id = ?", targetID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &target) {
+		return
+	}
+
+	roles, err := h.permissions.HeldRoles(&target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	session, err := h.impersonations.Start(actorID, actorSessionID, target.ID, reason, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start impersonation"})
+		return
+	}
+
+	token, expiresAt, err := h.jwtService.GenerateImpersonationToken(target.ID, target.Role, roles, session.ID, actorID, h.impersonations.TTL())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	log.Printf("Impersonation %s: %s is viewing as %s (%s)", session.ID, actorID, target.ID, reason)
+	audit.Record(c, audit.Entry{
+		Action:     "impersonation.start",
+		TargetType: "user",
+		TargetID:   target.ID.String(),
+		Details:    gin.H{"impersonation_id": session.ID, "reason": reason, "expires_at": session.ExpiresAt},
+	})
+
+	c.JSON(http.StatusCreated, ImpersonateResponse{
+		ImpersonationID: session.ID.String(),
+		Token:           token,
+		ExpiresAt:       expiresAt,
+		Profile:         &target,
+		ActiveRole:      target.Role,
+		Roles:           roles,
+	})
+}
+
+// GetImpersonations godoc
+// @Summary Get impersonation log
+// @Description List impersonation sessions, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param actor_id query string false "Filter by admin"
+// @Param target_id query string false "Filter by impersonated user"
+// @Param limit query int false "Page size (default 100)"
+// @Param offset query int false "Page offset"
+// @Success 200 {array} models.ImpersonationSession
+// @Router /admin/impersonations [get]
+func (h *ImpersonationHandler) GetImpersonations(c *gin.Context) {
+	query := h.db.Model(&models.ImpersonationSession{}).Preload("Actor").Preload("Target")
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+
+	limit := 100
+	offset := 0
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil {
+			limit = parsedLimit
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil {
+			offset = parsedOffset
+		}
+	}
+
+	var sessions []models.ImpersonationSession
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch impersonations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// EndImpersonation godoc
+// @Summary End an impersonation
+// @Description Stop an impersonation before it expires; its token stops working immediately
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Impersonation ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/impersonations/{id} [delete]
+func (h *ImpersonationHandler) EndImpersonation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid impersonation ID"})
+		return
+	}
+
+	if err := h.impersonations.End(id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Impersonation not found or already ended"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end impersonation"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "impersonation.end", TargetType: "impersonation", TargetID: id.String()})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	inviteCodeLength   = 8
+	inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I
+)
+
+var (
+	errInvalidInvite = errors.New("invite code is invalid, expired or used up")
+	errClassRequired = errors.New("class is required")
+)
+
+type InviteHandler struct {
+	db          *gorm.DB
+	permissions *auth.PermissionStore
+}
+
+func NewInviteHandler(db *gorm.DB, permissions *auth.PermissionStore) *InviteHandler {
+	return &InviteHandler{db: db, permissions: permissions}
+}
+
+type CreateInviteRequest struct {
+	Role           string  `json:"role" binding:"required"`
+	Class          *string `json:"class"`
+	MaxUses        int     `json:"max_uses" binding:"omitempty,min=1"`
+	ExpiresInHours int     `json:"expires_in_hours" binding:"omitempty,min=1"`
+	Note           *string `json:"note"`
+}
+
+type UpdateRegistrationSettingsRequest struct {
+	OpenRoles []string `json:"open_roles" binding:"dive,oneof=siswa orangtua guru guruwali"`
+}
+
+type RegistrationSettingsResponse struct {
+	OpenRoles []string  `json:"open_roles"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type InviteInfoResponse struct {
+	Role  string  `json:"role"`
+	Class *string `json:"class,omitempty"`
+}
+
+// normalizeInviteCode uppercases the code and drops separators so "k7qx-m2pa"
+// and "K7QXM2PA" are the same code
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// randomInviteCode returns a random code from an alphabet without look-alike characters
+func randomInviteCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := 0; i < inviteCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(inviteCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// activeInvites restricts a query to codes that can still be redeemed
+func activeInvites(db *gorm.DB) *gorm.DB {
+	return db.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND used_count < max_uses", time.Now())
+}
+
+// redeemInvite uses up one redemption of the code. The check and the
+// increment are a single UPDATE, so concurrent signups cannot exceed MaxUses.
+func redeemInvite(tx *gorm.DB, code string) (*models.InviteCode, error) {
+	var invite models.InviteCode
+	result := activeInvites(tx.Model(&invite).Clauses(clause.Returning{})).
+		Where("code = ?", normalizeInviteCode(code)).
+		Updates(map[string]interface{}{
+			"used_count": gorm.Expr("used_count + 1"),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidInvite
+	}
+	return &invite, nil
+}
+
+// loadRegistrationSettings returns the settings row, or invite-only defaults
+func loadRegistrationSettings(db *gorm.DB) (*models.RegistrationSettings, error) {
+	var settings models.RegistrationSettings
+	err := db.First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.RegistrationSettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// openRoles splits the stored role list. Admin can never self-register.
+func openRoles(settings *models.RegistrationSettings) []string {
+	roles := []string{}
+	for _, role := range strings.Split(settings.OpenRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" && role != "admin" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// GetInviteInfo shows the role and class a code signs up with, so the signup
+// form can prefill them
+func (h *InviteHandler) GetInviteInfo(c *gin.Context) {
+	var invite models.InviteCode
+	if err := activeInvites(h.db).Where("code = ?", normalizeInviteCode(c.Param("code"))).First(&invite).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": errInvalidInvite.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, InviteInfoResponse{Role: invite.Role, Class: invite.Class})
+}
+
+// GetInvites godoc
+// @Summary Get invite codes
+// @Description List invite codes, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param role query string false "Filter by role"
+// @Param active query bool false "Only codes that can still be redeemed"
+// @Success 200 {array} models.InviteCode
+// @Router /admin/invites [get]
+func (h *InviteHandler) GetInvites(c *gin.Context) {
+	query := h.db.Model(&models.InviteCode{})
+
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	if c.Query("active") == "true" {
+		query = activeInvites(query)
+	}
+
+	var invites []models.InviteCode
+	if err := query.Order("created_at DESC").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// CreateInvite godoc
+// @Summary Create invite code
+// @Description Create an invite code bound to a role and optionally a class. Codes are single-use unless max_uses is set.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param invite body CreateInviteRequest true "Invite data"
+// @Success 201 {object} models.InviteCode
+// @Router /admin/invites [post]
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkManageableRoles(c, h.db, h.permissions, req.Role) {
+		return
+	}
+
+	invite := models.InviteCode{
+		ID:        uuid.New(),
+		Role:      req.Role,
+		Class:     req.Class,
+		MaxUses:   req.MaxUses,
+		Note:      req.Note,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if invite.MaxUses == 0 {
+		invite.MaxUses = 1
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		invite.ExpiresAt = &expiresAt
+	}
+	if adminID, err := middleware.GetUserID(c); err == nil {
+		invite.CreatedBy = &adminID
+	}
+
+	for attempt := 1; ; attempt++ {
+		code, err := randomInviteCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate code"})
+			return
+		}
+		invite.Code = code
+
+		err = h.db.Create(&invite).Error
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) || attempt >= maxCredentialAttempts {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// RevokeInvite godoc
+// @Summary Revoke invite code
+// @Description Stop an invite code from being redeemed. Accounts already created with it are kept.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Invite ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/invites/{id} [delete]
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	result := h.db.Model(&models.InviteCode{}).
+		Where("id = ? AND revoked_at IS NULL", c.Param("id")).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetRegistrationSettings godoc
+// @Summary Get registration settings
+// @Description Get the roles that may sign up without an invite code
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} RegistrationSettingsResponse
+// @Router /admin/registration-settings [get]
+func (h *InviteHandler) GetRegistrationSettings(c *gin.Context) {
+	settings, err := loadRegistrationSettings(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RegistrationSettingsResponse{OpenRoles: openRoles(settings), UpdatedAt: settings.UpdatedAt})
+}
+
+// UpdateRegistrationSettings godoc
+// @Summary Update registration settings
+// @Description Set the roles that may sign up without an invite code. An empty list makes signup invite-only. Admin can never self-register.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param settings body UpdateRegistrationSettingsRequest true "Open roles"
+// @Success 200 {object} RegistrationSettingsResponse
+// @Router /admin/registration-settings [put]
+func (h *InviteHandler) UpdateRegistrationSettings(c *gin.Context) {
+	var req UpdateRegistrationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := loadRegistrationSettings(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings"})
+		return
+	}
+
+	settings.OpenRoles = strings.Join(req.OpenRoles, ",")
+	settings.UpdatedAt = time.Now()
+	if settings.ID == uuid.Nil {
+		settings.ID = uuid.New()
+		settings.CreatedAt = settings.UpdatedAt
+	}
+
+	if err := h.db.Save(settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RegistrationSettingsResponse{OpenRoles: openRoles(settings), UpdatedAt: settings.UpdatedAt})
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/FirstTirr/G7KAIH-GO/internal/forms"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -92,6 +96,9 @@ func (h *KegiatanHandler) CreateKegiatan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkFormSchema(c, req.FormSchema) {
+		return
+	}
 
 	// Verify category exists
 	var category models.Category
@@ -113,7 +120,14 @@ func (h *KegiatanHandler) CreateKegiatan(c *gin.Context) {
 		IsActive:    isActive,
 	}
 
-	if err := h.db.Create(&kegiatan).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&kegiatan).Error; err != nil {
+			return err
+		}
+		_, err := forms.Publish(tx, &kegiatan, formAuthor(c))
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create kegiatan"})
 		return
 	}
@@ -149,6 +163,9 @@ func (h *KegiatanHandler) UpdateKegiatan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkFormSchema(c, req.FormSchema) {
+		return
+	}
 
 	kegiatan.Name = req.Name
 	kegiatan.Description = req.Description
@@ -158,7 +175,15 @@ func (h *KegiatanHandler) UpdateKegiatan(c *gin.Context) {
 		kegiatan.IsActive = *req.IsActive
 	}
 
-	if err := h.db.Save(&kegiatan).Error; err != nil {
+	// Activities keep the form version they were submitted against
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&kegiatan).Error; err != nil {
+			return err
+		}
+		_, err := forms.Publish(tx, &kegiatan, formAuthor(c))
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update kegiatan"})
 		return
 	}
@@ -193,3 +218,161 @@ func (h *KegiatanHandler) DeleteKegiatan(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// checkFormSchema validates a form_schema before it is saved and answers 400
+// with the problems of each field when it is invalid
+func checkFormSchema(c *gin.Context, raw *string) bool {
+	schema, err := forms.Parse(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "form_schema must be a JSON object with a fields array"})
+		return false
+	}
+	if errs := schema.Check(); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form_schema", "fields": errs})
+		return false
+	}
+	return true
+}
+
+// FormDiff compares two form versions of a kegiatan
+type FormDiff struct {
+	KegiatanID uuid.UUID      `json:"kegiatan_id"`
+	From       int            `json:"from"`
+	To         int            `json:"to"`
+	Breaking   bool           `json:"breaking"`
+	Changes    []forms.Change `json:"changes"`
+}
+
+// GetFormVersions godoc
+// @Summary List form versions
+// @Description Every form_schema the kegiatan has had, oldest first
+// @Tags kegiatan
+// @Produce json
+// @Param id path string true "Kegiatan ID"
+// @Success 200 {array} models.KegiatanFormVersion
+// @Failure 404 {object} map[string]string
+// @Router /kegiatan/{id}/versions [get]
+func (h *KegiatanHandler) GetFormVersions(c *gin.Context) {
+	var kegiatan models.Kegiatan
+	if err := h.db.Where("id = ?", c.Param("id")).First(&kegiatan).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kegiatan not found"})
+		return
+	}
+
+	var versions []models.KegiatanFormVersion
+	if err := h.db.Where("kegiatan_id = ?", kegiatan.ID).Order("version ASC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch form versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetFormVersion godoc
+// @Summary Get a form version
+// @Tags kegiatan
+// @Produce json
+// @Param id path string true "Kegiatan ID"
+// @Param version path int true "Version"
+// @Success 200 {object} models.KegiatanFormVersion
+// @Failure 404 {object} map[string]string
+// @Router /kegiatan/{id}/versions/{version} [get]
+func (h *KegiatanHandler) GetFormVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Form version not found"})
+		return
+	}
+
+	var formVersion models.KegiatanFormVersion
+	if err := h.db.Where("kegiatan_id = ? AND version = ?", c.Param("id"), version).First(&formVersion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Form version not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, formVersion)
+}
+
+// DiffFormVersions godoc
+// @Summary Compare form versions
+// @Description Fields added, removed or changed between two versions. to defaults to the current version and from to the one before it; breaking changes may refuse data the older version accepted.
+// @Tags kegiatan
+// @Produce json
+// @Param id path string true "Kegiatan ID"
+// @Param from query int false "Older version"
+// @Param to query int false "Newer version"
+// @Success 200 {object} FormDiff
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /kegiatan/{id}/versions/diff [get]
+func (h *KegiatanHandler) DiffFormVersions(c *gin.Context) {
+	var kegiatan models.Kegiatan
+	if err := h.db.Where("id = ?", c.Param("id")).First(&kegiatan).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kegiatan not found"})
+		return
+	}
+
+	to := kegiatan.FormVersion
+	if value := c.Query("to"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a version number"})
+			return
+		}
+		to = n
+	}
+	from := to - 1
+	if from < 0 {
+		from = 0
+	}
+	if value := c.Query("from"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a version number"})
+			return
+		}
+		from = n
+	}
+	if from < 0 || to < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Versions must not be negative"})
+		return
+	}
+
+	// Version 0 is the empty form before the first version
+	schemas := make(map[int]*forms.Schema, 2)
+	for _, version := range []int{from, to} {
+		if version == 0 {
+			schemas[version] = &forms.Schema{Fields: []forms.Field{}}
+			continue
+		}
+		var formVersion models.KegiatanFormVersion
+		if err := h.db.Where("kegiatan_id = ? AND version = ?", kegiatan.ID, version).First(&formVersion).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Form version %d not found", version)})
+			return
+		}
+		schema, err := forms.Parse(formVersion.FormSchema)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid form schema"})
+			return
+		}
+		schemas[version] = schema
+	}
+
+	changes := forms.Diff(schemas[from], schemas[to])
+	c.JSON(http.StatusOK, FormDiff{
+		KegiatanID: kegiatan.ID,
+		From:       from,
+		To:         to,
+		Breaking:   forms.Breaking(changes),
+		Changes:    changes,
+	})
+}
+
+// formAuthor is the user saving a form version, if known
+func formAuthor(c *gin.Context) *uuid.UUID {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		return nil
+	}
+	return &userID
+}
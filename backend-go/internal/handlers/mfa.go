@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/printables"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const mfaQRImageSize = 256
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFASetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	QRCode     string `json:"qr_code"` // data:image/png;base64,...
+}
+
+type MFAEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	LoginResponse
+}
+
+// currentProfile loads the profile of the authenticated user, answering the
+// request itself when that fails
+func (h *AuthHandler) currentProfile(c *gin.Context) (*models.UserProfile, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", userID).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return nil, false
+	}
+	return &profile, true
+}
+
+// mfaError maps MFA service errors to responses
+func mfaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrMFANotEnrolled), errors.Is(err, auth.ErrMFAAlreadyEnabled), errors.Is(err, auth.ErrMFAUnsupportedRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+	}
+}
+
+// SetupMFA starts TOTP enrollment and returns the secret with a QR code for
+// the authenticator app. 2FA is not active until EnableMFA confirms a code.
+func (h *AuthHandler) SetupMFA(c *gin.Context) {
+	profile, ok := h.currentProfile(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := h.mfa.BeginEnrollment(profile)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	png, err := printables.QRPNG(uri, mfaQRImageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render QR code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURL: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// EnableMFA confirms enrollment with a code from the app. Other sessions are
+// signed out, and the response carries the recovery codes (shown only once)
+// and a token pair for this device that counts as two-factor authenticated.
+func (h *AuthHandler) EnableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, ok := h.currentProfile(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfa.ConfirmEnrollment(profile, req.Code)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	if err := h.sessions.RevokeAll(profile.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	pair, err := h.issueTokenPair(c, profile, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnableResponse{
+		RecoveryCodes: codes,
+		LoginResponse: LoginResponse{
+			Token:        pair.AccessToken,
+			RefreshToken: pair.RefreshToken,
+			ExpiresAt:    pair.ExpiresAt,
+			Profile:      profile,
+		},
+	})
+}
+
+// DisableMFA turns 2FA off after checking a TOTP or recovery code, and signs
+// the user out everywhere
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, ok := h.currentProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mfa.Verify(profile, req.Code); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	if err := h.mfa.Disable(profile.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+	if err := h.sessions.RevokeAll(profile.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a code
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, ok := h.currentProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mfa.Verify(profile, req.Code); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(profile)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserMFA godoc
+// @Summary Reset two-factor authentication
+// @Description Remove the TOTP secret and recovery codes of a user who lost their authenticator, and sign them out everywhere. They can enroll again after logging in.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/2fa [delete]
+func (h *SecurityHandler) ResetUserMFA(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", id).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile) {
+		return
+	}
+
+	if err := h.mfa.Disable(profile.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	if err := h.sessions.RevokeAll(profile.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
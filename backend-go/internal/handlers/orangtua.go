@@ -7,6 +7,7 @@ import (
 	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -19,10 +20,12 @@ func NewOrangTuaHandler(db *gorm.DB) *OrangTuaHandler {
 }
 
 func (h *OrangTuaHandler) GetChildren(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	parentID, _ := middleware.GetUserID(c)
 
 	var relationships []models.ParentStudent
-	if err := h.db.Where("parent_id = ?", parentID).
+	if err := db.Where("parent_id = ?", parentID).
 		Preload("Student").
 		Find(&relationships).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch children"})
@@ -40,19 +43,23 @@ func (h *OrangTuaHandler) GetChildren(c *gin.Context) {
 }
 
 func (h *OrangTuaHandler) GetChildActivities(c *gin.Context) {
-	studentID := c.Param("id")
-	parentID, _ := middleware.GetUserID(c)
+	db := middleware.DB(c, h.db)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
+	}
 
-	var relationship models.ParentStudent
-	if err := h.db.Where("parent_id = ? AND student_id = ?", parentID, studentID).
-		First(&relationship).Error; err != nil {
+	studentID, err := uuid.Parse(c.Param("id"))
+	if err != nil || !subject.ParentOf(studentID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this student's activities"})
 		return
 	}
 
-	query := h.db.Model(&models.Activity{}).
+	query := db.Model(&models.Activity{}).
 		Where("user_profile_id = ?", studentID).
 		Preload("Kegiatan").
+		Preload("Form").
 		Preload("Comments.UserProfile")
 
 	if startDate := c.Query("start_date"); startDate != "" {
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	temporaryPasswordLength   = 12
+	temporaryPasswordAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type SetUserPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+type SetUserPasswordResponse struct {
+	UserID            string `json:"user_id"`
+	Username          string `json:"username"`
+	TemporaryPassword string `json:"temporary_password"`
+	Message           string `json:"message"`
+}
+
+// randomTemporaryPassword returns a password that satisfies the policy
+func randomTemporaryPassword() (string, error) {
+	for {
+		b := make([]byte, temporaryPasswordLength)
+		max := big.NewInt(int64(len(temporaryPasswordAlphabet)))
+		for i := range b {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			b[i] = temporaryPasswordAlphabet[n.Int64()]
+		}
+		if auth.ValidatePasswordPolicy(string(b)) == nil {
+			return string(b), nil
+		}
+	}
+}
+
+// setPassword stores a new bcrypt password on the profile. Accounts from
+// before usernames (the seeded admin, legacy staff) get one here as well: the
+// token login refuses accounts with a password, so the username is the only
+// way left to sign in. A username taken in between is retried like in
+// issueCredentials.
+func setPassword(db *gorm.DB, profile *models.UserProfile, password string, mustChange bool) error {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"password_hash":        hash,
+		"password_must_change": mustChange,
+		"password_changed_at":  now,
+		"updated_at":           now,
+	}
+
+	generateUsername := profile.Username == nil
+	var username string
+	for attempt := 1; ; attempt++ {
+		if generateUsername {
+			username, err = nextFreeUsername(db, usernameBase(profile))
+			if err != nil {
+				return err
+			}
+			updates["username"] = username
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return tx.Model(profile).Updates(updates).Error
+		})
+		if err == nil {
+			break
+		}
+		if !generateUsername || !errors.Is(err, gorm.ErrDuplicatedKey) || attempt >= maxCredentialAttempts {
+			return err
+		}
+	}
+
+	if generateUsername {
+		profile.Username = &username
+	}
+	profile.PasswordHash = &hash
+	profile.PasswordMustChange = mustChange
+	profile.PasswordChangedAt = &now
+	profile.UpdatedAt = now
+	return nil
+}
+
+// ChangePassword sets or changes the password of a staff account. It is also
+// the way out of a forced password change. Every session is signed out and a
+// fresh token pair is returned for this device, along with the profile and
+// its username, which may have just been assigned.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", userID).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile not found"})
+		return
+	}
+
+	if !auth.RoleRequiresPassword(profile.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "passwords are only available for staff accounts"})
+		return
+	}
+
+	if profile.PasswordHash != nil {
+		if req.CurrentPassword == "" || auth.VerifyPassword(*profile.PasswordHash, req.CurrentPassword) != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+			return
+		}
+		if req.CurrentPassword == req.NewPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "new password must be different"})
+			return
+		}
+	}
+
+	if err := auth.ValidatePasswordPolicy(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, &profile, req.NewPassword, false); err != nil {
+			return err
+		}
+		return h.sessions.WithTx(tx).RevokeAll(profile.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+
+	pair, err := h.issueTokenPair(c, &profile, middleware.HasMFA(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt,
+		Profile:      &profile,
+	})
+}
+
+// SetUserPassword godoc
+// @Summary Set a temporary staff password
+// @Description Set a temporary password for a staff account (any role except siswa and orangtua). A random one is generated when none is given. Accounts without a username get one, returned with the password. The user must change it at next login and is signed out everywhere.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param password body SetUserPasswordRequest false "Temporary password"
+// @Success 200 {object} SetUserPasswordResponse
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/password [post]
+func (h *AdminHandler) SetUserPassword(c *gin.Context) {
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", c.Param("id")).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile) {
+		return
+	}
+
+	if !auth.RoleRequiresPassword(profile.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passwords are only available for staff accounts"})
+		return
+	}
+
+	var req SetUserPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	password := req.Password
+	if password == "" {
+		generated, err := randomTemporaryPassword()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate password"})
+			return
+		}
+		password = generated
+	} else if err := auth.ValidatePasswordPolicy(password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, &profile, password, true); err != nil {
+			return err
+		}
+		return h.sessions.WithTx(tx).RevokeAll(profile.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SetUserPasswordResponse{
+		UserID:            profile.ID.String(),
+		Username:          *profile.Username,
+		TemporaryPassword: password,
+		Message:           "The user must change this password at next login.",
+	})
+}
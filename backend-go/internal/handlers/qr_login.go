@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/printables"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const qrImageSize = 512
+
+type QRLoginHandler struct {
+	db          *gorm.DB
+	qrTokens    *auth.QRLoginStore
+	permissions *auth.PermissionStore
+}
+
+func NewQRLoginHandler(db *gorm.DB, qrTokens *auth.QRLoginStore, permissions *auth.PermissionStore) *QRLoginHandler {
+	return &QRLoginHandler{db: db, qrTokens: qrTokens, permissions: permissions}
+}
+
+type QRCardsRequest struct {
+	Class string `json:"class" binding:"required"`
+	Role  string `json:"role"`
+}
+
+// qrLoginAllowed reports whether a QR card may sign the user in. A card is a
+// bearer credential, so accounts that have or need a password never get one
+func qrLoginAllowed(profile *models.UserProfile) bool {
+	return profile.PasswordHash == nil && !auth.RoleRequiresPassword(profile.Role)
+}
+
+// CreateUserQRLogin godoc
+// @Summary Create a QR login code for a user
+// @Description Issue a one-time QR login code for a user, as PNG (default), SVG or JSON payload. Earlier unused codes of the user stop working. Staff and other users who sign in with a password get 409.
+// @Tags admin
+// @Produce image/png
+// @Produce image/svg+xml
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param format query string false "png (default), svg or json"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/users/{id}/qr-login [post]
+func (h *QRLoginHandler) CreateUserQRLogin(c *gin.Context) {
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", c.Param("id")).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile) {
+		return
+	}
+	if !qrLoginAllowed(&profile) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User signs in with a password and cannot use a QR code"})
+		return
+	}
+
+	var issuedBy *uuid.UUID
+	if adminID, err := middleware.GetUserID(c); err == nil {
+		issuedBy = &adminID
+	}
+
+	payload, err := h.qrTokens.Issue(profile.ID, issuedBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create QR code"})
+		return
+	}
+
+	switch c.Query("format") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"user_id": profile.ID, "payload": payload})
+	case "svg":
+		svg, err := printables.QRSVG(payload)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+			return
+		}
+		c.Data(http.StatusOK, "image/svg+xml", svg)
+	default:
+		png, err := printables.QRPNG(payload, qrImageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", png)
+	}
+}
+
+// CreateClassQRCards godoc
+// @Summary Print QR login cards for a class
+// @Description Issue new one-time QR login codes for every student (or the given role) in a class and return them as a printable PDF card sheet. Earlier unused cards stop working. Users who sign in with a password are left out.
+// @Tags admin
+// @Accept json
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param filter body QRCardsRequest true "Class and optional role (default siswa)"
+// @Success 200 {file} file
+// @Router /admin/users/qr-cards [post]
+func (h *QRLoginHandler) CreateClassQRCards(c *gin.Context) {
+	var req QRCardsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Class = strings.TrimSpace(req.Class)
+	if req.Role == "" {
+		req.Role = "siswa"
+	}
+	if !checkManageableRoles(c, h.db, h.permissions, req.Role) {
+		return
+	}
+	roles, err := manageableRoleNames(c, h.db, h.permissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	var matched []models.UserProfile
+	if err := scopeManageableUsers(h.db, roles).
+		Where("class = ? AND role = ?", req.Class, req.Role).
+		Order("name ASC").
+		Find(&matched).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+	if len(matched) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No users match the filter"})
+		return
+	}
+	profiles := make([]models.UserProfile, 0, len(matched))
+	for i := range matched {
+		if qrLoginAllowed(&matched[i]) {
+			profiles = append(profiles, matched[i])
+		}
+	}
+	if len(profiles) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Every matching user signs in with a password"})
+		return
+	}
+
+	var issuedBy *uuid.UUID
+	if adminID, err := middleware.GetUserID(c); err == nil {
+		issuedBy = &adminID
+	}
+
+	cards := make([]printables.QRCard, 0, len(profiles))
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		qrTokens := h.qrTokens.WithTx(tx)
+		for _, profile := range profiles {
+			payload, err := qrTokens.Issue(profile.ID, issuedBy)
+			if err != nil {
+				return err
+			}
+
+			card := printables.QRCard{Name: profile.Name, Class: profile.Class, Payload: payload}
+			if profile.Username != nil {
+				card.Username = *profile.Username
+			}
+			cards = append(cards, card)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create QR codes"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := printables.WriteQRCards(&buf, "Kartu Login G7KAIH - Kelas "+req.Class, cards); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
+		return
+	}
+
+	filename := fmt.Sprintf("qr-cards-%s-%s.pdf", req.Class, time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
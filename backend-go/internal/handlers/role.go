@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+type RoleHandler struct {
+	db          *gorm.DB
+	permissions *auth.PermissionStore
+}
+
+func NewRoleHandler(db *gorm.DB, permissions *auth.PermissionStore) *RoleHandler {
+	return &RoleHandler{db: db, permissions: permissions}
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	DisplayName string   `json:"display_name" binding:"required"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleRequest struct {
+	DisplayName *string  `json:"display_name"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// manageableRoles maps every role to whether the current user holds all of
+// its permissions. Users who manage accounts may only create, edit or hand
+// credentials to accounts with such roles, so they cannot give themselves or
+// anyone else more access than they have.
+func manageableRoles(c *gin.Context, db *gorm.DB, permissions *auth.PermissionStore) (map[string]bool, error) {
+	var names []string
+	if err := db.Model(&models.Role{}).Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+
+	actor := middleware.GetPermissions(c)
+	result := make(map[string]bool, len(names))
+	for _, name := range names {
+		granted, err := permissions.ForRole(name)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = coversPermissions(actor, granted.Names())
+	}
+	return result, nil
+}
+
+// manageableRoleNames lists the roles from manageableRoles the user may manage
+func manageableRoleNames(c *gin.Context, db *gorm.DB, permissions *auth.PermissionStore) ([]string, error) {
+	roles, err := manageableRoles(c, db, permissions)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name, ok := range roles {
+		if ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// checkManageableRoles answers the request and returns false unless every
+// role exists and may be managed by the current user
+func checkManageableRoles(c *gin.Context, db *gorm.DB, permissions *auth.PermissionStore, roles ...string) bool {
+	manageable, err := manageableRoles(c, db, permissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return false
+	}
+
+	for _, role := range roles {
+		ok, exists := manageable[role]
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + role})
+			return false
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage users with role " + role})
+			return false
+		}
+	}
+	return true
+}
+
+// checkManageableUser is checkManageableRoles for every role the user holds
+func checkManageableUser(c *gin.Context, db *gorm.DB, permissions *auth.PermissionStore, profile *models.UserProfile, extra ...string) bool {
+	roles, err := permissions.HeldRoles(profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return false
+	}
+	return checkManageableRoles(c, db, permissions, append(roles, extra...)...)
+}
+
+// scopeManageableUsers limits a user_profiles query to users whose roles are
+// all in the given list
+func scopeManageableUsers(query *gorm.DB, roles []string) *gorm.DB {
+	return query.Where("role IN ?", roles).
+		Where("NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_profile_id = user_profiles.id AND user_roles.role_name NOT IN ?)", roles)
+}
+
+func coversPermissions(actor auth.PermissionSet, permissions []string) bool {
+	for _, permission := range permissions {
+		if !actor.Has(permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePermissions checks the names against the registry and that the
+// current user holds them all
+func validatePermissions(c *gin.Context, permissions []string) bool {
+	for _, permission := range permissions {
+		if _, ok := auth.LookupPermission(permission); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + permission})
+			return false
+		}
+	}
+	if !coversPermissions(middleware.GetPermissions(c), permissions) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only grant permissions you have"})
+		return false
+	}
+	return true
+}
+
+// replaceRolePermissions stores the permission list of a role
+func replaceRolePermissions(tx *gorm.DB, role string, permissions []string) error {
+	if err := tx.Where("role_name = ?", role).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(permissions))
+	rows := make([]models.RolePermission, 0, len(permissions))
+	for _, permission := range permissions {
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+		rows = append(rows, models.RolePermission{RoleName: role, Permission: permission})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+// loadRole fetches a role with its permissions
+func (h *RoleHandler) loadRole(name string) (*models.Role, error) {
+	var role models.Role
+	if err := h.db.Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+
+	permissions, err := h.permissions.ForRole(role.Name)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions = permissions.Names()
+	return &role, nil
+}
+
+// GetPermissions godoc
+// @Summary Get permission registry
+// @Description List every permission a role can be given. Administrative permissions need a two-factor session when ADMIN_REQUIRE_MFA is on.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} auth.Permission
+// @Router /admin/permissions [get]
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, auth.Permissions())
+}
+
+// GetRoles godoc
+// @Summary Get roles
+// @Description List roles with their permissions
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Role
+// @Router /admin/roles [get]
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := h.db.Order("built_in DESC, name ASC").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	for i := range roles {
+		permissions, err := h.permissions.ForRole(roles[i].Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+			return
+		}
+		roles[i].Permissions = permissions.Names()
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole godoc
+// @Summary Create role
+// @Description Create a custom role, e.g. a BK counselor, with permissions from the registry. Users with a custom role log in like staff (password and optional 2FA).
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role body CreateRoleRequest true "Role data"
+// @Success 201 {object} models.Role
+// @Failure 409 {object} map[string]string
+// @Router /admin/roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	if !roleNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name must be 2-50 lowercase letters, digits or underscores"})
+		return
+	}
+	if !validatePermissions(c, req.Permissions) {
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return replaceRolePermissions(tx, role.Name, req.Permissions)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+	h.permissions.Invalidate()
+
+	created, err := h.loadRole(role.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "role.create", TargetType: "role", TargetID: role.Name, After: created})
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateRole godoc
+// @Summary Update role
+// @Description Rename a role or replace its permissions. The admin role always has every permission and cannot be changed.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Param role body UpdateRoleRequest true "Role data"
+// @Success 200 {object} models.Role
+// @Failure 404 {object} map[string]string
+// @Router /admin/roles/{name} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var role models.Role
+	if err := h.db.Where("name = ?", c.Param("name")).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Permissions != nil {
+		if role.Name == auth.AdminRole {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The admin role always has every permission"})
+			return
+		}
+		if !checkManageableRoles(c, h.db, h.permissions, role.Name) || !validatePermissions(c, req.Permissions) {
+			return
+		}
+	}
+
+	before, err := h.loadRole(role.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+
+	if req.DisplayName != nil {
+		role.DisplayName = *req.DisplayName
+	}
+	if req.Description != nil {
+		role.Description = req.Description
+	}
+	role.UpdatedAt = time.Now()
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&role).Error; err != nil {
+			return err
+		}
+		if req.Permissions == nil {
+			return nil
+		}
+		return replaceRolePermissions(tx, role.Name, req.Permissions)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	h.permissions.Invalidate()
+
+	updated, err := h.loadRole(role.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "role.update", TargetType: "role", TargetID: role.Name, Before: before, After: updated})
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteRole godoc
+// @Summary Delete role
+// @Description Delete a custom role together with its unused invite codes. Built-in roles and roles that users still have cannot be deleted.
+// @Tags admin
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/roles/{name} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := h.db.Where("name = ?", c.Param("name")).First(&role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if role.BuiltIn {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be deleted"})
+		return
+	}
+	if !checkManageableRoles(c, h.db, h.permissions, role.Name) {
+		return
+	}
+
+	var users int64
+	if err := h.db.Unscoped().Model(&models.UserProfile{}).
+		Where("role = ? OR EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_profile_id = user_profiles.id AND user_roles.role_name = ?)", role.Name, role.Name).
+		Count(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	if users > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is still assigned to users", "user_count": users})
+		return
+	}
+
+	if err := h.db.Delete(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	h.permissions.Invalidate()
+	audit.Record(c, audit.Entry{Action: "role.delete", TargetType: "role", TargetID: role.Name, Before: role})
+
+	c.Status(http.StatusNoContent)
+}
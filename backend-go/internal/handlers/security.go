@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SecurityHandler struct {
+	db          *gorm.DB
+	loginGuard  *auth.LoginGuard
+	sessions    *auth.SessionStore
+	mfa         *auth.MFAService
+	permissions *auth.PermissionStore
+}
+
+func NewSecurityHandler(db *gorm.DB, loginGuard *auth.LoginGuard, sessions *auth.SessionStore, mfa *auth.MFAService, permissions *auth.PermissionStore) *SecurityHandler {
+	return &SecurityHandler{db: db, loginGuard: loginGuard, sessions: sessions, mfa: mfa, permissions: permissions}
+}
+
+// GetLoginLockouts godoc
+// @Summary Get active login lockouts
+// @Description List IP addresses and devices that are currently blocked from logging in
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.LoginLockout
+// @Router /admin/login-lockouts [get]
+func (h *SecurityHandler) GetLoginLockouts(c *gin.Context) {
+	lockouts, err := h.loginGuard.ActiveLockouts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, lockouts)
+}
+
+// ClearLoginLockout godoc
+// @Summary Clear a login lockout
+// @Description Unblock an IP address or device and reset its failure counter
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Lockout ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/login-lockouts/{id} [delete]
+func (h *SecurityHandler) ClearLoginLockout(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lockout ID"})
+		return
+	}
+
+	if err := h.loginGuard.ClearLockout(id); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Lockout not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear lockout"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetLoginAttempts godoc
+// @Summary Get login attempts
+// @Description Get the login attempt log, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param ip query string false "Filter by IP address"
+// @Param user_id query string false "Filter by user ID"
+// @Param success query bool false "Filter by outcome"
+// @Param limit query int false "Page size (default 100)"
+// @Param offset query int false "Page offset"
+// @Success 200 {array} models.LoginAttempt
+// @Router /admin/login-attempts [get]
+func (h *SecurityHandler) GetLoginAttempts(c *gin.Context) {
+	query := h.db.Model(&models.LoginAttempt{})
+
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_profile_id = ?", userID)
+	}
+
+	if success := c.Query("success"); success != "" {
+		query = query.Where("success = ?", success == "true")
+	}
+
+	limit := 100
+	offset := 0
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil {
+			limit = parsedLimit
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil {
+			offset = parsedOffset
+		}
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login attempts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
+
+// GetUserSessions godoc
+// @Summary Get user sessions
+// @Description List the devices a user is currently logged in on
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {array} models.UserSession
+// @Router /admin/users/{id}/sessions [get]
+func (h *SecurityHandler) GetUserSessions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	sessions, err := h.sessions.Active(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeUserSessions godoc
+// @Summary Revoke user sessions
+// @Description Sign a user out of every device. Their access and refresh tokens stop working immediately.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Router /admin/users/{id}/revoke-sessions [post]
+func (h *SecurityHandler) RevokeUserSessions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.sessions.RevokeAll(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
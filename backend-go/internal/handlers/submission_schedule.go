@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/submission"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubmissionScheduleHandler serves the submission schedule: its public
+// status, and the weekly rules and date exceptions admins edit
+type SubmissionScheduleHandler struct {
+	db          *gorm.DB
+	schedule    *submission.Scheduler
+	permissions *auth.PermissionStore
+}
+
+func NewSubmissionScheduleHandler(db *gorm.DB, schedule *submission.Scheduler, permissions *auth.PermissionStore) *SubmissionScheduleHandler {
+	return &SubmissionScheduleHandler{db: db, schedule: schedule, permissions: permissions}
+}
+
+type SubmissionRuleRequest struct {
+	Weekday    *int       `json:"weekday" binding:"required,min=0,max=6"`
+	Closed     bool       `json:"closed"`
+	OpenTime   *string    `json:"open_time"`
+	CloseTime  *string    `json:"close_time"`
+	Class      *string    `json:"class"`
+	KegiatanID *uuid.UUID `json:"kegiatan_id"`
+}
+
+type SubmissionExceptionRequest struct {
+	Name       string     `json:"name" binding:"required"`
+	StartDate  string     `json:"start_date" binding:"required"`
+	EndDate    *string    `json:"end_date"`
+	Closed     *bool      `json:"closed"`
+	OpenTime   *string    `json:"open_time"`
+	CloseTime  *string    `json:"close_time"`
+	Class      *string    `json:"class"`
+	KegiatanID *uuid.UUID `json:"kegiatan_id"`
+}
+
+// SubmissionSchedule is the whole schedule as admins edit it
+type SubmissionSchedule struct {
+	Timezone   string                       `json:"timezone"`
+	Rules      []models.SubmissionRule      `json:"rules"`
+	Exceptions []models.SubmissionException `json:"exceptions"`
+}
+
+// checkSubmissionWindow answers the request with 403 and the next opening
+// time when a student writes an activity of a kegiatan outside the
+// schedule. Users with submission.override are never refused.
+func checkSubmissionWindow(c *gin.Context, db *gorm.DB, schedule *submission.Scheduler, userID, kegiatanID uuid.UUID) bool {
+	if middleware.HasPermission(c, auth.PermSubmissionOverride) {
+		return true
+	}
+
+	var profile models.UserProfile
+	if err := db.Select("id", "class").Where("id = ?", userID).First(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read submission schedule"})
+		return false
+	}
+	status, err := schedule.Status(db, submission.Scope{Class: profile.Class, KegiatanID: &kegiatanID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read submission schedule"})
+		return false
+	}
+	if !status.Open {
+		c.JSON(http.StatusForbidden, gin.H{"error": status.Message(), "opens_at": status.OpensAt, "reason": status.Reason})
+		return false
+	}
+	return true
+}
+
+// optionalString trims a scope value; empty means no scope
+func optionalString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// checkScheduleScope checks the kegiatan a rule or exception is narrowed to
+func (h *SubmissionScheduleHandler) checkScheduleScope(c *gin.Context, kegiatanID *uuid.UUID) bool {
+	if kegiatanID == nil {
+		return true
+	}
+	var kegiatan models.Kegiatan
+	if err := h.db.Where("id = ?", *kegiatanID).First(&kegiatan).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kegiatan not found"})
+		return false
+	}
+	return true
+}
+
+// applyRule validates a request and copies it onto a rule
+func (h *SubmissionScheduleHandler) applyRule(c *gin.Context, rule *models.SubmissionRule) bool {
+	var req SubmissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := submission.ValidateHours(req.Closed, req.OpenTime, req.CloseTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if !h.checkScheduleScope(c, req.KegiatanID) {
+		return false
+	}
+
+	rule.Weekday = *req.Weekday
+	rule.Closed = req.Closed
+	rule.OpenTime = req.OpenTime
+	rule.CloseTime = req.CloseTime
+	rule.Class = optionalString(req.Class)
+	rule.KegiatanID = req.KegiatanID
+	return true
+}
+
+// applyException validates a request and copies it onto an exception
+func (h *SubmissionScheduleHandler) applyException(c *gin.Context, exception *models.SubmissionException) bool {
+	var req SubmissionExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return false
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format"})
+		return false
+	}
+	var end *time.Time
+	if req.EndDate != nil && *req.EndDate != "" {
+		date, err := time.Parse("2006-01-02", *req.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format"})
+			return false
+		}
+		if date.Before(start) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+			return false
+		}
+		end = &date
+	}
+	// An exception closes submissions unless it says otherwise
+	closed := req.Closed == nil || *req.Closed
+	if err := submission.ValidateHours(closed, req.OpenTime, req.CloseTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if !h.checkScheduleScope(c, req.KegiatanID) {
+		return false
+	}
+
+	exception.Name = name
+	exception.StartDate = start
+	exception.EndDate = end
+	exception.Closed = closed
+	exception.OpenTime = req.OpenTime
+	exception.CloseTime = req.CloseTime
+	exception.Class = optionalString(req.Class)
+	exception.KegiatanID = req.KegiatanID
+	return true
+}
+
+// GetStatus godoc
+// @Summary Get the submission status
+// @Description Whether submissions are open now, with the next opening or closing time in the school timezone, for countdowns. class and kegiatan_id apply the rules of that class and kegiatan.
+// @Tags activities
+// @Produce json
+// @Param class query string false "Class of the student"
+// @Param kegiatan_id query string false "Kegiatan ID"
+// @Success 200 {object} submission.Status
+// @Router /submission-window/status [get]
+func (h *SubmissionScheduleHandler) GetStatus(c *gin.Context) {
+	scope := submission.Scope{Class: c.Query("class")}
+	if raw := c.Query("kegiatan_id"); raw != "" {
+		kegiatanID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid kegiatan_id"})
+			return
+		}
+		scope.KegiatanID = &kegiatanID
+	}
+
+	status, err := h.schedule.Status(h.db, scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read submission schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetSchedule godoc
+// @Summary Get the submission schedule
+// @Description Weekly rules and date exceptions. Days without a rule are open all day.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SubmissionSchedule
+// @Router /admin/submission-schedule [get]
+func (h *SubmissionScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule := SubmissionSchedule{Timezone: h.schedule.Location().String()}
+	if err := h.db.Order("weekday ASC, created_at ASC").Find(&schedule.Rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+		return
+	}
+	if err := h.db.Order("start_date DESC, created_at ASC").Find(&schedule.Exceptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// ResolveSchedule godoc
+// @Summary Check whether a user may submit
+// @Description Resolves the schedule for a user and, optionally, a kegiatan at a time (default now). Users with submission.override are always allowed.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query string true "User ID"
+// @Param kegiatan_id query string false "Kegiatan ID"
+// @Param at query string false "Time (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/submission-schedule/resolve [get]
+func (h *SubmissionScheduleHandler) ResolveSchedule(c *gin.Context) {
+	var profile models.UserProfile
+	if _, err := uuid.Parse(c.Query("user_id")); err != nil || h.db.Where("id = ?", c.Query("user_id")).First(&profile).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	scope := submission.Scope{Class: profile.Class}
+	if raw := c.Query("kegiatan_id"); raw != "" {
+		kegiatanID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid kegiatan_id"})
+			return
+		}
+		scope.KegiatanID = &kegiatanID
+	}
+	at := time.Now()
+	if raw := c.Query("at"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at format"})
+			return
+		}
+		at = parsed
+	}
+
+	roles, err := h.permissions.HeldRoles(&profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	granted, err := h.permissions.ForRoles(roles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	status, err := h.schedule.StatusAt(h.db, scope, at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read submission schedule"})
+		return
+	}
+	override := granted.Has(auth.PermSubmissionOverride)
+
+	c.JSON(http.StatusOK, gin.H{
+		"allowed":  status.Open || override,
+		"override": override,
+		"class":    profile.Class,
+		"status":   status,
+	})
+}
+
+// CreateRule godoc
+// @Summary Add a weekly submission rule
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rule body SubmissionRuleRequest true "Rule"
+// @Success 201 {object} models.SubmissionRule
+// @Failure 400 {object} map[string]string
+// @Router /admin/submission-schedule/rules [post]
+func (h *SubmissionScheduleHandler) CreateRule(c *gin.Context) {
+	rule := models.SubmissionRule{CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if !h.applyRule(c, &rule) {
+		return
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rule"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "submission_rule.create", TargetType: "submission_rule", TargetID: rule.ID.String(), After: rule})
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule godoc
+// @Summary Replace a weekly submission rule
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param rule body SubmissionRuleRequest true "Rule"
+// @Success 200 {object} models.SubmissionRule
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/submission-schedule/rules/{id} [put]
+func (h *SubmissionScheduleHandler) UpdateRule(c *gin.Context) {
+	var rule models.SubmissionRule
+	if _, err := uuid.Parse(c.Param("id")); err != nil || h.db.Where("id = ?", c.Param("id")).First(&rule).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+	before := rule
+	if !h.applyRule(c, &rule) {
+		return
+	}
+	rule.UpdatedAt = time.Now()
+
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "submission_rule.update", TargetType: "submission_rule", TargetID: rule.ID.String(), Before: before, After: rule})
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule godoc
+// @Summary Delete a weekly submission rule
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/submission-schedule/rules/{id} [delete]
+func (h *SubmissionScheduleHandler) DeleteRule(c *gin.Context) {
+	var rule models.SubmissionRule
+	if _, err := uuid.Parse(c.Param("id")); err != nil || h.db.Where("id = ?", c.Param("id")).First(&rule).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	if err := h.db.Delete(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rule"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "submission_rule.delete", TargetType: "submission_rule", TargetID: rule.ID.String(), Before: rule})
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateException godoc
+// @Summary Add a submission exception
+// @Description Replaces the weekly rules from start_date to end_date (until removed when empty). Exceptions close submissions unless closed is false.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param exception body SubmissionExceptionRequest true "Exception"
+// @Success 201 {object} models.SubmissionException
+// @Failure 400 {object} map[string]string
+// @Router /admin/submission-schedule/exceptions [post]
+func (h *SubmissionScheduleHandler) CreateException(c *gin.Context) {
+	exception := models.SubmissionException{CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if !h.applyException(c, &exception) {
+		return
+	}
+
+	if err := h.db.Create(&exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create exception"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "submission_exception.create", TargetType: "submission_exception", TargetID: exception.ID.String(), After: exception})
+
+	c.JSON(http.StatusCreated, exception)
+}
+
+// UpdateException godoc
+// @Summary Replace a submission exception
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Exception ID"
+// @Param exception body SubmissionExceptionRequest true "Exception"
+// @Success 200 {object} models.SubmissionException
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/submission-schedule/exceptions/{id} [put]
+func (h *SubmissionScheduleHandler) UpdateException(c *gin.Context) {
+	var exception models.SubmissionException
+	if _, err := uuid.Parse(c.Param("id")); err != nil || h.db.Where("id = ?", c.Param("id")).First(&exception).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exception not found"})
+		return
+	}
+	before := exception
+	if !h.applyException(c, &exception) {
+		return
+	}
+	exception.UpdatedAt = time.Now()
+
+	if err := h.db.Save(&exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exception"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "submission_exception.update", TargetType: "submission_exception", TargetID: exception.ID.String(), Before: before, After: exception})
+
+	c.JSON(http.StatusOK, exception)
+}
+
+// DeleteException godoc
+// @Summary Delete a submission exception
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Exception ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /admin/submission-schedule/exceptions/{id} [delete]
+func (h *SubmissionScheduleHandler) DeleteException(c *gin.Context) {
+	var exception models.SubmissionException
+	if _, err := uuid.Parse(c.Param("id")); err != nil || h.db.Where("id = ?", c.Param("id")).First(&exception).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exception not found"})
+		return
+	}
+
+	if err := h.db.Delete(&exception).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exception"})
+		return
+	}
+	audit.Record(c, audit.Entry{Action: "submission_exception.delete", TargetType: "submission_exception", TargetID: exception.ID.String(), Before: exception})
+
+	c.Status(http.StatusNoContent)
+}
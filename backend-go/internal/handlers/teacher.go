@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
 	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/policy"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -18,25 +20,36 @@ func NewTeacherHandler(db *gorm.DB) *TeacherHandler {
 	return &TeacherHandler{db: db}
 }
 
-func (h *TeacherHandler) GetStudents(c *gin.Context) {
-	teacherID, _ := middleware.GetUserID(c)
-	userRole, _ := middleware.GetUserRole(c)
+// student loads the student in the path when the teacher may see them,
+// answering the request itself otherwise
+func (h *TeacherHandler) student(c *gin.Context) (*models.UserProfile, bool) {
+	db := middleware.DB(c, h.db)
 
-	query := h.db.Model(&models.UserProfile{}).Where("role = ?", "siswa")
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return nil, false
+	}
 
-	if userRole != "admin" {
-		var teacherRoles []models.TeacherRole
-		h.db.Where("teacher_id = ?", teacherID).Find(&teacherRoles)
+	var student models.UserProfile
+	if err := db.Where("id = ? AND role = ?", c.Param("id"), policy.StudentRole).First(&student).Error; err != nil || !subject.Can(policy.ActionView, &student) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return nil, false
+	}
+	return &student, true
+}
 
-		if len(teacherRoles) > 0 {
-			classes := make([]string, len(teacherRoles))
-			for i, tr := range teacherRoles {
-				classes[i] = tr.ClassName
-			}
-			query = query.Where("class IN ?", classes)
-		}
+// GetStudents lists the students of the classes the teacher teaches, or
+// every student with student.view.all
+func (h *TeacherHandler) GetStudents(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
 	}
 
+	query := subject.ScopeUsers(db.Model(&models.UserProfile{})).Where("role = ?", policy.StudentRole)
+
 	if class := c.Query("class"); class != "" {
 		query = query.Where("class = ?", class)
 	}
@@ -51,11 +64,8 @@ func (h *TeacherHandler) GetStudents(c *gin.Context) {
 }
 
 func (h *TeacherHandler) GetStudent(c *gin.Context) {
-	id := c.Param("id")
-
-	var student models.UserProfile
-	if err := h.db.Where("id = ? AND role = ?", id, "siswa").First(&student).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+	student, ok := h.student(c)
+	if !ok {
 		return
 	}
 
@@ -63,11 +73,17 @@ func (h *TeacherHandler) GetStudent(c *gin.Context) {
 }
 
 func (h *TeacherHandler) GetStudentActivities(c *gin.Context) {
-	studentID := c.Param("id")
+	db := middleware.DB(c, h.db)
 
-	query := h.db.Model(&models.Activity{}).
-		Where("user_profile_id = ?", studentID).
+	student, ok := h.student(c)
+	if !ok {
+		return
+	}
+
+	query := db.Model(&models.Activity{}).
+		Where("user_profile_id = ?", student.ID).
 		Preload("Kegiatan").
+		Preload("Form").
 		Preload("Comments.UserProfile")
 
 	if startDate := c.Query("start_date"); startDate != "" {
@@ -87,24 +103,27 @@ func (h *TeacherHandler) GetStudentActivities(c *gin.Context) {
 	c.JSON(http.StatusOK, activities)
 }
 
+// GetSupervisedStudents lists the students of the classes the teacher
+// teaches, even with student.view.all
 func (h *TeacherHandler) GetSupervisedStudents(c *gin.Context) {
-	teacherID, _ := middleware.GetUserID(c)
+	db := middleware.DB(c, h.db)
 
-	var teacherRoles []models.TeacherRole
-	h.db.Where("teacher_id = ?", teacherID).Find(&teacherRoles)
-
-	if len(teacherRoles) == 0 {
-		c.JSON(http.StatusOK, []models.UserProfile{})
+	subject, ok := loadSubject(c, db)
+	if !ok {
 		return
 	}
 
-	classes := make([]string, len(teacherRoles))
-	for i, tr := range teacherRoles {
-		classes[i] = tr.ClassName
+	classes := make([]string, 0, len(subject.Classes))
+	for class := range subject.Classes {
+		classes = append(classes, class)
+	}
+	if len(classes) == 0 {
+		c.JSON(http.StatusOK, []models.UserProfile{})
+		return
 	}
 
 	var students []models.UserProfile
-	if err := h.db.Where("role = ? AND class IN ?", "siswa", classes).
+	if err := db.Where("role = ? AND class IN ?", policy.StudentRole, classes).
 		Find(&students).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch students"})
 		return
@@ -114,34 +133,27 @@ func (h *TeacherHandler) GetSupervisedStudents(c *gin.Context) {
 }
 
 func (h *TeacherHandler) GetDailyInactiveReport(c *gin.Context) {
+	db := middleware.DB(c, h.db)
+
 	dateStr := c.Query("date")
 	if dateStr == "" {
 		dateStr = time.Now().Format("2006-01-02")
 	}
 
-	teacherID, _ := middleware.GetUserID(c)
-	userRole, _ := middleware.GetUserRole(c)
-
-	query := h.db.Model(&models.UserProfile{}).Where("role = ?", "siswa")
-
-	if userRole != "admin" {
-		var teacherRoles []models.TeacherRole
-		h.db.Where("teacher_id = ?", teacherID).Find(&teacherRoles)
-
-		if len(teacherRoles) > 0 {
-			classes := make([]string, len(teacherRoles))
-			for i, tr := range teacherRoles {
-				classes[i] = tr.ClassName
-			}
-			query = query.Where("class IN ?", classes)
-		}
+	subject, ok := loadSubject(c, db)
+	if !ok {
+		return
 	}
 
+	// graduated, transferred and suspended students are not expected to post
+	query := subject.ScopeUsers(db.Model(&models.UserProfile{})).
+		Where("role = ? AND status = ?", policy.StudentRole, auth.StatusActive)
+
 	var allStudents []models.UserProfile
 	query.Find(&allStudents)
 
 	var activeUserIDs []string
-	h.db.Model(&models.Activity{}).
+	db.Model(&models.Activity{}).
 		Where("DATE(date) = ?", dateStr).
 		Distinct("user_profile_id").
 		Pluck("user_profile_id", &activeUserIDs)
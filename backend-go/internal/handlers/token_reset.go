@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/FirstTirr/G7KAIH-GO/internal/printables"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errPasswordAccount is returned when a PIN reset targets an account that has
+// set a password; the PIN login refuses those accounts, so a new one is useless
+var errPasswordAccount = errors.New("account signs in with a password")
+
+type BulkResetTokensRequest struct {
+	Class string `json:"class"`
+	Role  string `json:"role"`
+}
+
+type ResetTokenResponse struct {
+	UserID   string `json:"user_id"`
+	Name     string `json:"name"`
+	Class    string `json:"class"`
+	Username string `json:"username"`
+	PIN      string `json:"pin"`
+}
+
+// ResetCredentials gives each profile a new PIN and signs it out everywhere.
+// It runs in one transaction, so either every code changes or none does and
+// no student is left with a code nobody has printed. Accounts that sign in
+// with a password are refused with errPasswordAccount; callers filter them
+// out first.
+func (h *AdminHandler) ResetCredentials(profiles []models.UserProfile) ([]ResetTokenResponse, error) {
+	for _, profile := range profiles {
+		if profile.PasswordHash != nil {
+			return nil, errPasswordAccount
+		}
+	}
+
+	results := make([]ResetTokenResponse, 0, len(profiles))
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		sessions := h.sessions.WithTx(tx)
+		for i := range profiles {
+			profile := &profiles[i]
+			profile.UpdatedAt = time.Now()
+
+			pin, err := issueCredentials(tx, profile, false)
+			if err != nil {
+				return err
+			}
+			if err := sessions.RevokeAll(profile.ID); err != nil {
+				return err
+			}
+
+			results = append(results, ResetTokenResponse{
+				UserID:   profile.ID.String(),
+				Name:     profile.Name,
+				Class:    profile.Class,
+				Username: *profile.Username,
+				PIN:      pin,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ResetUserToken godoc
+// @Summary Reset a user's login code
+// @Description Generate a new PIN for a user who forgot theirs. The old code stops working and the user is signed out everywhere. Users who sign in with a password get 409; set a temporary password for them instead.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} ResetTokenResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/users/{id}/reset-token [post]
+func (h *AdminHandler) ResetUserToken(c *gin.Context) {
+	var profile models.UserProfile
+	if err := h.db.Where("id = ?", c.Param("id")).First(&profile).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !checkManageableUser(c, h.db, h.permissions, &profile) {
+		return
+	}
+	if profile.PasswordHash != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User signs in with a password; set a temporary password instead"})
+		return
+	}
+
+	results, err := h.ResetCredentials([]models.UserProfile{profile})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results[0])
+}
+
+// BulkResetTokens godoc
+// @Summary Reset login codes for a class or role
+// @Description Generate new PINs for every user in a class and/or role and return printable login slips. Use format=json to get the codes as JSON instead of a PDF. The requesting admin, users with roles they cannot manage and users who sign in with a password are never reset; the JSON response counts the latter as skipped.
+// @Tags admin
+// @Accept json
+// @Produce application/pdf
+// @Produce json
+// @Security BearerAuth
+// @Param filter body BulkResetTokensRequest true "Class and/or role"
+// @Param format query string false "pdf (default) or json"
+// @Success 200 {file} file
+// @Router /admin/users/reset-tokens [post]
+func (h *AdminHandler) BulkResetTokens(c *gin.Context) {
+	var req BulkResetTokensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Class = strings.TrimSpace(req.Class)
+	if req.Class == "" && req.Role == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "class or role is required"})
+		return
+	}
+
+	roles, err := manageableRoleNames(c, h.db, h.permissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	query := scopeManageableUsers(h.db.Model(&models.UserProfile{}), roles)
+	if req.Class != "" {
+		query = query.Where("class = ?", req.Class)
+	}
+	if req.Role != "" {
+		query = query.Where("role = ?", req.Role)
+	}
+	if adminID, err := middleware.GetUserID(c); err == nil {
+		query = query.Where("id <> ?", adminID)
+	}
+
+	var matched []models.UserProfile
+	if err := query.Order("class ASC, name ASC").Find(&matched).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+	if len(matched) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No users match the filter"})
+		return
+	}
+	profiles, skipped := splitPasswordAccounts(matched)
+	if len(profiles) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Every matching user signs in with a password"})
+		return
+	}
+
+	results, err := h.ResetCredentials(profiles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset tokens"})
+		return
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, gin.H{"count": len(results), "skipped": len(skipped), "tokens": results})
+		return
+	}
+
+	slips := make([]printables.TokenSlip, 0, len(results))
+	for _, r := range results {
+		slips = append(slips, printables.TokenSlip{Name: r.Name, Class: r.Class, Username: r.Username, PIN: r.PIN})
+	}
+
+	title := "Kode Login G7KAIH"
+	if req.Class != "" {
+		title += " - Kelas " + req.Class
+	}
+
+	var buf bytes.Buffer
+	if err := printables.WriteTokenSlips(&buf, title, slips); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
+		return
+	}
+
+	filename := fmt.Sprintf("login-slips-%s.pdf", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// splitPasswordAccounts separates the profiles a PIN reset applies to from the
+// ones that sign in with a password, keeping the original order of both
+func splitPasswordAccounts(profiles []models.UserProfile) (pin, password []models.UserProfile) {
+	for _, profile := range profiles {
+		if profile.PasswordHash != nil {
+			password = append(password, profile)
+		} else {
+			pin = append(pin, profile)
+		}
+	}
+	return pin, password
+}
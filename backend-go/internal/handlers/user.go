@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
 	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
 	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"github.com/gin-gonic/gin"
@@ -11,11 +13,12 @@ import (
 )
 
 type UserHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	sessions *auth.SessionStore
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *gorm.DB, sessions *auth.SessionStore) *UserHandler {
+	return &UserHandler{db: db, sessions: sessions}
 }
 
 type UpdateProfileRequest struct {
@@ -26,10 +29,11 @@ type UpdateProfileRequest struct {
 }
 
 type ChangeTokenRequest struct {
-	CurrentToken string `json:"current_token" binding:"required,len=4"`
+	CurrentToken string `json:"current_token" binding:"required"` // current PIN or legacy 4-digit token
 }
 
 type ChangeTokenResponse struct {
+	Username string `json:"username"`
 	NewToken string `json:"new_token"`
 	Message  string `json:"message"`
 }
@@ -111,27 +115,27 @@ func (h *UserHandler) ChangeToken(c *gin.Context) {
 		return
 	}
 
-	currentHash := hashToken(req.CurrentToken)
-	if profile.TokenHash != currentHash {
+	if !credentialMatches(&profile, req.CurrentToken) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current token is incorrect"})
 		return
 	}
 
-	newToken, err := random4Digits()
+	// Legacy accounts get a username here and move to username + PIN login
+	profile.UpdatedAt = time.Now()
+	newToken, err := issueCredentials(h.db, &profile, false)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update token"})
 		return
 	}
 
-	profile.TokenHash = hashToken(newToken)
-	profile.UpdatedAt = time.Now()
-
-	if err := h.db.Save(&profile).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update token"})
-		return
+	// Sign out every other device that knew the old code
+	currentSession, _ := middleware.GetSessionID(c)
+	if err := h.sessions.RevokeOthers(userID, currentSession); err != nil {
+		log.Printf("Warning: failed to revoke sessions after token change: %v", err)
 	}
 
 	c.JSON(http.StatusOK, ChangeTokenResponse{
+		Username: *profile.Username,
 		NewToken: newToken,
 		Message:  "Token changed successfully. Please save your new token securely.",
 	})
@@ -1,23 +1,54 @@
 package handlers
 
 import (
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
-)
+	"net/http"
+	"strings"
 
-func random4Digits() (string, error) {
-	b := make([]byte, 2)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
-	}
-	n := int(b[0])<<8 | int(b[1])
-	n = n % 10000
-	return fmt.Sprintf("%04d", n), nil
-}
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/policy"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
 
 func hashToken(token string) string {
 	h := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(h[:])
-}
\ No newline at end of file
+}
+
+// deviceFingerprint identifies the client device for login throttling. Apps
+// send a stable X-Device-ID; browsers without one fall back to user agent + IP.
+// Both come from the client, so the account counter backs them up.
+func deviceFingerprint(c *gin.Context) string {
+	if deviceID := strings.TrimSpace(c.GetHeader("X-Device-ID")); deviceID != "" {
+		return hashToken("device:" + deviceID)
+	}
+	return hashToken("ua:" + c.Request.UserAgent() + "|" + c.ClientIP())
+}
+
+func loginSubject(c *gin.Context) auth.LoginSubject {
+	return auth.LoginSubject{
+		IP:          c.ClientIP(),
+		Fingerprint: deviceFingerprint(c),
+		UserAgent:   c.Request.UserAgent(),
+	}
+}
+
+// loadSubject builds the policy subject of the current user, answering the
+// request itself when that fails
+func loadSubject(c *gin.Context, db *gorm.DB) (*policy.Subject, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	subject, err := policy.Load(db, userID, middleware.GetPermissions(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, false
+	}
+	return subject, true
+}
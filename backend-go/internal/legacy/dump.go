@@ -0,0 +1,166 @@
+package legacy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+type dumpSource struct {
+	tables map[string][]Row
+}
+
+// copyHeader matches the statement pg_dump writes before the data of a
+// table: COPY public.aktivitas (activityid, "isValidateByTeacher") FROM stdin;
+var copyHeader = regexp.MustCompile(`^COPY\s+(?:"?(\w+)"?\.)?"?(\w+)"?\s*\((.*)\)\s+FROM\s+stdin;\s*$`)
+
+// ReadDump reads the data of a plain-text pg_dump, such as
+// `pg_dump --data-only` or `supabase db dump --data-only --use-copy`. Only
+// COPY blocks of the public schema are read; INSERT statements are not
+// supported.
+func ReadDump(r io.Reader) (Source, error) {
+	src := &dumpSource{tables: make(map[string][]Row)}
+	br := bufio.NewReader(r)
+
+	var (
+		table   string
+		columns []string
+		skip    bool // inside the data of another schema, e.g. auth.users
+		line    int
+	)
+	for {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if text == "" && err == io.EOF {
+			break
+		}
+		line++
+		text = strings.TrimRight(text, "\r\n")
+
+		if columns == nil {
+			if m := copyHeader.FindStringSubmatch(text); m != nil {
+				table = m[2]
+				columns = parseColumns(m[3])
+				skip = m[1] != "" && m[1] != "public"
+				if _, ok := src.tables[table]; !ok && !skip {
+					src.tables[table] = []Row{}
+				}
+			}
+		} else if text == `\.` {
+			columns = nil
+		} else if !skip {
+			fields := strings.Split(text, "\t")
+			if len(fields) != len(columns) {
+				return nil, fmt.Errorf("line %d: %s row has %d columns, expected %d", line, table, len(fields), len(columns))
+			}
+			row := make(Row, len(columns))
+			for i, field := range fields {
+				if field != `\N` {
+					row[columns[i]] = unescapeCopy(field)
+				}
+			}
+			src.tables[table] = append(src.tables[table], row)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if columns != nil {
+		return nil, fmt.Errorf("dump ends inside the data of %s", table)
+	}
+	return src, nil
+}
+
+func (s *dumpSource) Rows(table string) ([]Row, error) {
+	rows, ok := s.tables[table]
+	if !ok {
+		return nil, ErrTableMissing
+	}
+	return rows, nil
+}
+
+func parseColumns(list string) []string {
+	parts := strings.Split(list, ",")
+	columns := make([]string, len(parts))
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, `"`) {
+			p = strings.ReplaceAll(strings.Trim(p, `"`), `""`, `"`)
+		}
+		columns[i] = p
+	}
+	return columns
+}
+
+// unescapeCopy undoes the backslash escapes of the COPY text format
+func unescapeCopy(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	b.Grow(len(field))
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i+1 == len(field) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch next := field[i]; next {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			n, v := 0, byte(0)
+			for ; n < 2 && i+1 < len(field) && isHex(field[i+1]); n++ {
+				i++
+				v = v<<4 | hexValue(field[i])
+			}
+			if n == 0 {
+				b.WriteByte('x')
+			} else {
+				b.WriteByte(v)
+			}
+		default:
+			if next >= '0' && next <= '7' {
+				v := next - '0'
+				for n := 1; n < 3 && i+1 < len(field) && field[i+1] >= '0' && field[i+1] <= '7'; n++ {
+					i++
+					v = v<<3 | (field[i] - '0')
+				}
+				b.WriteByte(v)
+			} else {
+				b.WriteByte(next)
+			}
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
@@ -0,0 +1,108 @@
+package legacy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDump = `--
+-- PostgreSQL database dump
+--
+
+COPY auth.users (id, email) FROM stdin;
+11111111-1111-1111-1111-111111111111	siswa@example.com
+\.
+
+COPY public.user_profiles (userid, username, roleid, kelas) FROM stdin;
+11111111-1111-1111-1111-111111111111	Budi\tSantoso	5	7A
+22222222-2222-2222-2222-222222222222	\N	4	\N
+\.
+
+COPY public.aktivitas_field_values (id, value, "isValidateByTeacher") FROM stdin;
+33333333-3333-3333-3333-333333333333	Baris satu\nBaris dua	t
+\.
+
+COPY public.aktivitas_field_files (id, file_bytes) FROM stdin;
+44444444-4444-4444-4444-444444444444	\\x89504e47
+\.
+`
+
+func TestReadDump(t *testing.T) {
+	src, err := ReadDump(strings.NewReader(testDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.Rows("users"); !errors.Is(err, ErrTableMissing) {
+		t.Errorf("auth.users should not be read, got %v", err)
+	}
+	if _, err := src.Rows("komentar"); !errors.Is(err, ErrTableMissing) {
+		t.Errorf("missing table: got %v, want ErrTableMissing", err)
+	}
+
+	users, err := src.Rows("user_profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{"userid": "11111111-1111-1111-1111-111111111111", "username": "Budi\tSantoso", "roleid": "5", "kelas": "7A"},
+		{"userid": "22222222-2222-2222-2222-222222222222", "roleid": "4"},
+	}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("user_profiles = %v, want %v", users, want)
+	}
+
+	values, _ := src.Rows("aktivitas_field_values")
+	if got := values[0]["value"]; got != "Baris satu\nBaris dua" {
+		t.Errorf("value = %q", got)
+	}
+	if !values[0].bool("isValidateByTeacher") {
+		t.Error("quoted column isValidateByTeacher should be read as true")
+	}
+
+	files, _ := src.Rows("aktivitas_field_files")
+	data, err := files[0].bytes("file_bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "\x89PNG" {
+		t.Errorf("file_bytes = %q, want PNG signature", data)
+	}
+}
+
+func TestReadDumpUnterminatedCopy(t *testing.T) {
+	_, err := ReadDump(strings.NewReader("COPY public.role (roleid, rolename) FROM stdin;\n1\tadmin\n"))
+	if err == nil {
+		t.Fatal("expected an error for a dump that ends inside COPY data")
+	}
+}
+
+func TestMapRole(t *testing.T) {
+	tests := map[string]string{
+		"Admin":    "admin",
+		"teacher":  "guru",
+		"guruwali": "guruwali",
+		"parent":   "orangtua",
+		"Student":  "siswa",
+	}
+	for legacy, want := range tests {
+		if got, ok := mapRole(legacy); !ok || got != want {
+			t.Errorf("mapRole(%q) = %q, %v; want %q", legacy, got, ok, want)
+		}
+	}
+	if _, ok := mapRole("Unknown"); ok {
+		t.Error("the Unknown role of pending accounts should not map to a role")
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	multi := &fieldMeta{fieldType: "multiselect"}
+	if got := fieldValue(multi, "Senang, Semangat,"); !reflect.DeepEqual(got, []string{"Senang", "Semangat"}) {
+		t.Errorf("multiselect value = %#v", got)
+	}
+	if got := fieldValue(&fieldMeta{fieldType: "time"}, "06:30"); got != "06:30" {
+		t.Errorf("time value = %#v", got)
+	}
+}
@@ -0,0 +1,1015 @@
+package legacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/forms"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// jakarta is the timezone the Next.js app used for submitted_date
+var jakarta = time.FixedZone("WIB", 7*60*60)
+
+// idNamespace derives stable IDs for rows the legacy schema had no ID for,
+// such as parent links, so a second run finds them instead of adding copies
+var idNamespace = uuid.MustParse("0b8f9e5c-6a4e-4c1e-9a57-6f1c2d7b3e10")
+
+const batchSize = 500
+
+var errDryRun = errors.New("dry run")
+
+// Options controls an import
+type Options struct {
+	// DryRun runs the whole import and its reconciliation in a transaction
+	// that is rolled back
+	DryRun bool
+}
+
+// EntityReport reconciles one kind of record. Target is what the Go
+// database holds for the imported records after the import; it is lower
+// than Imported when rows were deleted on the Go side since.
+type EntityReport struct {
+	Entity   string `json:"entity"`
+	Source   int    `json:"source"`
+	Imported int    `json:"imported"`
+	Skipped  int    `json:"skipped"`
+	Target   int64  `json:"target"`
+
+	target func(tx *gorm.DB) (int64, error)
+}
+
+// Report is the reconciliation report of an import. Issues explains every
+// skipped record and every record that was mapped with a fallback.
+type Report struct {
+	DryRun   bool            `json:"dry_run"`
+	Entities []*EntityReport `json:"entities"`
+	Issues   []string        `json:"issues"`
+}
+
+type fieldMeta struct {
+	id         string
+	categoryID string
+	key        string
+	label      string
+	fieldType  string
+	required   bool
+	order      int
+	config     string
+}
+
+type importer struct {
+	tx     *gorm.DB
+	src    Source
+	report *Report
+
+	roles      map[string]string               // legacy roleid -> role name
+	users      map[uuid.UUID]bool              // imported user IDs
+	categories map[string]uuid.UUID            // legacy categoryid -> category ID
+	fields     map[string]*fieldMeta           // legacy fieldid -> field
+	fieldKeys  map[uuid.UUID]map[string]string // kegiatan ID -> legacy fieldid -> form_data key
+	forms      map[uuid.UUID]*uuid.UUID        // kegiatan ID -> current form version ID
+	activities map[uuid.UUID]uuid.UUID         // imported activity ID -> kegiatan ID
+}
+
+// Import copies the legacy data into the Go database in one transaction.
+// Records keep their legacy UUIDs and are upserted, so running the import
+// again updates what it imported before (overwriting changes made to those
+// columns on the Go side) and adds what is new.
+//
+// Legacy users log in through Supabase Auth, so imported users have no
+// credentials yet; give them codes with `g7kaih-admin reset-token`.
+func Import(db *gorm.DB, src Source, opts Options) (*Report, error) {
+	imp := &importer{
+		src:        src,
+		report:     &Report{DryRun: opts.DryRun, Entities: []*EntityReport{}, Issues: []string{}},
+		roles:      make(map[string]string),
+		users:      make(map[uuid.UUID]bool),
+		categories: make(map[string]uuid.UUID),
+		fields:     make(map[string]*fieldMeta),
+		fieldKeys:  make(map[uuid.UUID]map[string]string),
+		forms:      make(map[uuid.UUID]*uuid.UUID),
+		activities: make(map[uuid.UUID]uuid.UUID),
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		imp.tx = tx
+		steps := []func() error{
+			imp.importRoles,
+			imp.importUsers,
+			imp.importCategories,
+			imp.importKegiatan,
+			imp.importActivities,
+			imp.importFiles,
+			imp.importComments,
+			imp.reconcile,
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return imp.report, nil
+}
+
+func (imp *importer) entity(name string) *EntityReport {
+	e := &EntityReport{Entity: name}
+	imp.report.Entities = append(imp.report.Entities, e)
+	return e
+}
+
+func (imp *importer) issue(format string, args ...interface{}) {
+	imp.report.Issues = append(imp.report.Issues, fmt.Sprintf(format, args...))
+}
+
+func (imp *importer) skip(e *EntityReport, format string, args ...interface{}) {
+	e.Skipped++
+	imp.issue(e.Entity+": "+format, args...)
+}
+
+// rows reads a legacy table. Only role and user_profiles must exist; the
+// other tables are reported and treated as empty when missing.
+func (imp *importer) rows(table string, required bool) ([]Row, error) {
+	rows, err := imp.src.Rows(table)
+	if errors.Is(err, ErrTableMissing) {
+		if required {
+			return nil, fmt.Errorf("legacy table %s not found", table)
+		}
+		imp.issue("legacy table %s not found, nothing imported from it", table)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legacy table %s: %w", table, err)
+	}
+	return rows, nil
+}
+
+// upsert inserts the rows, updating the given columns of rows whose ID
+// already exists
+func (imp *importer) upsert(rows interface{}, columns ...string) error {
+	if reflect.ValueOf(rows).Len() == 0 {
+		return nil
+	}
+	return imp.tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).CreateInBatches(rows, batchSize).Error
+}
+
+// insertMissing inserts the rows, leaving out those that conflict with an
+// existing row on any unique key
+func (imp *importer) insertMissing(rows interface{}) error {
+	if reflect.ValueOf(rows).Len() == 0 {
+		return nil
+	}
+	return imp.tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, batchSize).Error
+}
+
+// countIn returns a target counter for rows of model whose column is one of
+// ids and that match the optional extra condition
+func countIn(model interface{}, column string, ids []uuid.UUID, cond ...interface{}) func(tx *gorm.DB) (int64, error) {
+	return func(tx *gorm.DB) (int64, error) {
+		var total int64
+		for start := 0; start < len(ids); start += batchSize {
+			end := start + batchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			query := tx.Model(model).Where(column+" IN ?", ids[start:end])
+			if len(cond) > 0 {
+				query = query.Where(cond[0], cond[1:]...)
+			}
+			var n int64
+			if err := query.Count(&n).Error; err != nil {
+				return 0, err
+			}
+			total += n
+		}
+		return total, nil
+	}
+}
+
+// mapRole maps a legacy role name to a built-in role; ok is false for the
+// Unknown role of accounts an admin has not approved
+func mapRole(name string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "admin", "administrator", "superadmin":
+		return "admin", true
+	case "teacher", "guru":
+		return "guru", true
+	case "guruwali", "guru_wali", "guru wali":
+		return "guruwali", true
+	case "parent", "orangtua", "orang tua":
+		return "orangtua", true
+	case "student", "siswa":
+		return "siswa", true
+	}
+	return "", false
+}
+
+// mapActivityStatus maps the legacy aktivitas status to pending, approved or
+// rejected
+func mapActivityStatus(status string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "", "pending", "completed", "submitted", "parent_validated":
+		return "pending", true
+	case "teacher_validated", "fully_validated", "validated", "approved":
+		return "approved", true
+	case "rejected":
+		return "rejected", true
+	}
+	return "pending", false
+}
+
+func (imp *importer) importRoles() error {
+	rows, err := imp.rows("role", true)
+	if err != nil {
+		return err
+	}
+	e := imp.entity("roles")
+	e.Source = len(rows)
+
+	var names []string
+	for _, row := range rows {
+		role, ok := mapRole(row.str("rolename"))
+		if !ok {
+			imp.skip(e, "role %s (%q) has no equivalent; its users are not imported", row.str("roleid"), row.str("rolename"))
+			continue
+		}
+		imp.roles[row.str("roleid")] = role
+		names = append(names, role)
+		e.Imported++
+	}
+
+	e.target = func(tx *gorm.DB) (int64, error) {
+		var existing []string
+		if err := tx.Model(&models.Role{}).Where("name IN ?", names).Pluck("name", &existing).Error; err != nil {
+			return 0, err
+		}
+		found := make(map[string]bool, len(existing))
+		for _, name := range existing {
+			found[name] = true
+		}
+		var n int64
+		for _, name := range names {
+			if found[name] {
+				n++
+			}
+		}
+		return n, nil
+	}
+	return nil
+}
+
+func (imp *importer) importUsers() error {
+	rows, err := imp.rows("user_profiles", true)
+	if err != nil {
+		return err
+	}
+	users := imp.entity("users")
+	users.Source = len(rows)
+
+	profiles := make([]models.UserProfile, 0, len(rows))
+	var ids []uuid.UUID
+	for _, row := range rows {
+		id, err := uuid.Parse(row.str("userid"))
+		if err != nil {
+			imp.skip(users, "invalid userid %q", row.str("userid"))
+			continue
+		}
+		role, ok := imp.roles[row.str("roleid")]
+		if !ok {
+			imp.skip(users, "user %s (%s) has role %s, which is not imported", id, row.str("username"), row.str("roleid"))
+			continue
+		}
+
+		name := row.str("username")
+		if name == "" {
+			name, _, _ = strings.Cut(row.str("email"), "@")
+		}
+		if name == "" {
+			name = "Tanpa Nama"
+			imp.issue("users: user %s has no username or email, named %q", id, name)
+		}
+
+		profile := models.UserProfile{ID: id, Name: name, Class: row.str("kelas"), Role: role, Status: "active"}
+		if t, ok := row.time("created_at"); ok {
+			profile.CreatedAt = t
+		}
+		if t, ok := row.time("updated_at"); ok {
+			profile.UpdatedAt = t
+		}
+		profiles = append(profiles, profile)
+		ids = append(ids, id)
+		imp.users[id] = true
+	}
+	if err := imp.upsert(&profiles, "name", "class", "role"); err != nil {
+		return fmt.Errorf("failed to import users: %w", err)
+	}
+	users.Imported = len(profiles)
+	users.target = countIn(&models.UserProfile{}, "id", ids)
+
+	return imp.importUserLinks(rows)
+}
+
+// importUserLinks maps is_guruwali, the kelas of teachers, parent_of_userid
+// and guruwali_userid to extra roles, class assignments, parent links and
+// guru wali assignments
+func (imp *importer) importUserLinks(rows []Row) error {
+	userRoles := imp.entity("user_roles")
+	teacherRoles := imp.entity("teacher_roles")
+	parents := imp.entity("parent_links")
+	guruwali := imp.entity("guruwali_assignments")
+
+	var (
+		extraRoles  []models.UserRole
+		classes     []models.TeacherRole
+		links       []models.ParentStudent
+		assignments []models.GuruWaliAssignment
+		roleUserIDs []uuid.UUID
+		classIDs    []uuid.UUID
+		linkIDs     []uuid.UUID
+		assignIDs   []uuid.UUID
+	)
+	for _, row := range rows {
+		id, err := uuid.Parse(row.str("userid"))
+		if err != nil || !imp.users[id] {
+			continue
+		}
+		role := imp.roles[row.str("roleid")]
+
+		if row.bool("is_guruwali") && role != "guruwali" {
+			userRoles.Source++
+			extraRoles = append(extraRoles, models.UserRole{UserProfileID: id, RoleName: "guruwali"})
+			roleUserIDs = append(roleUserIDs, id)
+		}
+
+		if class := row.str("kelas"); class != "" && (role == "guru" || role == "guruwali") {
+			teacherRoles.Source++
+			classID := uuid.NewSHA1(idNamespace, []byte("teacher_role:"+id.String()+":"+class))
+			classes = append(classes, models.TeacherRole{ID: classID, TeacherID: id, ClassName: class})
+			classIDs = append(classIDs, classID)
+		}
+
+		if ref := row.str("parent_of_userid"); ref != "" {
+			parents.Source++
+			studentID, err := uuid.Parse(ref)
+			if err != nil || !imp.users[studentID] {
+				imp.skip(parents, "parent %s is linked to %s, which is not imported", id, ref)
+			} else {
+				linkID := uuid.NewSHA1(idNamespace, []byte("parent_student:"+id.String()+":"+studentID.String()))
+				links = append(links, models.ParentStudent{ID: linkID, ParentID: id, StudentID: studentID})
+				linkIDs = append(linkIDs, linkID)
+			}
+		}
+
+		if ref := row.str("guruwali_userid"); ref != "" {
+			guruwali.Source++
+			teacherID, err := uuid.Parse(ref)
+			if err != nil || !imp.users[teacherID] {
+				imp.skip(guruwali, "student %s has guru wali %s, who is not imported", id, ref)
+			} else {
+				assignID := uuid.NewSHA1(idNamespace, []byte("guruwali_assignment:"+teacherID.String()+":"+id.String()))
+				assignments = append(assignments, models.GuruWaliAssignment{ID: assignID, TeacherID: teacherID, StudentID: id})
+				assignIDs = append(assignIDs, assignID)
+			}
+		}
+	}
+
+	if err := imp.insertMissing(&extraRoles); err != nil {
+		return fmt.Errorf("failed to import user roles: %w", err)
+	}
+	if err := imp.insertMissing(&classes); err != nil {
+		return fmt.Errorf("failed to import teacher classes: %w", err)
+	}
+	if err := imp.insertMissing(&links); err != nil {
+		return fmt.Errorf("failed to import parent links: %w", err)
+	}
+	if err := imp.insertMissing(&assignments); err != nil {
+		return fmt.Errorf("failed to import guru wali assignments: %w", err)
+	}
+
+	userRoles.Imported = len(extraRoles)
+	teacherRoles.Imported = len(classes)
+	parents.Imported = len(links)
+	guruwali.Imported = len(assignments)
+
+	userRoles.target = countIn(&models.UserRole{}, "user_profile_id", roleUserIDs, "role_name = ?", "guruwali")
+	teacherRoles.target = countIn(&models.TeacherRole{}, "id", classIDs)
+	parents.target = countIn(&models.ParentStudent{}, "id", linkIDs)
+	guruwali.target = countIn(&models.GuruWaliAssignment{}, "id", assignIDs)
+	return nil
+}
+
+func (imp *importer) importCategories() error {
+	rows, err := imp.rows("category", false)
+	if err != nil {
+		return err
+	}
+	e := imp.entity("categories")
+	e.Source = len(rows)
+
+	// Category names are unique; a legacy category whose name is already
+	// taken by another ID is merged into that category
+	var existing []models.Category
+	if err := imp.tx.Unscoped().Find(&existing).Error; err != nil {
+		return err
+	}
+	byName := make(map[string]uuid.UUID, len(existing))
+	for _, c := range existing {
+		byName[strings.ToLower(c.Name)] = c.ID
+	}
+
+	var categories []models.Category
+	var ids []uuid.UUID
+	for _, row := range rows {
+		id, err := uuid.Parse(row.str("categoryid"))
+		if err != nil {
+			imp.skip(e, "invalid categoryid %q", row.str("categoryid"))
+			continue
+		}
+		name := row.str("categoryname")
+		if name == "" {
+			name = "Kategori " + id.String()[:8]
+		}
+		if other, ok := byName[strings.ToLower(name)]; ok && other != id {
+			imp.issue("categories: %s (%q) merged into existing category %s with the same name", id, name, other)
+			imp.categories[id.String()] = other
+			ids = append(ids, other)
+			e.Imported++
+			continue
+		}
+
+		category := models.Category{ID: id, Name: name}
+		if t, ok := row.time("created_at"); ok {
+			category.CreatedAt = t
+		}
+		categories = append(categories, category)
+		byName[strings.ToLower(name)] = id
+		imp.categories[id.String()] = id
+		ids = append(ids, id)
+		e.Imported++
+	}
+	if err := imp.upsert(&categories, "name"); err != nil {
+		return fmt.Errorf("failed to import categories: %w", err)
+	}
+	e.target = countIn(&models.Category{}, "id", ids)
+	return nil
+}
+
+// fallbackCategory returns the category for kegiatan that had none, which
+// the Go schema requires
+func (imp *importer) fallbackCategory() (uuid.UUID, error) {
+	const name = "Lainnya"
+	var category models.Category
+	err := imp.tx.Unscoped().Where("LOWER(name) = LOWER(?)", name).First(&category).Error
+	if err == nil {
+		return category.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, err
+	}
+	category = models.Category{ID: uuid.NewSHA1(idNamespace, []byte("category:"+name)), Name: name}
+	if err := imp.tx.Create(&category).Error; err != nil {
+		return uuid.Nil, err
+	}
+	imp.issue("categories: created category %q for kegiatan without a category", name)
+	return category.ID, nil
+}
+
+// importKegiatan builds each kegiatan's form schema from the fields of its
+// legacy categories. Field keys were only unique within a category, so a
+// key used by two categories of one kegiatan is suffixed with the field ID.
+func (imp *importer) importKegiatan() error {
+	rows, err := imp.rows("kegiatan", false)
+	if err != nil {
+		return err
+	}
+	links, err := imp.rows("kegiatan_categories", false)
+	if err != nil {
+		return err
+	}
+	fieldRows, err := imp.rows("category_fields", false)
+	if err != nil {
+		return err
+	}
+
+	e := imp.entity("kegiatan")
+	e.Source = len(rows)
+
+	fieldsByCategory := make(map[string][]*fieldMeta)
+	for _, row := range fieldRows {
+		order, _ := strconv.Atoi(row.str("order_index"))
+		f := &fieldMeta{
+			id:         row.str("fieldid"),
+			categoryID: row.str("categoryid"),
+			key:        row.str("field_key"),
+			label:      row.str("label"),
+			fieldType:  row.str("type"),
+			required:   row.bool("required"),
+			order:      order,
+			config:     row.str("config"),
+		}
+		imp.fields[f.id] = f
+		fieldsByCategory[f.categoryID] = append(fieldsByCategory[f.categoryID], f)
+	}
+	for _, fields := range fieldsByCategory {
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].order < fields[j].order })
+	}
+
+	categoriesOf := make(map[string][]string)
+	for _, link := range links {
+		kegiatanID, categoryID := link.str("kegiatanid"), link.str("categoryid")
+		categoriesOf[kegiatanID] = append(categoriesOf[kegiatanID], categoryID)
+	}
+
+	var kegiatan []models.Kegiatan
+	var ids []uuid.UUID
+	for _, row := range rows {
+		id, err := uuid.Parse(row.str("kegiatanid"))
+		if err != nil {
+			imp.skip(e, "invalid kegiatanid %q", row.str("kegiatanid"))
+			continue
+		}
+		name := row.str("kegiatanname")
+		if name == "" {
+			name = "Kegiatan " + id.String()[:8]
+		}
+
+		var categoryID uuid.UUID
+		schema := forms.Schema{Fields: []forms.Field{}}
+		keys := make(map[string]string)
+		used := make(map[string]bool)
+		for _, legacyCategory := range categoriesOf[id.String()] {
+			mapped, ok := imp.categories[legacyCategory]
+			if !ok {
+				imp.issue("kegiatan: %s (%q) links to category %s, which is not imported", id, name, legacyCategory)
+				continue
+			}
+			if categoryID == uuid.Nil {
+				categoryID = mapped
+			}
+			for _, f := range fieldsByCategory[legacyCategory] {
+				key := f.key
+				if used[key] {
+					key = f.key + "_" + f.id[:8]
+					imp.issue("kegiatan: %s (%q) has field key %q in several categories; field %s is stored as %q", id, name, f.key, f.id, key)
+				}
+				used[key] = true
+				keys[f.id] = key
+				schema.Fields = append(schema.Fields, f.schemaField(key, mapped))
+			}
+		}
+		if len(categoriesOf[id.String()]) > 1 {
+			imp.issue("kegiatan: %s (%q) had %d categories; the first is its category and the form holds the fields of all", id, name, len(categoriesOf[id.String()]))
+		}
+		if categoryID == uuid.Nil {
+			if categoryID, err = imp.fallbackCategory(); err != nil {
+				return err
+			}
+		}
+
+		formSchema, err := schema.Encode()
+		if err != nil {
+			return err
+		}
+		k := models.Kegiatan{ID: id, Name: name, CategoryID: categoryID, FormSchema: &formSchema, IsActive: true}
+		if t, ok := row.time("created_at"); ok {
+			k.CreatedAt = t
+		}
+		kegiatan = append(kegiatan, k)
+		imp.fieldKeys[id] = keys
+		ids = append(ids, id)
+	}
+	if err := imp.upsert(&kegiatan, "name", "category_id", "form_schema"); err != nil {
+		return fmt.Errorf("failed to import kegiatan: %w", err)
+	}
+	// A form that changed since the last import becomes a new version
+	for i := range kegiatan {
+		version, err := forms.Publish(imp.tx, &kegiatan[i], nil)
+		if err != nil {
+			return fmt.Errorf("failed to version the form of kegiatan %s: %w", kegiatan[i].ID, err)
+		}
+		if version != nil {
+			imp.forms[kegiatan[i].ID] = &version.ID
+		}
+	}
+	e.Imported = len(kegiatan)
+	e.target = countIn(&models.Kegiatan{}, "id", ids)
+	return nil
+}
+
+// schemaField returns the form field of a legacy field, tagged with the Go
+// category it was imported into
+func (f *fieldMeta) schemaField(key string, category uuid.UUID) forms.Field {
+	field := forms.Field{Key: key, Label: f.label, Type: f.fieldType, Required: f.required, CategoryID: category.String()}
+	if field.Label == "" {
+		field.Label = f.key
+	}
+	if field.Type == "" {
+		field.Type = forms.TypeText
+	}
+	if f.config != "" && f.config != "{}" && json.Valid([]byte(f.config)) {
+		var config struct {
+			Options []string `json:"options"`
+		}
+		if json.Unmarshal([]byte(f.config), &config) == nil {
+			field.Options = config.Options
+		}
+		field.Config = json.RawMessage(f.config)
+	}
+	return field
+}
+
+// fieldKey returns the form_data key of a legacy field in an activity of
+// the kegiatan
+func (imp *importer) fieldKey(kegiatanID uuid.UUID, fieldID string) (string, bool) {
+	if key, ok := imp.fieldKeys[kegiatanID][fieldID]; ok {
+		return key, true
+	}
+	// The field's category was unlinked from the kegiatan after the activity
+	// was submitted; keep the value under its own key
+	if f, ok := imp.fields[fieldID]; ok {
+		return f.key, true
+	}
+	return "", false
+}
+
+// importActivities imports aktivitas with their field values as form_data,
+// and the teacher/parent validation flags of those values
+func (imp *importer) importActivities() error {
+	rows, err := imp.rows("aktivitas", false)
+	if err != nil {
+		return err
+	}
+	valueRows, err := imp.rows("aktivitas_field_values", false)
+	if err != nil {
+		return err
+	}
+
+	activitiesReport := imp.entity("activities")
+	values := imp.entity("field_values")
+	validationsReport := imp.entity("field_validations")
+	activitiesReport.Source = len(rows)
+
+	var activities []models.Activity
+	var ids []uuid.UUID
+	for _, row := range rows {
+		id, err := uuid.Parse(row.str("activityid"))
+		if err != nil {
+			imp.skip(activitiesReport, "invalid activityid %q", row.str("activityid"))
+			continue
+		}
+		userID, err := uuid.Parse(row.str("userid"))
+		if err != nil || !imp.users[userID] {
+			imp.skip(activitiesReport, "%s belongs to user %s, who is not imported", id, row.str("userid"))
+			continue
+		}
+		kegiatanID, err := uuid.Parse(row.str("kegiatanid"))
+		if _, ok := imp.fieldKeys[kegiatanID]; err != nil || !ok {
+			imp.skip(activitiesReport, "%s is for kegiatan %s, which is not imported", id, row.str("kegiatanid"))
+			continue
+		}
+
+		date, ok := row.time("submitted_date")
+		if !ok {
+			created, _ := row.time("created_at")
+			date = created.In(jakarta)
+		}
+		status, known := mapActivityStatus(row.str("status"))
+		if !known {
+			imp.issue("activities: %s has unknown status %q, imported as pending", id, row.str("status"))
+		}
+
+		activity := models.Activity{
+			ID:            id,
+			UserProfileID: userID,
+			KegiatanID:    kegiatanID,
+			Date:          time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+			FormVersionID: imp.forms[kegiatanID],
+			Status:        status,
+			Notes:         row.ptr("activitycontent"),
+		}
+		if t, ok := row.time("created_at"); ok {
+			activity.CreatedAt = t
+		}
+		if t, ok := row.time("updated_at"); ok {
+			activity.UpdatedAt = t
+		}
+		activities = append(activities, activity)
+		imp.activities[id] = kegiatanID
+		ids = append(ids, id)
+	}
+
+	formData := make(map[uuid.UUID]map[string]interface{})
+	var validations []models.ActivityFieldValidation
+	var valueActivityIDs []uuid.UUID
+	for _, row := range valueRows {
+		hasValue := row.ptr("value") != nil
+		flagged := row.bool("isValidateByTeacher") || row.bool("isValidateByParent")
+		if hasValue {
+			values.Source++
+		}
+		if flagged {
+			validationsReport.Source++
+		}
+		if !hasValue && !flagged {
+			continue
+		}
+
+		skip := func(format string, args ...interface{}) {
+			if hasValue {
+				imp.skip(values, format, args...)
+				if flagged {
+					validationsReport.Skipped++
+				}
+			} else {
+				imp.skip(validationsReport, format, args...)
+			}
+		}
+		activityID, err := uuid.Parse(row.str("activityid"))
+		kegiatanID, ok := imp.activities[activityID]
+		if err != nil || !ok {
+			skip("value %s is for activity %s, which is not imported", row.str("id"), row.str("activityid"))
+			continue
+		}
+		key, ok := imp.fieldKey(kegiatanID, row.str("fieldid"))
+		if !ok {
+			skip("value %s is for unknown field %s", row.str("id"), row.str("fieldid"))
+			continue
+		}
+
+		if hasValue {
+			data := formData[activityID]
+			if data == nil {
+				data = make(map[string]interface{})
+				formData[activityID] = data
+			}
+			if _, dup := data[key]; dup {
+				imp.skip(values, "activity %s has several values for %q; value %s is dropped", activityID, key, row.str("id"))
+			} else {
+				data[key] = fieldValue(imp.fields[row.str("fieldid")], row.str("value"))
+				values.Imported++
+			}
+		}
+
+		if flagged {
+			id, err := uuid.Parse(row.str("id"))
+			if err != nil {
+				id = uuid.NewSHA1(idNamespace, []byte("field_validation:"+activityID.String()+":"+key))
+			}
+			validations = append(validations, models.ActivityFieldValidation{
+				ID:                 id,
+				ActivityID:         activityID,
+				FieldKey:           key,
+				ValidatedByTeacher: row.bool("isValidateByTeacher"),
+				ValidatedByParent:  row.bool("isValidateByParent"),
+			})
+			valueActivityIDs = append(valueActivityIDs, activityID)
+		}
+	}
+
+	for i := range activities {
+		if data, ok := formData[activities[i].ID]; ok {
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			s := string(encoded)
+			activities[i].FormData = &s
+		}
+	}
+	if err := imp.upsert(&activities, "user_profile_id", "kegiatan_id", "date", "form_data", "form_version_id", "status", "notes"); err != nil {
+		return fmt.Errorf("failed to import activities: %w", err)
+	}
+	activitiesReport.Imported = len(activities)
+	activitiesReport.target = countIn(&models.Activity{}, "id", ids)
+	values.target = func(tx *gorm.DB) (int64, error) {
+		var total int64
+		for start := 0; start < len(ids); start += batchSize {
+			end := start + batchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			var n int64
+			if err := tx.Raw(
+				"SELECT COALESCE(SUM((SELECT COUNT(*) FROM jsonb_object_keys(form_data))), 0) FROM activities WHERE id IN ? AND form_data IS NOT NULL AND jsonb_typeof(form_data) = 'object'",
+				ids[start:end],
+			).Scan(&n).Error; err != nil {
+				return 0, err
+			}
+			total += n
+		}
+		return total, nil
+	}
+
+	// One validation per activity field; the unique key decides, since a
+	// row may already exist with another ID
+	if len(validations) > 0 {
+		if err := imp.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "activity_id"}, {Name: "field_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"validated_by_teacher", "validated_by_parent"}),
+		}).CreateInBatches(&validations, batchSize).Error; err != nil {
+			return fmt.Errorf("failed to import field validations: %w", err)
+		}
+	}
+	validationsReport.Imported = len(validations)
+	validationsReport.target = countIn(&models.ActivityFieldValidation{}, "activity_id", uniqueIDs(valueActivityIDs),
+		"validated_by_teacher OR validated_by_parent")
+	return nil
+}
+
+// fieldValue converts a legacy text value: multiselect values were stored
+// comma-separated
+func fieldValue(f *fieldMeta, value string) interface{} {
+	if f != nil && f.fieldType == forms.TypeMultiselect {
+		options := []string{}
+		for _, option := range strings.Split(value, ",") {
+			if option = strings.TrimSpace(option); option != "" {
+				options = append(options, option)
+			}
+		}
+		return options
+	}
+	return value
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// importFiles imports files stored in the database (aktivitas_field_files)
+// and uploaded to Cloudinary (aktivitas_field_images)
+func (imp *importer) importFiles() error {
+	stored, err := imp.rows("aktivitas_field_files", false)
+	if err != nil {
+		return err
+	}
+	uploaded, err := imp.rows("aktivitas_field_images", false)
+	if err != nil {
+		return err
+	}
+	e := imp.entity("files")
+	e.Source = len(stored) + len(uploaded)
+
+	var files []models.ActivityFile
+	var ids []uuid.UUID
+	add := func(row Row, file models.ActivityFile) {
+		id, err := uuid.Parse(row.str("id"))
+		if err != nil {
+			imp.skip(e, "invalid file id %q", row.str("id"))
+			return
+		}
+		activityID, err := uuid.Parse(row.str("activityid"))
+		kegiatanID, ok := imp.activities[activityID]
+		if err != nil || !ok {
+			imp.skip(e, "file %s is for activity %s, which is not imported", id, row.str("activityid"))
+			return
+		}
+		key, ok := imp.fieldKey(kegiatanID, row.str("fieldid"))
+		if !ok {
+			imp.skip(e, "file %s is for unknown field %s", id, row.str("fieldid"))
+			return
+		}
+
+		file.ID = id
+		file.ActivityID = activityID
+		file.FieldKey = key
+		file.Filename = row.ptr("filename")
+		file.ContentType = row.str("content_type")
+		if file.ContentType == "" {
+			file.ContentType = "application/octet-stream"
+		}
+		if t, ok := row.time("created_at"); ok {
+			file.CreatedAt = t
+		}
+		files = append(files, file)
+		ids = append(ids, id)
+	}
+
+	for _, row := range stored {
+		data, err := row.bytes("file_bytes")
+		if err != nil || len(data) == 0 {
+			imp.skip(e, "file %s has no readable content", row.str("id"))
+			continue
+		}
+		add(row, models.ActivityFile{Data: data})
+	}
+	for _, row := range uploaded {
+		url := row.ptr("cloudinary_url")
+		if url == nil {
+			imp.skip(e, "image %s has no Cloudinary URL", row.str("id"))
+			continue
+		}
+		add(row, models.ActivityFile{URL: url, PublicID: row.ptr("cloudinary_public_id")})
+	}
+
+	// Files can be large, so they are written a few at a time
+	if len(files) > 0 {
+		if err := imp.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"activity_id", "field_key", "filename", "content_type", "url", "public_id", "data"}),
+		}).CreateInBatches(&files, 10).Error; err != nil {
+			return fmt.Errorf("failed to import files: %w", err)
+		}
+	}
+	e.Imported = len(files)
+	e.target = countIn(&models.ActivityFile{}, "id", ids)
+	return nil
+}
+
+// importComments imports komentar, which the Next.js app kept per student
+// rather than per activity, as student comments
+func (imp *importer) importComments() error {
+	rows, err := imp.rows("komentar", false)
+	if err != nil {
+		return err
+	}
+	e := imp.entity("student_comments")
+	e.Source = len(rows)
+
+	var comments []models.StudentComment
+	var ids []uuid.UUID
+	for _, row := range rows {
+		id, err := uuid.Parse(row.str("komentarid"))
+		if err != nil {
+			imp.skip(e, "invalid komentarid %q", row.str("komentarid"))
+			continue
+		}
+		if _, deleted := row.time("deleteat"); deleted {
+			imp.skip(e, "%s was deleted", id)
+			continue
+		}
+		studentID, err := uuid.Parse(row.str("siswaid"))
+		if err != nil || !imp.users[studentID] {
+			imp.skip(e, "%s is about student %s, who is not imported", id, row.str("siswaid"))
+			continue
+		}
+		userID, err := uuid.Parse(row.str("userid"))
+		if err != nil || !imp.users[userID] {
+			imp.skip(e, "%s was written by user %s, who is not imported", id, row.str("userid"))
+			continue
+		}
+		content := row.str("content")
+		if content == "" {
+			imp.skip(e, "%s is empty", id)
+			continue
+		}
+
+		comment := models.StudentComment{ID: id, StudentID: studentID, UserProfileID: userID, Content: content}
+		if t, ok := row.time("created_at"); ok {
+			comment.CreatedAt = t
+		}
+		if t, ok := row.time("updated_at"); ok {
+			comment.UpdatedAt = t
+		}
+		comments = append(comments, comment)
+		ids = append(ids, id)
+	}
+	if err := imp.upsert(&comments, "content"); err != nil {
+		return fmt.Errorf("failed to import comments: %w", err)
+	}
+	e.Imported = len(comments)
+	e.target = countIn(&models.StudentComment{}, "id", ids)
+	return nil
+}
+
+// reconcile counts what the Go database holds for every imported record
+func (imp *importer) reconcile() error {
+	for _, e := range imp.report.Entities {
+		if e.target == nil {
+			continue
+		}
+		n, err := e.target(imp.tx)
+		if err != nil {
+			return fmt.Errorf("failed to reconcile %s: %w", e.Entity, err)
+		}
+		e.Target = n
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package legacy imports the data of the Supabase schema the Next.js app
+// used (user_profiles with userid/roleid/kelas, aktivitas and its field
+// value and file tables) into the Go models.
+package legacy
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ErrTableMissing is returned by a Source for a table it does not have
+var ErrTableMissing = errors.New("table not found")
+
+// Row is one legacy row as Postgres text values by column name. NULL
+// columns are left out.
+type Row map[string]string
+
+// Source reads the rows of a table of the legacy public schema
+type Source interface {
+	Rows(table string) ([]Row, error)
+}
+
+type dbSource struct {
+	db *gorm.DB
+}
+
+// OpenDB connects to the legacy database, e.g. the Supabase connection
+// string. Every column is read as text, so rows look the same as those of
+// a dump.
+func OpenDB(dsn string) (Source, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to legacy database: %w", err)
+	}
+	return &dbSource{db: db}, nil
+}
+
+func (s *dbSource) Rows(table string) ([]Row, error) {
+	var columns []string
+	if err := s.db.Raw(
+		"SELECT column_name FROM information_schema.columns WHERE table_schema = 'public' AND table_name = ? ORDER BY ordinal_position",
+		table,
+	).Scan(&columns).Error; err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, ErrTableMissing
+	}
+
+	selects := make([]string, len(columns))
+	for i, col := range columns {
+		selects[i] = quoteIdent(col) + "::text"
+	}
+	rows, err := s.db.Raw("SELECT " + strings.Join(selects, ", ") + " FROM public." + quoteIdent(table)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Row
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(Row, len(columns))
+		for i, v := range values {
+			if v.Valid {
+				row[columns[i]] = v.String
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// str returns the trimmed value of the column, empty for NULL
+func (r Row) str(col string) string {
+	return strings.TrimSpace(r[col])
+}
+
+// ptr returns the trimmed value of the column, nil for NULL or empty
+func (r Row) ptr(col string) *string {
+	v := r.str(col)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// bool reads a Postgres boolean, which is t/f in a dump and true/false
+// when cast to text
+func (r Row) bool(col string) bool {
+	switch strings.ToLower(r.str(col)) {
+	case "t", "true", "1", "yes", "y", "on":
+		return true
+	}
+	return false
+}
+
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// time parses a Postgres timestamp or date; ok is false for NULL or
+// unparseable values
+func (r Row) time(col string) (time.Time, bool) {
+	v := r.str(col)
+	if v == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// bytes decodes a bytea column in hex output format (\x0a1b...)
+func (r Row) bytes(col string) ([]byte, error) {
+	v, ok := r[col]
+	if !ok {
+		return nil, nil
+	}
+	if !strings.HasPrefix(v, `\x`) {
+		return nil, fmt.Errorf("column %s is not hex-encoded bytea", col)
+	}
+	return hex.DecodeString(v[2:])
+}
@@ -1,26 +1,55 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type AuthMiddleware struct {
-	jwtService *auth.JWTService
+	jwtService      *auth.JWTService
+	sessions        *auth.SessionStore
+	impersonations  *auth.ImpersonationStore
+	permissions     *auth.PermissionStore
+	adminRequireMFA bool
 }
 
-func NewAuthMiddleware(jwtService *auth.JWTService) *AuthMiddleware {
+func NewAuthMiddleware(jwtService *auth.JWTService, sessions *auth.SessionStore, impersonations *auth.ImpersonationStore, permissions *auth.PermissionStore, adminRequireMFA bool) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtService: jwtService,
+		jwtService:      jwtService,
+		sessions:        sessions,
+		impersonations:  impersonations,
+		permissions:     permissions,
+		adminRequireMFA: adminRequireMFA,
 	}
 }
 
-// Authenticate validates JWT token and sets user info in context
+// Authenticate validates JWT token, checks that its session is still active
+// and sets user info in context. The roles and their permissions come from
+// the database, so role changes and deletions apply to tokens that were
+// already issued. The active role is the one named in the token while the
+// user still holds it, otherwise the primary role; permissions are those of
+// every held role. Accounts that are not active and staff who still have to
+// set a password are turned away.
+// Impersonation tokens are read-only: any other method than GET, HEAD or
+// OPTIONS is refused and counted on the impersonation session.
 func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
+	return m.authenticate(false)
+}
+
+// AuthenticateAllowPasswordChange is Authenticate for the few routes a user
+// may call while a password change is pending (set password, me, logout)
+func (m *AuthMiddleware) AuthenticateAllowPasswordChange() gin.HandlerFunc {
+	return m.authenticate(true)
+}
+
+func (m *AuthMiddleware) authenticate(allowPasswordChange bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -44,25 +73,100 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 			return
 		}
 
+		var profile *models.UserProfile
+		if claims.Act != nil {
+			profile, err = m.impersonations.Validate(claims.SessionID, claims.UserID, claims.Act.UserID)
+		} else {
+			profile, err = m.sessions.Validate(claims.SessionID, claims.UserID)
+		}
+		if err != nil {
+			if errors.Is(err, auth.ErrSessionRevoked) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			}
+			c.Abort()
+			return
+		}
+
+		// Impersonation may look at an inactive account, e.g. to see what a
+		// suspended student saw before
+		if claims.Act == nil && !auth.AccountActive(profile) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":          "Account is not active",
+				"account_status": profile.Status,
+			})
+			c.Abort()
+			return
+		}
+
+		if !allowPasswordChange && auth.PasswordChangeRequired(profile) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                    "Password change required",
+				"password_change_required": true,
+			})
+			c.Abort()
+			return
+		}
+
+		roles, err := m.permissions.HeldRoles(profile)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			c.Abort()
+			return
+		}
+
+		permissions, err := m.permissions.ForRoles(roles)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			c.Abort()
+			return
+		}
+
 		// Set user info in context
-		c.Set("user_id", claims.UserID)
+		c.Set("user_id", profile.ID)
 		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
+		c.Set("user_role", auth.ActiveRole(claims.Role, roles))
+		c.Set("user_roles", roles)
+		c.Set("session_id", claims.SessionID)
+		c.Set("mfa", claims.MFA)
+		c.Set("permissions", permissions)
+
+		if claims.Act != nil {
+			c.Set("impersonator_id", claims.Act.UserID)
+			if !isReadOnlyMethod(c.Request.Method) {
+				if err := m.impersonations.RecordBlockedWrite(claims.SessionID); err != nil {
+					log.Printf("Warning: failed to record blocked write: %v", err)
+				}
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":         "Read-only while impersonating",
+					"impersonating": true,
+				})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
 
-// RequireRole checks if user has required role
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// RequireRole checks if user holds any of the roles, whichever one is active.
+// Prefer RequirePermission, so custom roles work without code changes.
 func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("user_role")
+		userRoles, exists := c.Get("user_roles")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 			c.Abort()
 			return
 		}
 
-		roleStr, ok := userRole.(string)
+		held, ok := userRoles.([]string)
 		if !ok {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid role type"})
 			c.Abort()
@@ -70,9 +174,11 @@ func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 		}
 
 		for _, role := range roles {
-			if roleStr == role {
-				c.Next()
-				return
+			for _, h := range held {
+				if h == role {
+					c.Next()
+					return
+				}
 			}
 		}
 
@@ -81,19 +187,39 @@ func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
 	}
 }
 
-// RequireAdmin checks if user is admin
-func (m *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
-	return m.RequireRole("admin")
-}
+// RequirePermission checks that the user's role grants the permission.
+// Administrative permissions also need a two-factor session unless that is
+// disabled in config.
+func (m *AuthMiddleware) RequirePermission(permission string) gin.HandlerFunc {
+	info, ok := auth.LookupPermission(permission)
+	if !ok {
+		panic("middleware: unknown permission " + permission)
+	}
 
-// RequireTeacher checks if user is teacher or admin
-func (m *AuthMiddleware) RequireTeacher() gin.HandlerFunc {
-	return m.RequireRole("admin", "guru", "guruwali")
-}
+	return func(c *gin.Context) {
+		if _, exists := c.Get("permissions"); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		if !HasPermission(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "permission": permission})
+			c.Abort()
+			return
+		}
+
+		if info.Administrative && m.adminRequireMFA && !HasMFA(c) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":        "Two-factor authentication required",
+				"mfa_required": true,
+			})
+			c.Abort()
+			return
+		}
 
-// RequireGuruWali checks if user is guruwali or admin
-func (m *AuthMiddleware) RequireGuruWali() gin.HandlerFunc {
-	return m.RequireRole("admin", "guruwali")
+		c.Next()
+	}
 }
 
 // GetUserID gets user ID from context
@@ -111,7 +237,57 @@ func GetUserID(c *gin.Context) (uuid.UUID, error) {
 	return uid, nil
 }
 
-// GetUserRole gets user role from context
+// GetSessionID gets the current session ID from context
+func GetSessionID(c *gin.Context) (uuid.UUID, error) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return uuid.Nil, auth.ErrInvalidToken
+	}
+
+	sid, ok := sessionID.(uuid.UUID)
+	if !ok {
+		return uuid.Nil, auth.ErrInvalidToken
+	}
+
+	return sid, nil
+}
+
+// HasMFA reports whether the current session passed two-factor authentication
+func HasMFA(c *gin.Context) bool {
+	return c.GetBool("mfa")
+}
+
+// HasPermission reports whether any of the current user's roles grants the
+// permission
+func HasPermission(c *gin.Context, permission string) bool {
+	return GetPermissions(c).Has(permission)
+}
+
+// GetPermissions gets the current user's permissions from context
+func GetPermissions(c *gin.Context) auth.PermissionSet {
+	permissions, _ := c.Get("permissions")
+	set, _ := permissions.(auth.PermissionSet)
+	return set
+}
+
+// GetImpersonatorID returns the admin behind an impersonation token
+func GetImpersonatorID(c *gin.Context) (uuid.UUID, bool) {
+	actor, exists := c.Get("impersonator_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := actor.(uuid.UUID)
+	return id, ok
+}
+
+// GetUserRoles gets every role the user holds, primary role first
+func GetUserRoles(c *gin.Context) []string {
+	roles, _ := c.Get("user_roles")
+	held, _ := roles.([]string)
+	return held
+}
+
+// GetUserRole gets the active user role from context
 func GetUserRole(c *gin.Context) (string, error) {
 	role, exists := c.Get("user_role")
 	if !exists {
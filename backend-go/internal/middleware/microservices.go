@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // ServiceToken middleware for microservices authentication
@@ -104,7 +105,5 @@ func RequestID() gin.HandlerFunc {
 }
 
 func generateRequestID() string {
-	// Simple implementation - in production, use UUID or similar
-	return strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(
-		http.TimeFormat, ":", ""), " ", "-"), ",", "")
+	return uuid.NewString()
 }
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/config"
+	"github.com/FirstTirr/G7KAIH-GO/internal/database"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RowLevelSecurity runs the rest of the request in a transaction that the
+// database's row-level security policies apply to, as the authenticated
+// user. Handlers get the transaction with DB. It commits unless the handler
+// answered with a server error. The response is held back until the commit
+// succeeded, so a write that is rolled back is answered with a 500 rather
+// than the handler's success. When RLS is disabled in config this does
+// nothing.
+func RowLevelSecurity(db *gorm.DB, cfg config.DatabaseConfig) gin.HandlerFunc {
+	if !cfg.RLSEnabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+		role, _ := GetUserRole(c)
+
+		tx := db.Begin()
+		if tx.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			c.Abort()
+			return
+		}
+		if err := database.SetRequestUser(tx, cfg.RLSRole, userID, role); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			c.Abort()
+			return
+		}
+
+		writer := c.Writer
+		buffer := &bufferedWriter{ResponseWriter: writer, status: http.StatusOK}
+		defer func() {
+			if r := recover(); r != nil {
+				c.Writer = writer
+				tx.Rollback()
+				panic(r)
+			}
+		}()
+
+		c.Writer = buffer
+		c.Set("db", tx)
+		c.Next()
+		c.Writer = writer
+
+		if buffer.status >= http.StatusInternalServerError {
+			tx.Rollback()
+			buffer.flush()
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			log.Printf("Warning: failed to commit request transaction: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		buffer.flush()
+	}
+}
+
+// bufferedWriter holds a response back until flush
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Flush does nothing; the response is only sent by flush
+func (w *bufferedWriter) Flush() {}
+
+// flush sends the held back response
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		log.Printf("Warning: failed to write response: %v", err)
+	}
+}
+
+// DB returns the request transaction started by RowLevelSecurity, or db when
+// there is none
+func DB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := c.Get("db"); ok {
+		if tx, ok := tx.(*gorm.DB); ok {
+			return tx
+		}
+	}
+	return db
+}
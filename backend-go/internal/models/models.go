@@ -8,12 +8,35 @@ import (
 )
 
 // UserProfile represents a user in the system
+
 type UserProfile struct {
-	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Name      string         `gorm:"not null" json:"name"`
-	Class     string         `json:"class"`
-	Role      string         `gorm:"not null;default:'siswa'" json:"role"` // admin, guru, guruwali, siswa, orangtua
-	TokenHash string         `gorm:"not null;uniqueIndex" json:"-"`
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Class     string    `json:"class"`
+	Role      string    `gorm:"not null;default:'siswa'" json:"role"` // primary role, see UserRole
+	Username  *string   `gorm:"uniqueIndex" json:"username,omitempty"`
+	NIS       *string   `gorm:"column:nis;uniqueIndex" json:"nis,omitempty"`
+	PINHash   *string   `json:"-"`
+	TokenHash *string   `gorm:"uniqueIndex" json:"-"` // legacy global 4-digit code
+
+	// Staff roles log in with a bcrypt password instead of the PIN
+	PasswordHash       *string    `json:"-"`
+	PasswordMustChange bool       `gorm:"not null;default:false" json:"password_must_change"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+
+	// TOTP two-factor authentication. The secret is encrypted; TOTPEnabledAt
+	// is nil while enrollment is still pending.
+	TOTPSecret    *string    `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at,omitempty"`
+	TOTPLastStep  *int64     `gorm:"column:totp_last_step" json:"-"`
+
+	// Account status (active, suspended, graduated, transferred). Anything but
+	// active blocks login without deleting the account's history.
+	Status          string     `gorm:"not null;default:'active'" json:"status"`
+	StatusReason    *string    `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	StatusChangedBy *uuid.UUID `gorm:"type:uuid" json:"status_changed_by,omitempty"`
+
 	AvatarURL *string        `json:"avatar_url,omitempty"`
 	Bio       *string        `json:"bio,omitempty"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -51,6 +74,7 @@ type Kegiatan struct {
 	Description *string        `gorm:"type:text" json:"description,omitempty"`
 	CategoryID  uuid.UUID      `gorm:"type:uuid;not null" json:"category_id"`
 	FormSchema  *string        `gorm:"type:jsonb" json:"form_schema,omitempty"` // JSON schema for dynamic forms
+	FormVersion int            `gorm:"not null" json:"form_version"`            // current version of FormSchema, 0 without a form
 	IsActive    bool           `gorm:"default:true" json:"is_active"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -61,23 +85,65 @@ type Kegiatan struct {
 	Activities []Activity `gorm:"foreignKey:KegiatanID" json:"activities,omitempty"`
 }
 
+// KegiatanFormVersion is a revision of a kegiatan's form_schema. Versions
+// are never changed; saving a different form adds the next one.
+type KegiatanFormVersion struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	KegiatanID uuid.UUID  `gorm:"type:uuid;not null" json:"kegiatan_id"`
+	Version    int        `gorm:"not null" json:"version"`
+	FormSchema *string    `gorm:"type:jsonb" json:"form_schema,omitempty"`
+	CreatedBy  *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
 // Activity represents user activities
 type Activity struct {
 	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	UserProfileID uuid.UUID      `gorm:"type:uuid;not null" json:"user_profile_id"`
 	KegiatanID    uuid.UUID      `gorm:"type:uuid;not null" json:"kegiatan_id"`
 	Date          time.Time      `gorm:"not null;index:idx_user_date" json:"date"`
-	FormData      *string        `gorm:"type:jsonb" json:"form_data,omitempty"` // JSON data from dynamic forms
-	Status        string         `gorm:"default:'pending'" json:"status"`        // pending, approved, rejected
+	FormData      *string        `gorm:"type:jsonb" json:"form_data,omitempty"`      // JSON data from dynamic forms
+	FormVersionID *uuid.UUID     `gorm:"type:uuid" json:"form_version_id,omitempty"` // form version FormData was submitted against
+	Status        string         `gorm:"default:'pending'" json:"status"`            // pending, approved, rejected
 	Notes         *string        `gorm:"type:text" json:"notes,omitempty"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Relations
-	UserProfile *UserProfile `gorm:"foreignKey:UserProfileID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user_profile,omitempty"`
-	Kegiatan    *Kegiatan    `gorm:"foreignKey:KegiatanID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"kegiatan,omitempty"`
-	Comments    []Comment    `gorm:"foreignKey:ActivityID" json:"comments,omitempty"`
+	UserProfile *UserProfile              `gorm:"foreignKey:UserProfileID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user_profile,omitempty"`
+	Kegiatan    *Kegiatan                 `gorm:"foreignKey:KegiatanID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"kegiatan,omitempty"`
+	Form        *KegiatanFormVersion      `gorm:"foreignKey:FormVersionID" json:"form,omitempty"`
+	Comments    []Comment                 `gorm:"foreignKey:ActivityID" json:"comments,omitempty"`
+	Files       []ActivityFile            `gorm:"foreignKey:ActivityID" json:"files,omitempty"`
+	Validations []ActivityFieldValidation `gorm:"foreignKey:ActivityID" json:"validations,omitempty"`
+}
+
+// ActivityFieldValidation records that a teacher and/or parent confirmed
+// one field of an activity's form_data
+type ActivityFieldValidation struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ActivityID         uuid.UUID `gorm:"type:uuid;not null" json:"activity_id"`
+	FieldKey           string    `gorm:"not null" json:"field_key"`
+	ValidatedByTeacher bool      `gorm:"not null;default:false" json:"validated_by_teacher"`
+	ValidatedByParent  bool      `gorm:"not null;default:false" json:"validated_by_parent"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// ActivityFile is a file submitted for a form field: either uploaded to
+// Cloudinary (URL) or stored in the database (Data)
+type ActivityFile struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ActivityID  uuid.UUID `gorm:"type:uuid;not null" json:"activity_id"`
+	FieldKey    string    `gorm:"not null" json:"field_key"`
+	Filename    *string   `json:"filename,omitempty"`
+	ContentType string    `gorm:"not null" json:"content_type"`
+	URL         *string   `gorm:"column:url" json:"url,omitempty"`
+	PublicID    *string   `json:"public_id,omitempty"`
+	Data        []byte    `gorm:"type:bytea" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // Comment represents comments on activities
@@ -95,6 +161,22 @@ type Comment struct {
 	UserProfile *UserProfile `gorm:"foreignKey:UserProfileID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user_profile,omitempty"`
 }
 
+// StudentComment is a note a teacher or parent leaves about a student as a
+// whole rather than on one activity (komentar in the Next.js app)
+type StudentComment struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	StudentID     uuid.UUID      `gorm:"type:uuid;not null" json:"student_id"`
+	UserProfileID uuid.UUID      `gorm:"type:uuid;not null" json:"user_profile_id"`
+	Content       string         `gorm:"type:text;not null" json:"content"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Relations
+	Student     *UserProfile `gorm:"foreignKey:StudentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"student,omitempty"`
+	UserProfile *UserProfile `gorm:"foreignKey:UserProfileID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"user_profile,omitempty"`
+}
+
 // TeacherRole represents teacher assignments to classes
 type TeacherRole struct {
 	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -137,16 +219,205 @@ type ParentStudent struct {
 	Student *UserProfile `gorm:"foreignKey:StudentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"student,omitempty"`
 }
 
-// SubmissionWindow represents the time window for activity submissions
-type SubmissionWindow struct {
+// SubmissionRule sets the submission hours of one weekday (0 = Sunday). A
+// close time before the open time runs past midnight; no times means all
+// day. Class ("7A", or "XII*" for a grade) and KegiatanID narrow the rule.
+type SubmissionRule struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Weekday    int        `gorm:"not null" json:"weekday"`
+	Closed     bool       `gorm:"not null" json:"closed"`
+	OpenTime   *string    `json:"open_time"`  // Format: "HH:MM"
+	CloseTime  *string    `json:"close_time"` // Format: "HH:MM"
+	Class      *string    `json:"class"`
+	KegiatanID *uuid.UUID `gorm:"type:uuid" json:"kegiatan_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// SubmissionException replaces the weekly rules from StartDate to EndDate
+// (until removed when nil), e.g. closed on a national holiday or for XII
+// classes during exams. Scoped like SubmissionRule.
+type SubmissionException struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name       string     `gorm:"not null" json:"name"`
+	StartDate  time.Time  `gorm:"type:date;not null" json:"start_date"`
+	EndDate    *time.Time `gorm:"type:date" json:"end_date"`
+	Closed     bool       `gorm:"not null" json:"closed"`
+	OpenTime   *string    `json:"open_time"`  // Format: "HH:MM"
+	CloseTime  *string    `json:"close_time"` // Format: "HH:MM"
+	Class      *string    `json:"class"`
+	KegiatanID *uuid.UUID `gorm:"type:uuid" json:"kegiatan_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Role is a named set of permissions. Built-in roles (admin, guru, guruwali,
+// siswa, orangtua) cannot be deleted; schools can add their own.
+type Role struct {
+	Name        string    `gorm:"primaryKey;size:50" json:"name"`
+	DisplayName string    `gorm:"not null" json:"display_name"`
+	Description *string   `json:"description,omitempty"`
+	BuiltIn     bool      `gorm:"not null;default:false" json:"built_in"`
+	Permissions []string  `gorm:"-" json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RolePermission grants a permission from the registry to a role
+type RolePermission struct {
+	RoleName   string `gorm:"primaryKey;size:50" json:"role_name"`
+	Permission string `gorm:"primaryKey;size:100" json:"permission"`
+}
+
+// UserRole gives a profile a role in addition to its primary Role, e.g. a
+// guru who is also a parent at the school
+type UserRole struct {
+	UserProfileID uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_profile_id"`
+	RoleName      string    `gorm:"primaryKey;size:50" json:"role_name"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RecoveryCode is a one-time fallback for a lost TOTP device
+type RecoveryCode struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserProfileID uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_profile_id"`
+	CodeHash      string     `gorm:"not null" json:"-"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// QRLoginToken is the one-time credential printed on a QR login card. Issuing
+// a new card revokes the user's unused ones.
+type QRLoginToken struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserProfileID uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_profile_id"`
+	TokenHash     string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy     *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// InviteCode lets someone sign up with a fixed role (and optionally class).
+// A code can be redeemed MaxUses times before it expires or is revoked.
+type InviteCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code      string     `gorm:"uniqueIndex;not null" json:"code"`
+	Role      string     `gorm:"not null" json:"role"`
+	Class     *string    `json:"class,omitempty"`
+	MaxUses   int        `gorm:"not null;default:1" json:"max_uses"`
+	UsedCount int        `gorm:"not null;default:0" json:"used_count"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Note      *string    `json:"note,omitempty"`
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// RegistrationSettings controls which roles may sign up without an invite code.
+// OpenRoles is a comma-separated list; empty means signup is invite-only.
+type RegistrationSettings struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	IsOpen    bool      `gorm:"default:true" json:"is_open"`
-	OpenTime  *string   `json:"open_time,omitempty"`  // Format: "HH:MM"
-	CloseTime *string   `json:"close_time,omitempty"` // Format: "HH:MM"
+	OpenRoles string    `gorm:"not null;default:''" json:"open_roles"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// LoginAttempt records every call to the login endpoint
+type LoginAttempt struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	IPAddress     string     `gorm:"not null" json:"ip_address"`
+	Fingerprint   string     `gorm:"not null" json:"fingerprint"`
+	UserAgent     *string    `json:"user_agent,omitempty"`
+	UserProfileID *uuid.UUID `gorm:"type:uuid" json:"user_profile_id,omitempty"`
+	Success       bool       `gorm:"not null" json:"success"`
+	Reason        *string    `json:"reason,omitempty"` // invalid_credentials, locked, account_<status>
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// LoginLockout tracks consecutive login failures for one IP, device
+// fingerprint or account
+type LoginLockout struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Scope         string     `gorm:"not null" json:"scope"` // ip, fingerprint, account
+	Identifier    string     `gorm:"not null" json:"identifier"`
+	FailureCount  int        `gorm:"not null;default:0" json:"failure_count"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// UserSession is one logged-in device. Access tokens carry the session ID and
+// stop working as soon as the session is revoked.
+type UserSession struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserProfileID uuid.UUID  `gorm:"type:uuid;not null" json:"user_profile_id"`
+	UserAgent     *string    `json:"user_agent,omitempty"`
+	IPAddress     *string    `json:"ip_address,omitempty"`
+	MFA           bool       `gorm:"column:mfa;not null;default:false" json:"mfa"`
+	ActiveRole    *string    `json:"active_role,omitempty"` // nil means the primary role
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// RefreshToken is a one-time refresh token. Every rotation stores a new token
+// for the same session; presenting an already rotated token revokes the session.
+type RefreshToken struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserProfileID uuid.UUID  `gorm:"type:uuid;not null" json:"user_profile_id"`
+	SessionID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	TokenHash     string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID  *uuid.UUID `gorm:"type:uuid" json:"replaced_by_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// ImpersonationSession records an admin viewing the app as another user.
+// The impersonation token names it as its session, so ending it or revoking
+// the admin's own session stops the token at once.
+type ImpersonationSession struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ActorID        uuid.UUID    `gorm:"type:uuid;not null" json:"actor_id"`
+	ActorSessionID uuid.UUID    `gorm:"type:uuid;not null" json:"actor_session_id"`
+	TargetID       uuid.UUID    `gorm:"type:uuid;not null" json:"target_id"`
+	Reason         string       `gorm:"not null" json:"reason"`
+	UserAgent      *string      `json:"user_agent,omitempty"`
+	IPAddress      *string      `json:"ip_address,omitempty"`
+	ExpiresAt      time.Time    `gorm:"not null" json:"expires_at"`
+	EndedAt        *time.Time   `json:"ended_at,omitempty"`
+	BlockedWrites  int          `gorm:"not null;default:0" json:"blocked_writes"` // write requests refused during the session
+	CreatedAt      time.Time    `json:"created_at"`
+	Actor          *UserProfile `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	Target         *UserProfile `gorm:"foreignKey:TargetID" json:"target,omitempty"`
+}
+
+// AuditLog is one entry of the append-only audit trail of administrative and
+// review actions. Changes holds the fields that changed as
+// {"field": {"before": ..., "after": ...}}.
+type AuditLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ActorID    *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"`
+	Action     string     `gorm:"not null" json:"action"`
+	TargetType *string    `json:"target_type,omitempty"`
+	TargetID   *string    `json:"target_id,omitempty"`
+	Changes    *string    `gorm:"type:jsonb" json:"changes,omitempty"`
+	Details    *string    `gorm:"type:jsonb" json:"details,omitempty"`
+	Method     string     `gorm:"not null" json:"method"`
+	Path       string     `gorm:"not null" json:"path"`
+	StatusCode int        `gorm:"not null" json:"status_code"`
+	IPAddress  *string    `json:"ip_address,omitempty"`
+	UserAgent  *string    `json:"user_agent,omitempty"`
+	RequestID  *string    `json:"request_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
 // TableName overrides
 func (UserProfile) TableName() string {
 	return "user_profiles"
@@ -160,6 +431,10 @@ func (Kegiatan) TableName() string {
 	return "kegiatan"
 }
 
+func (KegiatanFormVersion) TableName() string {
+	return "kegiatan_form_versions"
+}
+
 func (Activity) TableName() string {
 	return "activities"
 }
@@ -168,6 +443,18 @@ func (Comment) TableName() string {
 	return "comments"
 }
 
+func (StudentComment) TableName() string {
+	return "student_comments"
+}
+
+func (ActivityFieldValidation) TableName() string {
+	return "activity_field_validations"
+}
+
+func (ActivityFile) TableName() string {
+	return "activity_files"
+}
+
 func (TeacherRole) TableName() string {
 	return "teacher_roles"
 }
@@ -180,6 +467,62 @@ func (ParentStudent) TableName() string {
 	return "parent_students"
 }
 
-func (SubmissionWindow) TableName() string {
-	return "submission_windows"
-}
\ No newline at end of file
+func (SubmissionRule) TableName() string {
+	return "submission_rules"
+}
+
+func (SubmissionException) TableName() string {
+	return "submission_exceptions"
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+func (UserRole) TableName() string {
+	return "user_roles"
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+func (QRLoginToken) TableName() string {
+	return "qr_login_tokens"
+}
+
+func (InviteCode) TableName() string {
+	return "invite_codes"
+}
+
+func (RegistrationSettings) TableName() string {
+	return "registration_settings"
+}
+
+func (LoginLockout) TableName() string {
+	return "login_lockouts"
+}
+
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+func (ImpersonationSession) TableName() string {
+	return "impersonation_sessions"
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
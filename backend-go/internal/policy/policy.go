@@ -0,0 +1,225 @@
+// Package policy decides whether a user may act on another user's data. The
+// answers come from the user's permissions together with the relationships
+// that grant access to students: the classes a teacher teaches
+// (teacher_roles), the students a guru wali supervises (guruwali_assignments)
+// and the children linked to a parent (parent_students).
+package policy
+
+import (
+	"strings"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StudentRole is the role whose accounts class teachers can see
+const StudentRole = "siswa"
+
+// Action is something a subject wants to do with a resource
+type Action string
+
+const (
+	ActionView    Action = "view"
+	ActionUpdate  Action = "update"
+	ActionReview  Action = "review" // change the status of an activity
+	ActionDelete  Action = "delete"
+	ActionComment Action = "comment"
+)
+
+// Subject is the user a decision is made for
+type Subject struct {
+	UserID      uuid.UUID
+	Permissions auth.PermissionSet
+	Classes     map[string]bool    // classes taught, from teacher_roles
+	Supervised  map[uuid.UUID]bool // students supervised as guru wali
+	Children    map[uuid.UUID]bool // linked children
+}
+
+// Load builds the subject for a user. Relationships are only read when a
+// permission makes use of them.
+func Load(db *gorm.DB, userID uuid.UUID, permissions auth.PermissionSet) (*Subject, error) {
+	s := &Subject{
+		UserID:      userID,
+		Permissions: permissions,
+		Classes:     map[string]bool{},
+		Supervised:  map[uuid.UUID]bool{},
+		Children:    map[uuid.UUID]bool{},
+	}
+
+	if permissions.Has(auth.PermReportViewClass) {
+		var classes []string
+		if err := db.Model(&models.TeacherRole{}).Where("teacher_id = ?", userID).Pluck("class_name", &classes).Error; err != nil {
+			return nil, err
+		}
+		for _, class := range classes {
+			s.Classes[class] = true
+		}
+	}
+
+	if permissions.Has(auth.PermReportViewSupervised) {
+		var ids []uuid.UUID
+		if err := db.Model(&models.GuruWaliAssignment{}).Where("teacher_id = ?", userID).Pluck("student_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			s.Supervised[id] = true
+		}
+	}
+
+	if permissions.Has(auth.PermChildView) {
+		var ids []uuid.UUID
+		if err := db.Model(&models.ParentStudent{}).Where("parent_id = ?", userID).Pluck("student_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			s.Children[id] = true
+		}
+	}
+
+	return s, nil
+}
+
+// Teaches reports whether the subject teaches the class
+func (s *Subject) Teaches(class string) bool {
+	return class != "" && s.Permissions.Has(auth.PermReportViewClass) && s.Classes[class]
+}
+
+// Supervises reports whether the subject is guru wali of the student
+func (s *Subject) Supervises(studentID uuid.UUID) bool {
+	return s.Permissions.Has(auth.PermReportViewSupervised) && s.Supervised[studentID]
+}
+
+// ParentOf reports whether the student is linked to the subject as a child
+func (s *Subject) ParentOf(studentID uuid.UUID) bool {
+	return s.Permissions.Has(auth.PermChildView) && s.Children[studentID]
+}
+
+// CanSeeUser reports whether the subject may see a user and their activities:
+// themselves, every student with student.view.all, students of the classes
+// they teach, students they supervise and their children.
+func (s *Subject) CanSeeUser(user *models.UserProfile) bool {
+	if user == nil {
+		return false
+	}
+	if user.ID == s.UserID {
+		return true
+	}
+	if user.Role == StudentRole && (s.Permissions.Has(auth.PermStudentViewAll) || s.Teaches(user.Class)) {
+		return true
+	}
+	return s.Supervises(user.ID) || s.ParentOf(user.ID)
+}
+
+// Can answers whether the subject may perform the action on the resource.
+// Resources are *models.UserProfile, *models.Activity with UserProfile
+// loaded, *models.Comment with Activity.UserProfile loaded and
+// *models.StudentComment with Student loaded; anything else is refused.
+// Commenting on a user means writing a student comment about them, which
+// users cannot do about themselves.
+func (s *Subject) Can(action Action, resource interface{}) bool {
+	switch r := resource.(type) {
+	case *models.UserProfile:
+		switch action {
+		case ActionView:
+			return s.CanSeeUser(r)
+		case ActionComment:
+			return r.ID != s.UserID && s.CanSeeUser(r)
+		}
+
+	case *models.Activity:
+		owner := r.UserProfileID == s.UserID
+		switch action {
+		case ActionView, ActionComment:
+			return owner || s.CanSeeUser(r.UserProfile)
+		case ActionReview:
+			return s.Permissions.Has(auth.PermActivityReview) && s.CanSeeUser(r.UserProfile)
+		case ActionUpdate:
+			return owner || s.Can(ActionReview, r)
+		case ActionDelete:
+			return owner || s.Permissions.Has(auth.PermActivityDeleteAny)
+		}
+
+	case *models.Comment:
+		switch action {
+		case ActionView:
+			return s.Can(ActionView, r.Activity)
+		case ActionUpdate, ActionDelete:
+			return r.UserProfileID == s.UserID || s.Permissions.Has(auth.PermCommentModerate)
+		}
+
+	case *models.StudentComment:
+		switch action {
+		case ActionView:
+			return s.CanSeeUser(r.Student)
+		case ActionUpdate, ActionDelete:
+			return r.UserProfileID == s.UserID || s.Permissions.Has(auth.PermCommentModerate)
+		}
+	}
+	return false
+}
+
+// ScopeUsers limits a user_profiles query to the users CanSeeUser allows
+func (s *Subject) ScopeUsers(db *gorm.DB) *gorm.DB {
+	return s.scope(db, "user_profiles.id")
+}
+
+// ScopeActivities limits an activities query to the activities of users
+// CanSeeUser allows
+func (s *Subject) ScopeActivities(db *gorm.DB) *gorm.DB {
+	return s.scope(db, "activities.user_profile_id")
+}
+
+// scope adds the CanSeeUser rules as a condition on the column holding the
+// user ID
+func (s *Subject) scope(db *gorm.DB, column string) *gorm.DB {
+	students := "SELECT id FROM user_profiles WHERE deleted_at IS NULL AND role = ?"
+	if s.Permissions.Has(auth.PermStudentViewAll) {
+		return db.Where("("+column+" = ? OR "+column+" IN ("+students+"))", s.UserID, StudentRole)
+	}
+
+	conditions := []string{column + " = ?"}
+	args := []interface{}{s.UserID}
+
+	if classes := s.teachingClasses(); len(classes) > 0 {
+		conditions = append(conditions, column+" IN ("+students+" AND class IN ?)")
+		args = append(args, StudentRole, classes)
+	}
+	if ids := linkedIDs(s.Supervised, s.Permissions.Has(auth.PermReportViewSupervised)); len(ids) > 0 {
+		conditions = append(conditions, column+" IN ?")
+		args = append(args, ids)
+	}
+	if ids := linkedIDs(s.Children, s.Permissions.Has(auth.PermChildView)); len(ids) > 0 {
+		conditions = append(conditions, column+" IN ?")
+		args = append(args, ids)
+	}
+
+	return db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+func (s *Subject) teachingClasses() []string {
+	if !s.Permissions.Has(auth.PermReportViewClass) {
+		return nil
+	}
+	classes := make([]string, 0, len(s.Classes))
+	for class, ok := range s.Classes {
+		if ok {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
+func linkedIDs(set map[uuid.UUID]bool, allowed bool) []uuid.UUID {
+	if !allowed {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(set))
+	for id, ok := range set {
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
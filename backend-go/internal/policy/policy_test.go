@@ -0,0 +1,228 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var (
+	studentA   = &models.UserProfile{ID: uuid.New(), Role: "siswa", Class: "7A"}
+	studentB   = &models.UserProfile{ID: uuid.New(), Role: "siswa", Class: "7B"}
+	otherGuru  = &models.UserProfile{ID: uuid.New(), Role: "guru", Class: "7A"}
+	teacherID  = uuid.New()
+	guruWaliID = uuid.New()
+	parentID   = uuid.New()
+	adminID    = uuid.New()
+	bkID       = uuid.New()
+)
+
+func permissions(names ...string) auth.PermissionSet {
+	set := auth.PermissionSet{}
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+func allPermissions() auth.PermissionSet {
+	set := auth.PermissionSet{}
+	for _, p := range auth.Permissions() {
+		set[p.Name] = struct{}{}
+	}
+	return set
+}
+
+// subjects covers every built-in role plus a custom BK counselor role
+func subjects() map[string]*Subject {
+	return map[string]*Subject{
+		"siswa": {
+			UserID:      studentA.ID,
+			Permissions: permissions(),
+		},
+		"orangtua": {
+			UserID:      parentID,
+			Permissions: permissions(auth.PermChildView),
+			Children:    map[uuid.UUID]bool{studentA.ID: true},
+		},
+		"guru": {
+			UserID:      teacherID,
+			Permissions: permissions(auth.PermActivityReview, auth.PermReportViewClass),
+			Classes:     map[string]bool{"7A": true},
+		},
+		"guruwali": {
+			UserID:      guruWaliID,
+			Permissions: permissions(auth.PermActivityReview, auth.PermReportViewClass, auth.PermReportViewSupervised),
+			Supervised:  map[uuid.UUID]bool{studentB.ID: true},
+		},
+		"bk": {
+			UserID:      bkID,
+			Permissions: permissions(auth.PermActivityReview, auth.PermStudentViewAll),
+		},
+		"admin": {
+			UserID:      adminID,
+			Permissions: allPermissions(),
+		},
+		// links without the permission that uses them grant nothing
+		"guru without permission": {
+			UserID:      teacherID,
+			Permissions: permissions(),
+			Classes:     map[string]bool{"7A": true},
+		},
+	}
+}
+
+func activityOf(owner *models.UserProfile) *models.Activity {
+	return &models.Activity{ID: uuid.New(), UserProfileID: owner.ID, UserProfile: owner}
+}
+
+func commentOn(activity *models.Activity, author uuid.UUID) *models.Comment {
+	return &models.Comment{ID: uuid.New(), ActivityID: activity.ID, UserProfileID: author, Activity: activity}
+}
+
+func studentCommentOn(student *models.UserProfile, author uuid.UUID) *models.StudentComment {
+	return &models.StudentComment{ID: uuid.New(), StudentID: student.ID, UserProfileID: author, Student: student}
+}
+
+func TestCan(t *testing.T) {
+	activityA := activityOf(studentA)
+	activityB := activityOf(studentB)
+
+	tests := []struct {
+		subject  string
+		action   Action
+		resource interface{}
+		want     bool
+	}{
+		// students only reach their own data
+		{"siswa", ActionView, studentA, true},
+		{"siswa", ActionView, studentB, false},
+		{"siswa", ActionView, activityA, true},
+		{"siswa", ActionView, activityB, false},
+		{"siswa", ActionUpdate, activityA, true},
+		{"siswa", ActionReview, activityA, false},
+		{"siswa", ActionDelete, activityA, true},
+		{"siswa", ActionDelete, activityB, false},
+		{"siswa", ActionComment, activityB, false},
+		{"siswa", ActionView, commentOn(activityB, studentB.ID), false},
+		{"siswa", ActionUpdate, commentOn(activityA, studentA.ID), true},
+		{"siswa", ActionDelete, commentOn(activityA, teacherID), false},
+
+		// parents see their linked children only
+		{"orangtua", ActionView, studentA, true},
+		{"orangtua", ActionView, studentB, false},
+		{"orangtua", ActionView, activityA, true},
+		{"orangtua", ActionComment, activityA, true},
+		{"orangtua", ActionView, activityB, false},
+		{"orangtua", ActionUpdate, activityA, false},
+		{"orangtua", ActionReview, activityA, false},
+		{"orangtua", ActionDelete, activityA, false},
+		{"orangtua", ActionView, commentOn(activityA, teacherID), true},
+
+		// class teachers see and review students of their classes
+		{"guru", ActionView, studentA, true},
+		{"guru", ActionView, studentB, false},
+		{"guru", ActionView, otherGuru, false},
+		{"guru", ActionView, activityA, true},
+		{"guru", ActionReview, activityA, true},
+		{"guru", ActionUpdate, activityA, true},
+		{"guru", ActionView, activityB, false},
+		{"guru", ActionReview, activityB, false},
+		{"guru", ActionDelete, activityA, false},
+		{"guru", ActionComment, activityA, true},
+		{"guru", ActionUpdate, commentOn(activityA, studentA.ID), false},
+
+		// guru wali see the students assigned to them
+		{"guruwali", ActionView, studentB, true},
+		{"guruwali", ActionView, studentA, false},
+		{"guruwali", ActionReview, activityB, true},
+		{"guruwali", ActionView, activityA, false},
+
+		// student.view.all reaches every student but not other staff
+		{"bk", ActionView, studentA, true},
+		{"bk", ActionView, studentB, true},
+		{"bk", ActionView, otherGuru, false},
+		{"bk", ActionReview, activityB, true},
+		{"bk", ActionDelete, activityB, false},
+
+		// admin holds every permission
+		{"admin", ActionView, activityB, true},
+		{"admin", ActionReview, activityB, true},
+		{"admin", ActionDelete, activityB, true},
+		{"admin", ActionDelete, commentOn(activityB, studentB.ID), true},
+
+		// comments about a student follow who may see the student
+		{"siswa", ActionComment, studentA, false},
+		{"siswa", ActionView, studentCommentOn(studentA, teacherID), true},
+		{"siswa", ActionDelete, studentCommentOn(studentA, teacherID), false},
+		{"orangtua", ActionComment, studentA, true},
+		{"orangtua", ActionComment, studentB, false},
+		{"guru", ActionComment, studentA, true},
+		{"guru", ActionView, studentCommentOn(studentB, guruWaliID), false},
+		{"guru", ActionDelete, studentCommentOn(studentA, teacherID), true},
+		{"admin", ActionDelete, studentCommentOn(studentB, teacherID), true},
+
+		{"guru without permission", ActionView, studentA, false},
+		{"guru without permission", ActionView, activityA, false},
+
+		// unknown actions and resources are refused, and so is an activity
+		// whose owner was not loaded unless it is the subject's own
+		{"admin", ActionDelete, studentA, false},
+		{"admin", ActionView, "activity", false},
+		{"admin", ActionView, &models.Activity{UserProfileID: studentA.ID}, false},
+		{"siswa", ActionView, &models.Activity{UserProfileID: studentA.ID}, true},
+	}
+
+	all := subjects()
+	for _, tt := range tests {
+		subject := all[tt.subject]
+		if got := subject.Can(tt.action, tt.resource); got != tt.want {
+			t.Errorf("%s %s %T: got %v, want %v", tt.subject, tt.action, tt.resource, got, tt.want)
+		}
+	}
+}
+
+func TestScope(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		subject string
+		want    []string
+		notWant []string
+	}{
+		{"siswa", []string{"activities.user_profile_id = "}, []string{"class IN", "OR"}},
+		{"orangtua", []string{"OR activities.user_profile_id IN "}, []string{"class IN"}},
+		{"guru", []string{"AND class IN "}, nil},
+		{"guruwali", []string{"OR activities.user_profile_id IN "}, []string{"class IN"}},
+		{"bk", []string{"SELECT id FROM user_profiles WHERE deleted_at IS NULL AND role = "}, []string{"class IN"}},
+		{"guru without permission", nil, []string{"class IN", "OR"}},
+	}
+
+	all := subjects()
+	for _, tt := range tests {
+		var activities []models.Activity
+		stmt := all[tt.subject].ScopeActivities(db.Model(&models.Activity{})).Find(&activities).Statement
+		sql := stmt.SQL.String()
+		for _, s := range tt.want {
+			if !strings.Contains(sql, s) {
+				t.Errorf("%s: %q does not contain %q", tt.subject, sql, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(sql, s) {
+				t.Errorf("%s: %q should not contain %q", tt.subject, sql, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package printables
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QRCard is one QR login card
+type QRCard struct {
+	Name     string
+	Class    string
+	Username string
+	Payload  string
+}
+
+const (
+	cardColumns = 3
+	cardRows    = 4
+)
+
+// QRPNG renders the payload as a PNG image of size x size pixels
+func QRPNG(payload string, size int) ([]byte, error) {
+	return qrcode.Encode(payload, qrcode.Medium, size)
+}
+
+// QRSVG renders the payload as a scalable SVG image
+func QRSVG(payload string) ([]byte, error) {
+	q, err := qrcode.New(payload, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := q.Bitmap()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, len(bitmap), len(bitmap))
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&sb, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	sb.WriteString(`"/></svg>`)
+
+	return []byte(sb.String()), nil
+}
+
+// WriteQRCards renders the cards as an A4 PDF, twelve per page, separated by
+// dashed cut lines
+func WriteQRCards(w io.Writer, title string, cards []QRCard) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageW, pageH := pdf.GetPageSize()
+	cardW := (pageW - 2*pageMargin) / cardColumns
+	cardH := (pageH - 2*pageMargin) / cardRows
+	perPage := cardColumns * cardRows
+	qrSize := cardW - 16
+
+	if len(cards) == 0 {
+		pdf.AddPage()
+	}
+
+	for i, card := range cards {
+		if i%perPage == 0 {
+			pdf.AddPage()
+			drawGrid(pdf, cardColumns, cardRows, cardW, cardH)
+		}
+
+		n := i % perPage
+		x := pageMargin + float64(n%cardColumns)*cardW
+		y := pageMargin + float64(n/cardColumns)*cardH
+
+		png, err := QRPNG(card.Payload, 512)
+		if err != nil {
+			return err
+		}
+		imageName := fmt.Sprintf("qr%d", i)
+		pdf.RegisterImageOptionsReader(imageName, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+		pdf.ImageOptions(imageName, x+8, y+4, qrSize, qrSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+		pdf.SetXY(x+3, y+6+qrSize)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(cardW-6, 5, tr(card.Name), "", 2, "C", false, 0, "")
+		pdf.SetX(x + 3)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(cardW-6, 5, tr("Kelas "+card.Class), "", 2, "C", false, 0, "")
+		pdf.SetX(x + 3)
+		pdf.SetFont("Courier", "", fitFontSize(pdf, "Courier", card.Username, 9, cardW-6))
+		pdf.CellFormat(cardW-6, 5, card.Username, "", 2, "C", false, 0, "")
+		pdf.SetX(x + 3)
+		pdf.SetFont("Helvetica", "I", 6)
+		pdf.CellFormat(cardW-6, 4, tr(title), "", 2, "C", false, 0, "")
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
@@ -0,0 +1,107 @@
+package printables
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// TokenSlip is one cut-out login slip
+type TokenSlip struct {
+	Name     string
+	Class    string
+	Username string
+	PIN      string
+}
+
+const (
+	slipColumns = 2
+	slipRows    = 5
+	pageMargin  = 10.0
+)
+
+// WriteTokenSlips renders the slips as an A4 PDF, ten per page, separated by
+// dashed cut lines
+func WriteTokenSlips(w io.Writer, title string, slips []TokenSlip) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageW, pageH := pdf.GetPageSize()
+	slipW := (pageW - 2*pageMargin) / slipColumns
+	slipH := (pageH - 2*pageMargin) / slipRows
+	perPage := slipColumns * slipRows
+	printed := time.Now().Format("02-01-2006")
+
+	if len(slips) == 0 {
+		pdf.AddPage()
+	}
+
+	for i, slip := range slips {
+		if i%perPage == 0 {
+			pdf.AddPage()
+			drawGrid(pdf, slipColumns, slipRows, slipW, slipH)
+		}
+
+		n := i % perPage
+		x := pageMargin + float64(n%slipColumns)*slipW
+		y := pageMargin + float64(n/slipColumns)*slipH
+
+		pdf.SetXY(x+5, y+6)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(slipW-10, 5, tr(title), "", 2, "L", false, 0, "")
+
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.SetX(x + 5)
+		pdf.CellFormat(slipW-10, 6, tr(fmt.Sprintf("Nama  : %s", slip.Name)), "", 2, "L", false, 0, "")
+		pdf.SetX(x + 5)
+		pdf.CellFormat(slipW-10, 6, tr(fmt.Sprintf("Kelas : %s", slip.Class)), "", 2, "L", false, 0, "")
+
+		username := fmt.Sprintf("Username: %s", slip.Username)
+		pdf.SetX(x + 5)
+		pdf.SetFont("Courier", "", fitFontSize(pdf, "Courier", username, 11, slipW-10))
+		pdf.CellFormat(slipW-10, 7, username, "", 2, "L", false, 0, "")
+		pdf.SetX(x + 5)
+		pdf.SetFont("Courier", "B", 16)
+		pdf.CellFormat(slipW-10, 9, fmt.Sprintf("PIN: %s", slip.PIN), "", 2, "L", false, 0, "")
+
+		pdf.SetX(x + 5)
+		pdf.SetFont("Helvetica", "I", 7)
+		pdf.CellFormat(slipW-10, 4, "Simpan kode ini, jangan dibagikan. Dicetak "+printed, "", 2, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// drawGrid draws dashed cut lines around a cols x rows grid of cells
+func drawGrid(pdf *gofpdf.Fpdf, cols, rows int, cellW, cellH float64) {
+	pdf.SetDrawColor(150, 150, 150)
+	pdf.SetLineWidth(0.2)
+	pdf.SetDashPattern([]float64{2, 2}, 0)
+
+	for col := 0; col <= cols; col++ {
+		x := pageMargin + float64(col)*cellW
+		pdf.Line(x, pageMargin, x, pageMargin+float64(rows)*cellH)
+	}
+	for row := 0; row <= rows; row++ {
+		y := pageMargin + float64(row)*cellH
+		pdf.Line(pageMargin, y, pageMargin+float64(cols)*cellW, y)
+	}
+
+	pdf.SetDashPattern([]float64{}, 0)
+}
+
+// fitFontSize shrinks the font until text fits in width, down to 6pt
+func fitFontSize(pdf *gofpdf.Fpdf, family, text string, size, width float64) float64 {
+	for ; size > 6; size-- {
+		pdf.SetFont(family, "", size)
+		if pdf.GetStringWidth(text) <= width {
+			break
+		}
+	}
+	return size
+}
@@ -1,10 +1,15 @@
 package router
 
 import (
+	"log"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/audit"
 	"github.com/FirstTirr/G7KAIH-GO/internal/auth"
 	"github.com/FirstTirr/G7KAIH-GO/internal/config"
 	"github.com/FirstTirr/G7KAIH-GO/internal/handlers"
 	"github.com/FirstTirr/G7KAIH-GO/internal/middleware"
+	"github.com/FirstTirr/G7KAIH-GO/internal/submission"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -14,9 +19,15 @@ import (
 func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	r := gin.Default()
 
-	// Set trusted proxies
+	// Set trusted proxies. Without any, X-Forwarded-For is ignored: gin would
+	// otherwise trust it from every client, and a spoofed client IP resets
+	// the login throttling.
 	if len(cfg.Server.TrustedProxies) > 0 {
-		r.SetTrustedProxies(cfg.Server.TrustedProxies)
+		if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	} else if err := r.SetTrustedProxies(nil); err != nil {
+		log.Fatalf("Failed to disable trusted proxies: %v", err)
 	}
 
 	// Middleware - Request ID (for tracing in microservices)
@@ -44,25 +55,73 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	}
 
 	// JWT Service
+	if cfg.JWT.KeysDir == "" && cfg.JWT.Secret == "your-secret-key" {
+		if cfg.Environment == "production" {
+			log.Fatal("Set JWT_KEYS_DIR or JWT_SECRET before running in production")
+		}
+		log.Println("Warning: signing tokens with the default JWT secret")
+	}
+	if cfg.MFA.EncryptionKey == "" && cfg.JWT.Secret == "your-secret-key" && cfg.Environment == "production" {
+		log.Fatal("Set MFA_ENCRYPTION_KEY or JWT_SECRET before running in production")
+	}
+	signingKeys, err := auth.NewKeySet(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
 	jwtService := auth.NewJWTService(
-		cfg.JWT.Secret,
-		cfg.JWT.ExpirationHours,
+		signingKeys,
+		cfg.JWT.AccessTokenTTL,
 	)
+	refreshTokens := auth.NewRefreshTokenStore(db, cfg.JWT.RefreshTokenTTL)
+	sessions := auth.NewSessionStore(db)
+	impersonations := auth.NewImpersonationStore(db, cfg.JWT.ImpersonationTTL)
+	qrTokens := auth.NewQRLoginStore(db, cfg.QRLogin)
+	mfa, err := auth.NewMFAService(db, cfg.MFA, cfg.JWT.Secret)
+	if err != nil {
+		log.Fatalf("Failed to initialize two-factor authentication: %v", err)
+	}
+
+	permissions := auth.NewPermissionStore(db)
 
 	// Auth Middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtService)
+	authMiddleware := middleware.NewAuthMiddleware(jwtService, sessions, impersonations, permissions, cfg.MFA.AdminRequireMFA)
+
+	// Row-level security for routes that read student data (no-op unless
+	// DB_RLS_ENABLED is set)
+	rls := middleware.RowLevelSecurity(db, cfg.Database)
+
+	// Audit log of administrative and review actions
+	auditLog := audit.New(db)
+
+	// Login brute-force protection
+	loginGuard := auth.NewLoginGuard(db, cfg.LoginGuard)
+
+	// Submission schedule, resolved in the school timezone
+	schoolTimezone, err := time.LoadLocation(cfg.School.Timezone)
+	if err != nil {
+		log.Fatalf("Invalid SCHOOL_TIMEZONE %q: %v", cfg.School.Timezone, err)
+	}
+	submissionSchedule := submission.NewScheduler(schoolTimezone)
 
 	// Handlers
-	authHandler := handlers.NewAuthHandler(db, jwtService)
+	authHandler := handlers.NewAuthHandler(db, jwtService, loginGuard, refreshTokens, sessions, qrTokens, mfa, permissions)
 	categoryHandler := handlers.NewCategoryHandler(db)
 	kegiatanHandler := handlers.NewKegiatanHandler(db)
-	activityHandler := handlers.NewActivityHandler(db)
+	activityHandler := handlers.NewActivityHandler(db, submissionSchedule)
 	commentHandler := handlers.NewCommentHandler(db)
-	userHandler := handlers.NewUserHandler(db)
+	userHandler := handlers.NewUserHandler(db, sessions)
 	teacherHandler := handlers.NewTeacherHandler(db)
 	guruWaliHandler := handlers.NewGuruWaliHandler(db)
 	orangTuaHandler := handlers.NewOrangTuaHandler(db)
-	adminHandler := handlers.NewAdminHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, sessions, permissions)
+	inviteHandler := handlers.NewInviteHandler(db, permissions)
+	qrLoginHandler := handlers.NewQRLoginHandler(db, qrTokens, permissions)
+	securityHandler := handlers.NewSecurityHandler(db, loginGuard, sessions, mfa, permissions)
+	roleHandler := handlers.NewRoleHandler(db, permissions)
+	impersonationHandler := handlers.NewImpersonationHandler(db, jwtService, impersonations, permissions)
+	auditHandler := handlers.NewAuditHandler(db)
+	submissionScheduleHandler := handlers.NewSubmissionScheduleHandler(db, submissionSchedule, permissions)
+	compatHandler := handlers.NewCompatHandler(db, permissions, submissionSchedule)
 
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -72,17 +131,33 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Public keys for services that verify our tokens
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// API v1 routes
 	v1 := r.Group("/api/v1")
 	{
 		// Auth routes (public)
-		auth := v1.Group("/auth")
+		authRoutes := v1.Group("/auth")
 		{
-			auth.POST("/signup", authHandler.Signup)
-			auth.POST("/login", authHandler.Login)
+			authRoutes.POST("/signup", authHandler.Signup)
+			authRoutes.GET("/invites/:code", inviteHandler.GetInviteInfo)
+			authRoutes.POST("/login", authHandler.Login)
+			authRoutes.POST("/login/qr", authHandler.LoginQR)
+			authRoutes.POST("/login/2fa", authHandler.LoginTwoFactor)
+			authRoutes.POST("/refresh", authHandler.Refresh)
 			// expose a token-validated 'me' endpoint so frontends can fetch current user
 			// Note: requires Authorization header (Bearer token)
-			auth.GET("/me", authMiddleware.Authenticate(), authHandler.Me)
+			authRoutes.GET("/me", authMiddleware.AuthenticateAllowPasswordChange(), authHandler.Me)
+			authRoutes.POST("/logout", authMiddleware.AuthenticateAllowPasswordChange(), authHandler.Logout)
+			authRoutes.POST("/logout-all", authMiddleware.AuthenticateAllowPasswordChange(), authHandler.LogoutAll)
+			authRoutes.POST("/password", authMiddleware.AuthenticateAllowPasswordChange(), authHandler.ChangePassword)
+			authRoutes.GET("/sessions", authMiddleware.Authenticate(), authHandler.GetSessions)
+			authRoutes.POST("/switch-role", authMiddleware.Authenticate(), authHandler.SwitchRole)
+			authRoutes.POST("/2fa/setup", authMiddleware.Authenticate(), authHandler.SetupMFA)
+			authRoutes.POST("/2fa/enable", authMiddleware.Authenticate(), authHandler.EnableMFA)
+			authRoutes.POST("/2fa/disable", authMiddleware.Authenticate(), authHandler.DisableMFA)
+			authRoutes.POST("/2fa/recovery-codes", authMiddleware.Authenticate(), authHandler.RegenerateRecoveryCodes)
 		}
 
 		// Categories (public read, admin write)
@@ -90,9 +165,9 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		{
 			categories.GET("", categoryHandler.GetCategories)
 			categories.GET("/:id", categoryHandler.GetCategory)
-			categories.POST("", authMiddleware.Authenticate(), authMiddleware.RequireAdmin(), categoryHandler.CreateCategory)
-			categories.PUT("/:id", authMiddleware.Authenticate(), authMiddleware.RequireAdmin(), categoryHandler.UpdateCategory)
-			categories.DELETE("/:id", authMiddleware.Authenticate(), authMiddleware.RequireAdmin(), categoryHandler.DeleteCategory)
+			categories.POST("", authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermCategoryManage), auditLog.Middleware(), categoryHandler.CreateCategory)
+			categories.PUT("/:id", authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermCategoryManage), auditLog.Middleware(), categoryHandler.UpdateCategory)
+			categories.DELETE("/:id", authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermCategoryManage), auditLog.Middleware(), categoryHandler.DeleteCategory)
 		}
 
 		// Kegiatan (public read, admin write)
@@ -100,14 +175,20 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		{
 			kegiatan.GET("", kegiatanHandler.GetKegiatan)
 			kegiatan.GET("/:id", kegiatanHandler.GetKegiatanByID)
-			kegiatan.POST("", authMiddleware.Authenticate(), authMiddleware.RequireAdmin(), kegiatanHandler.CreateKegiatan)
-			kegiatan.PUT("/:id", authMiddleware.Authenticate(), authMiddleware.RequireAdmin(), kegiatanHandler.UpdateKegiatan)
-			kegiatan.DELETE("/:id", authMiddleware.Authenticate(), authMiddleware.RequireAdmin(), kegiatanHandler.DeleteKegiatan)
+			kegiatan.GET("/:id/versions", kegiatanHandler.GetFormVersions)
+			kegiatan.GET("/:id/versions/diff", kegiatanHandler.DiffFormVersions)
+			kegiatan.GET("/:id/versions/:version", kegiatanHandler.GetFormVersion)
+			kegiatan.POST("", authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermKegiatanManage), auditLog.Middleware(), kegiatanHandler.CreateKegiatan)
+			kegiatan.PUT("/:id", authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermKegiatanManage), auditLog.Middleware(), kegiatanHandler.UpdateKegiatan)
+			kegiatan.DELETE("/:id", authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermKegiatanManage), auditLog.Middleware(), kegiatanHandler.DeleteKegiatan)
 		}
 
+		// Submission window status (public, for countdowns)
+		v1.GET("/submission-window/status", submissionScheduleHandler.GetStatus)
+
 		// Activities (authenticated)
 		activities := v1.Group("/activities")
-		activities.Use(authMiddleware.Authenticate())
+		activities.Use(authMiddleware.Authenticate(), auditLog.Collect(), rls)
 		{
 			activities.GET("", activityHandler.GetActivities)
 			activities.GET("/:id", activityHandler.GetActivity)
@@ -118,7 +199,7 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 
 		// Comments (authenticated)
 		comments := v1.Group("/comments")
-		comments.Use(authMiddleware.Authenticate())
+		comments.Use(authMiddleware.Authenticate(), auditLog.Collect(), rls)
 		{
 			comments.GET("", commentHandler.GetComments)
 			comments.POST("", commentHandler.CreateComment)
@@ -132,11 +213,12 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		{
 			users.GET("/profile", userHandler.GetProfile)
 			users.PUT("/profile", userHandler.UpdateProfile)
+			users.POST("/change-token", userHandler.ChangeToken)
 		}
 
 		// Teacher routes
 		teacher := v1.Group("/teacher")
-		teacher.Use(authMiddleware.Authenticate(), authMiddleware.RequireTeacher())
+		teacher.Use(authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermReportViewClass), rls)
 		{
 			teacher.GET("/students", teacherHandler.GetStudents)
 			teacher.GET("/students/:id", teacherHandler.GetStudent)
@@ -147,7 +229,7 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 
 		// Guru Wali routes
 		guruwali := v1.Group("/guruwali")
-		guruwali.Use(authMiddleware.Authenticate(), authMiddleware.RequireGuruWali())
+		guruwali.Use(authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermReportViewSupervised), rls)
 		{
 			guruwali.GET("/students", guruWaliHandler.GetStudents)
 			guruwali.GET("/students/:id", guruWaliHandler.GetStudent)
@@ -157,37 +239,107 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 
 		// Orang Tua routes
 		orangtua := v1.Group("/orangtua")
-		orangtua.Use(authMiddleware.Authenticate(), authMiddleware.RequireRole("orangtua"))
+		orangtua.Use(authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermChildView), rls)
 		{
 			orangtua.GET("/siswa", orangTuaHandler.GetChildren)
 			orangtua.GET("/siswa/:id/activities", orangTuaHandler.GetChildActivities)
 		}
 
-		// Admin routes
+		// Admin routes, each group guarded by a permission. Every successful
+		// write is audited.
 		admin := v1.Group("/admin")
-		admin.Use(authMiddleware.Authenticate(), authMiddleware.RequireAdmin())
+		admin.Use(authMiddleware.Authenticate(), auditLog.Middleware())
 		{
-			admin.GET("/users", adminHandler.GetUsers)
-			admin.POST("/users", adminHandler.CreateUser)
-			admin.PUT("/users/:id", adminHandler.UpdateUser)
-			admin.DELETE("/users/:id", adminHandler.DeleteUser)
-			admin.POST("/users/bulk-import", adminHandler.BulkImportUsers)
-			
-			admin.POST("/link-parent-student", adminHandler.LinkParentStudent)
-			admin.DELETE("/link-parent-student/:id", adminHandler.UnlinkParentStudent)
-			
-			admin.POST("/assign-guruwali", adminHandler.AssignGuruWali)
-			admin.DELETE("/assign-guruwali/:id", adminHandler.UnassignGuruWali)
-			admin.GET("/guruwali-assignments", adminHandler.GetGuruWaliAssignments)
-			
-			admin.POST("/teacher-roles", adminHandler.AssignTeacherRole)
-			admin.DELETE("/teacher-roles/:id", adminHandler.UnassignTeacherRole)
-			admin.GET("/teacher-roles", adminHandler.GetTeacherRoles)
-			
-			admin.GET("/submission-window", adminHandler.GetSubmissionWindow)
-			admin.PUT("/submission-window", adminHandler.UpdateSubmissionWindow)
+			users := admin.Group("", authMiddleware.RequirePermission(auth.PermUserManage))
+			users.GET("/users", adminHandler.GetUsers)
+			users.POST("/users", adminHandler.CreateUser)
+			users.PUT("/users/:id", adminHandler.UpdateUser)
+			users.DELETE("/users/:id", adminHandler.DeleteUser)
+			users.PUT("/users/:id/roles", adminHandler.SetUserRoles)
+			users.PUT("/users/:id/status", adminHandler.SetUserStatus)
+			users.POST("/users/status", adminHandler.BulkSetUserStatus)
+			users.POST("/users/bulk-import", adminHandler.BulkImportUsers)
+			users.POST("/users/reset-tokens", adminHandler.BulkResetTokens)
+			users.POST("/users/:id/reset-token", adminHandler.ResetUserToken)
+			users.POST("/users/:id/password", adminHandler.SetUserPassword)
+			users.POST("/users/qr-cards", qrLoginHandler.CreateClassQRCards)
+			users.POST("/users/:id/qr-login", qrLoginHandler.CreateUserQRLogin)
+
+			users.POST("/link-parent-student", adminHandler.LinkParentStudent)
+			users.DELETE("/link-parent-student/:id", adminHandler.UnlinkParentStudent)
+
+			users.POST("/assign-guruwali", adminHandler.AssignGuruWali)
+			users.DELETE("/assign-guruwali/:id", adminHandler.UnassignGuruWali)
+			users.GET("/guruwali-assignments", adminHandler.GetGuruWaliAssignments)
+
+			users.POST("/teacher-roles", adminHandler.AssignTeacherRole)
+			users.DELETE("/teacher-roles/:id", adminHandler.UnassignTeacherRole)
+			users.GET("/teacher-roles", adminHandler.GetTeacherRoles)
+
+			users.GET("/invites", inviteHandler.GetInvites)
+			users.POST("/invites", inviteHandler.CreateInvite)
+			users.DELETE("/invites/:id", inviteHandler.RevokeInvite)
+			users.GET("/registration-settings", inviteHandler.GetRegistrationSettings)
+			users.PUT("/registration-settings", inviteHandler.UpdateRegistrationSettings)
+
+			settings := admin.Group("", authMiddleware.RequirePermission(auth.PermSettingsManage))
+			settings.GET("/submission-schedule", submissionScheduleHandler.GetSchedule)
+			settings.GET("/submission-schedule/resolve", submissionScheduleHandler.ResolveSchedule)
+			settings.POST("/submission-schedule/rules", submissionScheduleHandler.CreateRule)
+			settings.PUT("/submission-schedule/rules/:id", submissionScheduleHandler.UpdateRule)
+			settings.DELETE("/submission-schedule/rules/:id", submissionScheduleHandler.DeleteRule)
+			settings.POST("/submission-schedule/exceptions", submissionScheduleHandler.CreateException)
+			settings.PUT("/submission-schedule/exceptions/:id", submissionScheduleHandler.UpdateException)
+			settings.DELETE("/submission-schedule/exceptions/:id", submissionScheduleHandler.DeleteException)
+
+			security := admin.Group("", authMiddleware.RequirePermission(auth.PermSecurityManage))
+			security.GET("/users/:id/sessions", securityHandler.GetUserSessions)
+			security.POST("/users/:id/revoke-sessions", securityHandler.RevokeUserSessions)
+			security.DELETE("/users/:id/2fa", securityHandler.ResetUserMFA)
+			security.GET("/login-lockouts", securityHandler.GetLoginLockouts)
+			security.DELETE("/login-lockouts/:id", securityHandler.ClearLoginLockout)
+			security.GET("/login-attempts", securityHandler.GetLoginAttempts)
+
+			impersonation := admin.Group("", authMiddleware.RequirePermission(auth.PermUserImpersonate))
+			impersonation.POST("/users/:id/impersonate", impersonationHandler.Impersonate)
+			impersonation.GET("/impersonations", impersonationHandler.GetImpersonations)
+			impersonation.DELETE("/impersonations/:id", impersonationHandler.EndImpersonation)
+
+			auditLogs := admin.Group("", authMiddleware.RequirePermission(auth.PermAuditView))
+			auditLogs.GET("/audit-log", auditHandler.GetAuditLog)
+			auditLogs.GET("/audit-log/:id", auditHandler.GetAuditLogEntry)
+
+			roles := admin.Group("", authMiddleware.RequirePermission(auth.PermRoleManage))
+			roles.GET("/permissions", roleHandler.GetPermissions)
+			roles.GET("/roles", roleHandler.GetRoles)
+			roles.POST("/roles", roleHandler.CreateRole)
+			roles.PUT("/roles/:name", roleHandler.UpdateRole)
+			roles.DELETE("/roles/:name", roleHandler.DeleteRole)
 		}
 	}
 
+	// Routes of the Next.js API with its paths and JSON shapes, so pages can
+	// move off Supabase one at a time
+	compat := r.Group("/api")
+	{
+		compat.GET("/submission-window", compatHandler.GetSubmissionWindow)
+		compat.POST("/submission-window", authMiddleware.Authenticate(), authMiddleware.RequirePermission(auth.PermSettingsManage), auditLog.Middleware(), compatHandler.UpdateSubmissionWindow)
+
+		studentData := compat.Group("", authMiddleware.Authenticate(), auditLog.Collect(), rls)
+		studentData.GET("/aktivitas", compatHandler.GetActivities)
+		studentData.POST("/aktivitas", compatHandler.CreateActivity)
+		studentData.GET("/komentar", compatHandler.GetComments)
+		studentData.POST("/komentar", compatHandler.CreateComment)
+		studentData.DELETE("/komentar", compatHandler.DeleteComment)
+
+		compat.GET("/user-profiles/:userid", authMiddleware.Authenticate(), compatHandler.GetUserProfile)
+
+		admin := compat.Group("", authMiddleware.Authenticate(), auditLog.Middleware())
+		admin.PATCH("/category/:categoryid", authMiddleware.RequirePermission(auth.PermCategoryManage), compatHandler.UpdateCategory)
+		admin.DELETE("/category/:categoryid", authMiddleware.RequirePermission(auth.PermCategoryManage), compatHandler.DeleteCategory)
+		admin.PATCH("/user-profiles/:userid", authMiddleware.RequirePermission(auth.PermUserManage), compatHandler.UpdateUserProfile)
+		admin.DELETE("/user-profiles/:userid", authMiddleware.RequirePermission(auth.PermUserManage), compatHandler.DeleteUserProfile)
+	}
+
 	return r
 }
@@ -0,0 +1,331 @@
+// Package submission decides whether a student may submit activities of a
+// kegiatan at a given time. The schedule is made of weekly rules (the hours
+// of each weekday) and exceptions for date ranges such as holidays or exams;
+// both can be narrowed to a class, a grade or a kegiatan. Times are read in
+// the school timezone.
+package submission
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lookahead is how far Resolve searches for the next opening or closing
+const lookahead = 366
+
+const dateFormat = "2006-01-02"
+
+// Status is whether a submission is allowed at a moment. OpensAt is nil while
+// open, or when the schedule never opens again within a year; ClosesAt is nil
+// when closed, or open with no end in sight. Reason names the exception that
+// decided the day, if any.
+type Status struct {
+	Open     bool       `json:"open"`
+	Now      time.Time  `json:"now"`
+	Timezone string     `json:"timezone"`
+	OpensAt  *time.Time `json:"opens_at"`
+	ClosesAt *time.Time `json:"closes_at"`
+	Reason   string     `json:"reason,omitempty"`
+}
+
+// Message explains to a student why a submission was refused
+func (s Status) Message() string {
+	closed := "Submission is closed"
+	if s.Reason != "" {
+		closed = fmt.Sprintf("Submission is closed (%s)", s.Reason)
+	}
+	if s.OpensAt == nil {
+		return closed + " and is not scheduled to open again"
+	}
+	return fmt.Sprintf("%s; it opens at %s", closed, s.OpensAt.Format("2006-01-02 15:04 MST"))
+}
+
+// Scope is who submits what: the class of the student and the kegiatan.
+// Empty fields only match rules and exceptions without that scope.
+type Scope struct {
+	Class      string
+	KegiatanID *uuid.UUID
+}
+
+// ParseClock parses an "HH:MM" time of day into minutes after midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil || len(value) != len("15:04") {
+		return 0, errors.New("time must be in HH:MM format")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateHours checks the times of a rule or exception. Closed ones take no
+// times; an open one without times is open all day.
+func ValidateHours(closed bool, openTime, closeTime *string) error {
+	if closed {
+		if openTime != nil || closeTime != nil {
+			return errors.New("a closed rule takes no open_time or close_time")
+		}
+		return nil
+	}
+	for _, t := range []*string{openTime, closeTime} {
+		if t == nil {
+			continue
+		}
+		if _, err := ParseClock(*t); err != nil {
+			return err
+		}
+	}
+	if openTime != nil && closeTime != nil && *openTime == *closeTime {
+		return errors.New("open_time and close_time must differ")
+	}
+	return nil
+}
+
+// MatchClass reports whether a class scope applies to a class. A scope
+// ending in "*" matches every class starting with it, e.g. "XII*" for the
+// whole grade; an empty scope matches every class. The prefix has to end
+// where the grade does, so "XI*" does not match "XII IPA 1" and "1*" does
+// not match "10A".
+func MatchClass(scope *string, class string) bool {
+	if scope == nil || *scope == "" {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(*scope, "*")
+	if !ok {
+		return strings.EqualFold(*scope, class)
+	}
+	if len(class) < len(prefix) || !strings.EqualFold(class[:len(prefix)], prefix) {
+		return false
+	}
+	if prefix == "" || len(class) == len(prefix) {
+		return true
+	}
+	last, _ := utf8.DecodeLastRuneInString(prefix)
+	next, _ := utf8.DecodeRuneInString(class[len(prefix):])
+	return !(unicode.IsLetter(last) && unicode.IsLetter(next)) && !(unicode.IsDigit(last) && unicode.IsDigit(next))
+}
+
+// entry is a rule or an exception
+type entry struct {
+	name       string
+	closed     bool
+	openTime   *string
+	closeTime  *string
+	class      *string
+	kegiatanID *uuid.UUID
+	createdAt  time.Time
+}
+
+// specificity ranks the entries matching a scope: a kegiatan scope beats a
+// class scope, and an exact class beats a grade
+func (e *entry) specificity() int {
+	score := 0
+	if e.kegiatanID != nil {
+		score += 4
+	}
+	if e.class != nil && *e.class != "" {
+		if strings.HasSuffix(*e.class, "*") {
+			score++
+		} else {
+			score += 2
+		}
+	}
+	return score
+}
+
+func (e *entry) matches(scope Scope) bool {
+	if e.kegiatanID != nil && (scope.KegiatanID == nil || *e.kegiatanID != *scope.KegiatanID) {
+		return false
+	}
+	return MatchClass(e.class, scope.Class)
+}
+
+// pick returns the most specific entry matching the scope; among equals the
+// newest wins
+func pick(entries []*entry, scope Scope) *entry {
+	var best *entry
+	for _, e := range entries {
+		if !e.matches(scope) {
+			continue
+		}
+		if best == nil || e.specificity() > best.specificity() ||
+			(e.specificity() == best.specificity() && e.createdAt.After(best.createdAt)) {
+			best = e
+		}
+	}
+	return best
+}
+
+// Schedule is the set of rules and exceptions, read in Location
+type Schedule struct {
+	Rules      []models.SubmissionRule
+	Exceptions []models.SubmissionException
+	Location   *time.Location
+}
+
+// day returns the entry that decides a date for the scope: the exception
+// covering it, else the rule of its weekday. nil means open all day.
+func (s *Schedule) day(scope Scope, date time.Time) (*entry, bool) {
+	key := date.Format(dateFormat)
+
+	var exceptions []*entry
+	for i := range s.Exceptions {
+		x := &s.Exceptions[i]
+		if x.StartDate.Format(dateFormat) > key || (x.EndDate != nil && x.EndDate.Format(dateFormat) < key) {
+			continue
+		}
+		exceptions = append(exceptions, &entry{
+			name: x.Name, closed: x.Closed, openTime: x.OpenTime, closeTime: x.CloseTime,
+			class: x.Class, kegiatanID: x.KegiatanID, createdAt: x.CreatedAt,
+		})
+	}
+	if e := pick(exceptions, scope); e != nil {
+		return e, true
+	}
+
+	var rules []*entry
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		if r.Weekday != int(date.Weekday()) {
+			continue
+		}
+		rules = append(rules, &entry{
+			closed: r.Closed, openTime: r.OpenTime, closeTime: r.CloseTime,
+			class: r.Class, kegiatanID: r.KegiatanID, createdAt: r.CreatedAt,
+		})
+	}
+	return pick(rules, scope), false
+}
+
+// interval is the time a day is open, which may run past midnight
+type interval struct {
+	opens, closes time.Time
+	reason        string
+}
+
+// hours returns the open interval of a date, or false when it is closed
+func (s *Schedule) hours(scope Scope, date time.Time) (interval, bool) {
+	e, exception := s.day(scope, date)
+	start, end := 0, 24*60
+	reason := ""
+	if e != nil {
+		if e.closed {
+			return interval{}, false
+		}
+		if e.openTime != nil {
+			start, _ = ParseClock(*e.openTime)
+		}
+		if e.closeTime != nil {
+			end, _ = ParseClock(*e.closeTime)
+		}
+		if end <= start {
+			end += 24 * 60
+		}
+		if exception {
+			reason = e.name
+		}
+	}
+	year, month, day := date.Date()
+	return interval{
+		opens:  time.Date(year, month, day, 0, start, 0, 0, s.Location),
+		closes: time.Date(year, month, day, 0, end, 0, 0, s.Location),
+		reason: reason,
+	}, true
+}
+
+// Resolve answers whether the scope may submit at t, and when that changes
+func (s *Schedule) Resolve(scope Scope, t time.Time) Status {
+	now := t.In(s.Location)
+	status := Status{Now: now, Timezone: s.Location.String()}
+
+	// Yesterday's hours may run past midnight
+	year, month, day := now.Date()
+	var intervals []interval
+	for offset := -1; offset <= lookahead; offset++ {
+		date := time.Date(year, month, day+offset, 0, 0, 0, 0, s.Location)
+		if i, ok := s.hours(scope, date); ok {
+			intervals = append(intervals, i)
+		}
+	}
+
+	for k, current := range intervals {
+		if now.Before(current.opens) {
+			opens := current.opens
+			status.OpensAt = &opens
+			break
+		}
+		if !now.Before(current.closes) {
+			continue
+		}
+
+		status.Open = true
+		status.Reason = current.reason
+		closes := current.closes
+		for _, next := range intervals[k+1:] {
+			if next.opens.After(closes) {
+				status.ClosesAt = &closes
+				return status
+			}
+			if next.closes.After(closes) {
+				closes = next.closes
+			}
+		}
+		return status
+	}
+
+	if e, exception := s.day(scope, time.Date(year, month, day, 0, 0, 0, 0, s.Location)); exception && e.closed {
+		status.Reason = e.name
+	}
+	return status
+}
+
+// Scheduler loads the schedule and resolves it in the school timezone
+type Scheduler struct {
+	loc *time.Location
+	now func() time.Time
+}
+
+func NewScheduler(loc *time.Location) *Scheduler {
+	return &Scheduler{loc: loc, now: time.Now}
+}
+
+// Location is the school timezone
+func (s *Scheduler) Location() *time.Location {
+	return s.loc
+}
+
+// Load reads the rules and the exceptions that have not ended by t
+func (s *Scheduler) Load(db *gorm.DB, t time.Time) (*Schedule, error) {
+	schedule := &Schedule{Location: s.loc}
+	if err := db.Order("weekday ASC, created_at ASC").Find(&schedule.Rules).Error; err != nil {
+		return nil, err
+	}
+	// Yesterday's hours may still be running
+	since := t.In(s.loc).AddDate(0, 0, -1).Format(dateFormat)
+	if err := db.Where("end_date IS NULL OR end_date >= ?", since).
+		Order("start_date ASC, created_at ASC").
+		Find(&schedule.Exceptions).Error; err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Status resolves the schedule for the scope now
+func (s *Scheduler) Status(db *gorm.DB, scope Scope) (Status, error) {
+	return s.StatusAt(db, scope, s.now())
+}
+
+// StatusAt resolves the schedule for the scope at t
+func (s *Scheduler) StatusAt(db *gorm.DB, scope Scope, t time.Time) (Status, error) {
+	schedule, err := s.Load(db, t)
+	if err != nil {
+		return Status{}, err
+	}
+	return schedule.Resolve(scope, t), nil
+}
@@ -0,0 +1,171 @@
+package submission
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FirstTirr/G7KAIH-GO/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	wib      = time.FixedZone("WIB", 7*60*60)
+	kegiatan = uuid.New()
+	created  = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func clock(value string) *string {
+	return &value
+}
+
+// at is a time in March 2026, which starts on a Sunday
+func at(day int, hour, minute int) time.Time {
+	return time.Date(2026, time.March, day, hour, minute, 0, 0, wib)
+}
+
+func date(day int) time.Time {
+	return time.Date(2026, time.March, day, 0, 0, 0, 0, time.UTC)
+}
+
+// weekdays is open 05:00-21:00 Monday to Friday and all day at weekends
+func weekdays() []models.SubmissionRule {
+	var rules []models.SubmissionRule
+	for day := 1; day <= 5; day++ {
+		rules = append(rules, models.SubmissionRule{Weekday: day, OpenTime: clock("05:00"), CloseTime: clock("21:00"), CreatedAt: created})
+	}
+	return rules
+}
+
+func TestResolve(t *testing.T) {
+	holiday := models.SubmissionException{Name: "Libur Nasional", StartDate: date(3), EndDate: ptr(date(3)), Closed: true, CreatedAt: created}
+	exams := models.SubmissionException{Name: "Ujian XII", StartDate: date(9), EndDate: ptr(date(13)), Closed: true, Class: clock("XII*"), CreatedAt: created}
+	makeUp := models.SubmissionException{Name: "Susulan", StartDate: date(11), EndDate: ptr(date(11)), OpenTime: clock("07:00"), CloseTime: clock("09:00"), Class: clock("XII IPA 1"), CreatedAt: created}
+	closed := models.SubmissionException{Name: "Ditutup", StartDate: date(16), Closed: true, CreatedAt: created}
+	kegiatanClosed := models.SubmissionRule{Weekday: 4, Closed: true, KegiatanID: &kegiatan, CreatedAt: created}
+	var nights []models.SubmissionRule
+	for day := 0; day <= 6; day++ {
+		nights = append(nights, models.SubmissionRule{Weekday: day, OpenTime: clock("20:00"), CloseTime: clock("02:00"), CreatedAt: created})
+	}
+	overnight := &Schedule{Rules: nights, Location: wib}
+
+	regular := &Schedule{
+		Rules:      append(weekdays(), kegiatanClosed),
+		Exceptions: []models.SubmissionException{holiday, exams, makeUp},
+		Location:   wib,
+	}
+	student := Scope{Class: "7A"}
+	senior := Scope{Class: "XII IPA 1"}
+	seniorB := Scope{Class: "xii ips 2"}
+	ofKegiatan := Scope{Class: "7A", KegiatanID: &kegiatan}
+
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		scope    Scope
+		now      time.Time
+		open     bool
+		opensAt  *time.Time
+		closesAt *time.Time
+		reason   string
+	}{
+		{"empty schedule", &Schedule{Location: wib}, student, at(2, 3, 0), true, nil, nil, ""},
+		{"before weekday hours", regular, student, at(2, 4, 30), false, ptr(at(2, 5, 0)), nil, ""},
+		{"weekday hours", regular, student, at(2, 5, 0), true, nil, ptr(at(2, 21, 0)), ""},
+		{"holiday", regular, student, at(3, 12, 0), false, ptr(at(4, 5, 0)), nil, "Libur Nasional"},
+		{"after friday hours", regular, student, at(6, 21, 0), false, ptr(at(7, 0, 0)), nil, ""},
+		{"weekend runs until monday", regular, student, at(7, 12, 0), true, nil, ptr(at(9, 0, 0)), ""},
+		{"kegiatan rule", regular, ofKegiatan, at(5, 12, 0), false, ptr(at(6, 5, 0)), nil, ""},
+		{"other kegiatan", regular, student, at(5, 12, 0), true, nil, ptr(at(5, 21, 0)), ""},
+		{"grade exams", regular, seniorB, at(10, 12, 0), false, ptr(at(14, 0, 0)), nil, "Ujian XII"},
+		{"other grade during exams", regular, student, at(10, 12, 0), true, nil, ptr(at(10, 21, 0)), ""},
+		{"class make-up beats grade exams", regular, senior, at(11, 8, 0), true, nil, ptr(at(11, 9, 0)), "Susulan"},
+		{"before make-up", regular, senior, at(11, 6, 0), false, ptr(at(11, 7, 0)), nil, ""},
+		{"closed until removed", &Schedule{Rules: weekdays(), Exceptions: []models.SubmissionException{closed}, Location: wib}, student, at(16, 12, 0), false, nil, nil, "Ditutup"},
+		{"overnight after midnight", overnight, student, at(3, 1, 0), true, nil, ptr(at(3, 2, 0)), ""},
+		{"overnight during the day", overnight, student, at(3, 12, 0), false, ptr(at(3, 20, 0)), nil, ""},
+		{"resolved in school timezone", regular, student, time.Date(2026, time.March, 1, 22, 30, 0, 0, time.UTC), true, nil, ptr(at(2, 21, 0)), ""},
+	}
+
+	for _, tt := range tests {
+		status := tt.schedule.Resolve(tt.scope, tt.now)
+		if status.Open != tt.open {
+			t.Errorf("%s: open = %v, want %v", tt.name, status.Open, tt.open)
+		}
+		if !sameTime(status.OpensAt, tt.opensAt) {
+			t.Errorf("%s: opens_at = %v, want %v", tt.name, status.OpensAt, tt.opensAt)
+		}
+		if !sameTime(status.ClosesAt, tt.closesAt) {
+			t.Errorf("%s: closes_at = %v, want %v", tt.name, status.ClosesAt, tt.closesAt)
+		}
+		if status.Reason != tt.reason {
+			t.Errorf("%s: reason = %q, want %q", tt.name, status.Reason, tt.reason)
+		}
+	}
+}
+
+func TestMatchClass(t *testing.T) {
+	tests := []struct {
+		scope *string
+		class string
+		want  bool
+	}{
+		{nil, "7A", true},
+		{clock(""), "7A", true},
+		{clock("7A"), "7a", true},
+		{clock("7A"), "7B", false},
+		{clock("XII*"), "XII IPA 1", true},
+		{clock("XII*"), "XI IPA 1", false},
+		{clock("XII*"), "", false},
+		{clock("XI*"), "XII IPA 1", false},
+		{clock("XI*"), "XI IPA 1", true},
+		{clock("X*"), "XI IPA 1", false},
+		{clock("X*"), "X-2", true},
+		{clock("7*"), "7A", true},
+		{clock("1*"), "10A", false},
+	}
+	for _, tt := range tests {
+		if got := MatchClass(tt.scope, tt.class); got != tt.want {
+			t.Errorf("MatchClass(%v, %q) = %v, want %v", tt.scope, tt.class, got, tt.want)
+		}
+	}
+}
+
+func TestValidateHours(t *testing.T) {
+	if err := ValidateHours(false, clock("05:00"), clock("21:00")); err != nil {
+		t.Errorf("valid hours refused: %v", err)
+	}
+	if err := ValidateHours(true, nil, nil); err != nil {
+		t.Errorf("closed day refused: %v", err)
+	}
+	for name, err := range map[string]error{
+		"closed with times": ValidateHours(true, clock("05:00"), nil),
+		"same times":        ValidateHours(false, clock("05:00"), clock("05:00")),
+		"bad time":          ValidateHours(false, clock("5:00"), nil),
+	} {
+		if err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	if m, err := ParseClock("07:30"); err != nil || m != 450 {
+		t.Errorf("ParseClock(07:30) = %d, %v", m, err)
+	}
+	for _, value := range []string{"7:30", "24:00", "07:60", "0730", ""} {
+		if _, err := ParseClock(value); err == nil {
+			t.Errorf("ParseClock(%q) accepted", value)
+		}
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}